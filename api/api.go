@@ -0,0 +1,154 @@
+// Package api 定义管理 API 的请求和响应类型。服务端（store、handler）和
+// Go 客户端（client）共用这些定义，字段只在这里修改，两边不会不一致。
+package api
+
+import "time"
+
+type Status string
+
+const (
+	StatusPending Status = "PENDING"
+	StatusSuccess Status = "SUCCESS"
+	StatusFailed  Status = "FAILED"
+	// StatusAwaitingApproval 表示任务需要第二个人确认，确认前不会下发给 MAA
+	StatusAwaitingApproval Status = "AWAITING_APPROVAL"
+	StatusRejected         Status = "REJECTED"
+)
+
+type Task struct {
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	Params       string     `json:"params,omitempty"`
+	Status       Status     `json:"status"`
+	Payload      string     `json:"payload,omitempty"`
+	Device       string     `json:"device,omitempty"`
+	User         string     `json:"user,omitempty"` // 领取任务的 MAA 用户标识
+	Screen       string     `json:"screen,omitempty"`
+	Priority     int        `json:"priority,omitempty"`     // 越大越先下发，同优先级按 Order
+	Order        int64      `json:"order,omitempty"`        // 队列中的先后，同优先级时小的先下发
+	SubmittedBy  string     `json:"submitted_by,omitempty"` // 待确认任务的提交者
+	ApprovedBy   string     `json:"approved_by,omitempty"`  // 确认（或拒绝）该任务的用户
+	CreatedAt    time.Time  `json:"created_at"`
+	DispatchedAt *time.Time `json:"dispatched_at,omitempty"` // 首次下发给 MAA 的时间
+	DoneAt       *time.Time `json:"done_at,omitempty"`
+}
+
+// AssignedTo 判断任务是否已下发给该用户的该设备。未指定设备的任务下发给所有设备，
+// 下发后任何设备都可以汇报。
+func (t *Task) AssignedTo(user, device string) bool {
+	if t.DispatchedAt == nil {
+		return false
+	}
+	return t.Device == "" || (t.Device == device && t.User == user)
+}
+
+// Done 判断任务是否已经结束（成功、失败或被拒绝）
+func (t *Task) Done() bool {
+	return t.DoneAt != nil
+}
+
+// FinishedBefore 判断任务是否在 before 之前已经结束
+func (t *Task) FinishedBefore(before time.Time) bool {
+	return t.DoneAt != nil && t.DoneAt.Before(before)
+}
+
+// IsScreenshotType 判断任务类型是否为截图，截图任务成功后 Payload 保存截图文件路径
+func IsScreenshotType(taskType string) bool {
+	return taskType == "CaptureImage" || taskType == "CaptureImageNow"
+}
+
+// ScreenshotFile 返回任务保存的截图路径，不是成功的截图任务时返回空字符串
+func (t *Task) ScreenshotFile() string {
+	if !IsScreenshotType(t.Type) || t.Status != StatusSuccess {
+		return ""
+	}
+	return t.Payload
+}
+
+// TaskResult 是等待任务结束接口的响应。等待超时时任务可能仍未结束，以 DoneAt 是否为空判断。
+type TaskResult struct {
+	*Task
+	ScreenshotURL string `json:"screenshot_url,omitempty"` // 成功的截图任务的下载地址
+}
+
+// Device 是轮询过获取任务端点的 MAA 实例
+type Device struct {
+	ID       string    `json:"id"`
+	User     string    `json:"user"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+// SubmitTaskRequest 是 POST /api/v1/tasks（及旧的 POST /admin/task）的请求体
+type SubmitTaskRequest struct {
+	Type   string `json:"type" binding:"required"`
+	Params string `json:"params,omitempty"`
+	Device string `json:"device,omitempty"` // 可选，只下发给该设备
+	// Priority 可选，越大越先下发，默认 0，可以为负数
+	Priority int `json:"priority,omitempty"`
+}
+
+// Move 是调整队列顺序的方向
+type Move string
+
+const (
+	MoveUp    Move = "up"    // 与前一个会下发给同一设备的任务交换
+	MoveDown  Move = "down"  // 与后一个会下发给同一设备的任务交换
+	MoveFront Move = "front" // 移到会下发给同一设备的任务之前
+)
+
+// MoveTaskRequest 是调整尚未下发任务顺序的请求体
+type MoveTaskRequest struct {
+	To Move `json:"to" binding:"required,oneof=up down front"`
+}
+
+// SubmitBatchRequest 是批量下发的请求体，任务按数组顺序入队，要么全部成功，要么一个都不加入。
+// 各任务的 Priority 必须相同。
+type SubmitBatchRequest struct {
+	Tasks []SubmitTaskRequest `json:"tasks" binding:"required,dive"`
+}
+
+// SubmitBatchResponse 是批量下发的响应，顺序与请求一致
+type SubmitBatchResponse struct {
+	Tasks []*Task `json:"tasks"`
+}
+
+// Alert 是卡死检测产生的告警
+type Alert struct {
+	Device     string    `json:"device"`
+	TaskID     string    `json:"task_id"`
+	TaskType   string    `json:"task_type"`
+	Frames     int       `json:"frames"`
+	StopQueued bool      `json:"stop_queued"`
+	Screenshot string    `json:"screenshot"` // 最后一帧的分享链接
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Identity 是 GET /api/v1/me 的响应：登录用户返回 Username、Role、Types，
+// API 密钥返回 Name、Scopes 等密钥信息
+type Identity struct {
+	Username string   `json:"username,omitempty"`
+	Role     string   `json:"role,omitempty"`
+	Types    []string `json:"types,omitempty"`
+	Name     string   `json:"name,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	Devices  []string `json:"devices,omitempty"`
+	CSRF     string   `json:"csrf,omitempty"` // 控制面板会话写请求须带上的 X-CSRF-Token
+}
+
+// Error 是 /admin 下错误响应的格式
+type Error struct {
+	Error string `json:"error"`
+}
+
+// ErrorEnvelope 是 /api/v1 下所有错误响应的格式
+type ErrorEnvelope struct {
+	Error Problem `json:"error"`
+}
+
+// Problem 描述一个错误。Code 是稳定的机器可读代码，如 not_found、rate_limited；
+// Message 是给人看的说明，可能随版本变化。
+type Problem struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Status  int    `json:"status"`
+}
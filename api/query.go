@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 500
+)
+
+// TaskQuery 是 GET /api/v1/tasks 的查询参数，零值表示不限
+type TaskQuery struct {
+	ID     string    // id=，任务 ID 前缀，如 maactl 显示的 8 位短 ID
+	Status []Status  // status=PENDING,SUCCESS
+	Type   []string  // type=LinkStart*,CaptureImageNow，末尾 * 表示前缀匹配
+	Device string    // device=
+	Screen string    // screen=，截图识别出的画面标签
+	Since  time.Time // since=，按创建时间，RFC3339
+	Until  time.Time // until=
+	Sort   string    // sort=created_at 或 -created_at（默认，最新的在前）
+	Limit  int       // limit=，默认 50，最大 500
+	Cursor string    // cursor=，上一页返回的 next_cursor
+}
+
+// TaskList 是 GET /api/v1/tasks 的响应
+type TaskList struct {
+	Items      []*Task `json:"items"`
+	NextCursor string  `json:"next_cursor,omitempty"` // 为空表示没有更多
+}
+
+// Ascending 判断是否按创建时间从早到晚排序
+func (q TaskQuery) Ascending() bool {
+	return q.Sort == "created_at"
+}
+
+// Values 把查询编码为 URL 参数
+func (q TaskQuery) Values() url.Values {
+	v := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			v.Set(key, value)
+		}
+	}
+	statuses := make([]string, len(q.Status))
+	for i, s := range q.Status {
+		statuses[i] = string(s)
+	}
+	set("id", q.ID)
+	set("status", strings.Join(statuses, ","))
+	set("type", strings.Join(q.Type, ","))
+	set("device", q.Device)
+	set("screen", q.Screen)
+	if !q.Since.IsZero() {
+		set("since", q.Since.Format(time.RFC3339Nano))
+	}
+	if !q.Until.IsZero() {
+		set("until", q.Until.Format(time.RFC3339Nano))
+	}
+	set("sort", q.Sort)
+	if q.Limit > 0 {
+		set("limit", strconv.Itoa(q.Limit))
+	}
+	set("cursor", q.Cursor)
+	return v
+}
+
+// ParseTaskQuery 解析并校验 URL 参数，未指定的 limit 取默认值
+func ParseTaskQuery(v url.Values) (TaskQuery, error) {
+	q := TaskQuery{
+		ID:     v.Get("id"),
+		Type:   splitList(v.Get("type")),
+		Device: v.Get("device"),
+		Screen: v.Get("screen"),
+		Sort:   v.Get("sort"),
+		Limit:  DefaultPageSize,
+		Cursor: v.Get("cursor"),
+	}
+	for _, s := range splitList(v.Get("status")) {
+		status := Status(strings.ToUpper(s))
+		switch status {
+		case StatusPending, StatusSuccess, StatusFailed, StatusAwaitingApproval, StatusRejected:
+			q.Status = append(q.Status, status)
+		default:
+			return q, fmt.Errorf("未知的状态 %q", s)
+		}
+	}
+	for name, dst := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if s := v.Get(name); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				return q, fmt.Errorf("%s 应为 RFC3339 时间，如 2024-01-02T15:04:05+08:00", name)
+			}
+			*dst = t
+		}
+	}
+	switch q.Sort {
+	case "", "-created_at", "created_at":
+	default:
+		return q, errors.New("sort 只能是 created_at 或 -created_at")
+	}
+	if s := v.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > MaxPageSize {
+			return q, fmt.Errorf("limit 应为 1-%d 的整数", MaxPageSize)
+		}
+		q.Limit = n
+	}
+	return q, nil
+}
+
+func splitList(v string) []string {
+	var result []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTaskQuery(t *testing.T) {
+	v, _ := url.ParseQuery("id=0123abcd&status=pending,FAILED&type=LinkStart*,+CaptureImageNow&device=dev1&screen=main&since=2024-01-02T15:04:05%2B08:00&sort=created_at&limit=10&cursor=abc")
+	q, err := ParseTaskQuery(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := time.Date(2024, 1, 2, 7, 4, 5, 0, time.UTC)
+	want := TaskQuery{
+		ID:     "0123abcd",
+		Status: []Status{StatusPending, StatusFailed},
+		Type:   []string{"LinkStart*", "CaptureImageNow"},
+		Device: "dev1",
+		Screen: "main",
+		Since:  q.Since,
+		Sort:   "created_at",
+		Limit:  10,
+		Cursor: "abc",
+	}
+	if !reflect.DeepEqual(q, want) || !q.Since.Equal(since) || !q.Ascending() {
+		t.Errorf("解析为 %+v", q)
+	}
+
+	// 编码后再解析得到同样的查询
+	again, err := ParseTaskQuery(q.Values())
+	if err != nil || !reflect.DeepEqual(again.Status, q.Status) || !again.Since.Equal(q.Since) || again.Cursor != q.Cursor || again.ID != q.ID {
+		t.Errorf("Values 往返后为 %+v, %v", again, err)
+	}
+
+	if q, err := ParseTaskQuery(url.Values{}); err != nil || q.Limit != DefaultPageSize || q.Ascending() {
+		t.Errorf("没有参数时为 %+v, %v", q, err)
+	}
+	if v := (TaskQuery{}).Values(); len(v) != 0 {
+		t.Errorf("零值编码为 %s", v.Encode())
+	}
+}
+
+func TestParseTaskQueryInvalid(t *testing.T) {
+	for _, c := range []struct{ query, want string }{
+		{"status=DONE", "DONE"},
+		{"since=yesterday", "since"},
+		{"until=2024-01-02", "until"},
+		{"sort=type", "sort"},
+		{"limit=0", "limit"},
+		{"limit=501", "limit"},
+		{"limit=x", "limit"},
+	} {
+		v, _ := url.ParseQuery(c.query)
+		if _, err := ParseTaskQuery(v); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: 返回 %v", c.query, err)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"ArknightsMaaRemoter/config"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 导出与导入 ────────────────────────────────────────────────
+
+// 导出包是一个 zip 文件：manifest.json、tasks.json（格式同数据目录中的 tasks.json），
+// 以及 screenshots/ 下的截图。截图与任务的对应关系记录在 manifest 中，
+// 导入时截图放入目标的截图目录并改写任务中的路径，因此可以跨机器、跨系统迁移。
+const archiveVersion = 1
+
+type archiveManifest struct {
+	Version     int               `json:"version"`
+	ExportedAt  time.Time         `json:"exported_at"`
+	Tasks       int               `json:"tasks"`
+	Screenshots map[string]string `json:"screenshots"` // 任务 ID → 包内路径
+}
+
+func runExport(args []string) error {
+	flags, configFlag := newFlagSet("export", "")
+	out := flags.String("o", "", "输出文件（默认 maa-export-<时间>.zip）")
+	days := flags.Int("days", 0, "只导出最近 N 天创建的任务，0 表示全部")
+	noScreenshots := flags.Bool("no-screenshots", false, "不导出截图文件")
+	_ = flags.Parse(args)
+
+	name := *out
+	if name == "" {
+		name = "maa-export-" + time.Now().Format("20060102_150405") + ".zip"
+	}
+	cfg, _, err := openDataDir(*configFlag, false)
+	if err != nil {
+		return err
+	}
+
+	var since time.Time
+	if *days > 0 {
+		since = time.Now().AddDate(0, 0, -*days)
+	}
+	all := store.New(cfg.DataDir).All()
+	var tasks []*store.Task
+	// All 返回最新的在前，导出包按创建顺序保存
+	for i := len(all) - 1; i >= 0; i-- {
+		if !all[i].CreatedAt.Before(since) {
+			tasks = append(tasks, all[i])
+		}
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	manifest, missing, err := writeArchive(f, cfg, tasks, !*noScreenshots)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name)
+		return err
+	}
+	fmt.Printf("已导出 %d 个任务、%d 张截图到 %s\n", manifest.Tasks, len(manifest.Screenshots), name)
+	if missing > 0 {
+		fmt.Printf("有 %d 张截图文件不存在，已跳过（可运行 doctor 查看）\n", missing)
+	}
+	return nil
+}
+
+// writeArchive 写入导出包，返回清单和缺失的截图数量
+func writeArchive(w io.Writer, cfg *config.Config, tasks []*store.Task, screenshots bool) (*archiveManifest, int, error) {
+	zw := zip.NewWriter(w)
+	manifest := &archiveManifest{
+		Version:     archiveVersion,
+		ExportedAt:  time.Now(),
+		Tasks:       len(tasks),
+		Screenshots: make(map[string]string),
+	}
+
+	missing := 0
+	for _, t := range tasks {
+		file := t.ScreenshotFile()
+		if !screenshots || file == "" {
+			continue
+		}
+		entry := "screenshots/" + filepath.Base(file)
+		if err := copyToZip(zw, entry, cfg.Resolve(file)); errors.Is(err, os.ErrNotExist) {
+			missing++
+			continue
+		} else if err != nil {
+			return nil, 0, err
+		}
+		manifest.Screenshots[t.ID] = entry
+	}
+
+	if tasks == nil {
+		tasks = []*store.Task{}
+	}
+	for name, v := range map[string]any{"tasks.json": tasks, "manifest.json": manifest} {
+		data, _ := json.MarshalIndent(v, "", "  ")
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: manifest.ExportedAt})
+		if err != nil {
+			return nil, 0, err
+		}
+		if _, err := fw.Write(data); err != nil {
+			return nil, 0, err
+		}
+	}
+	return manifest, missing, zw.Close()
+}
+
+func copyToZip(zw *zip.Writer, entry, file string) error {
+	src, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	// PNG 已经压缩过，直接存储
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: entry, Method: zip.Store, Modified: info.ModTime()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, src)
+	return err
+}
+
+func runImport(args []string) error {
+	flags, configFlag := newFlagSet("import", "<导出包.zip>")
+	force := flags.Bool("force", false, "不检查服务是否正在运行")
+	_ = flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	name := flags.Arg(0)
+	cfg, _, err := openDataDir(*configFlag, true)
+	if err != nil {
+		return err
+	}
+	if !*force {
+		if err := ensureStopped(cfg); err != nil {
+			return err
+		}
+	}
+
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return fmt.Errorf("打开导出包失败: %w", err)
+	}
+	defer zr.Close()
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var manifest archiveManifest
+	var tasks []*store.Task
+	if err := readZipJSON(files, "manifest.json", &manifest); err != nil {
+		return err
+	}
+	if manifest.Version != archiveVersion {
+		return fmt.Errorf("不支持的导出包版本 %d", manifest.Version)
+	}
+	if err := readZipJSON(files, "tasks.json", &tasks); err != nil {
+		return err
+	}
+
+	s := store.New(cfg.DataDir)
+	var fresh []*store.Task
+	screenshots := 0
+	for _, t := range tasks {
+		if !store.ValidID(t.ID) || s.Get(t.ID) != nil {
+			continue
+		}
+		if entry, ok := manifest.Screenshots[t.ID]; ok {
+			file, err := extractScreenshot(files, entry, cfg)
+			if err != nil {
+				return err
+			}
+			t.Payload = file
+			screenshots++
+		}
+		fresh = append(fresh, t)
+	}
+	open := 0
+	for _, t := range fresh {
+		if t.Status == store.StatusPending || t.Status == store.StatusAwaitingApproval {
+			open++
+		}
+	}
+	added := s.Import(fresh)
+	fmt.Printf("已导入 %d 个任务、%d 张截图，跳过 %d 个已存在的任务\n", added, screenshots, len(tasks)-added)
+	if open > 0 {
+		fmt.Printf("其中 %d 个未结束的任务已改为待确认，须在控制面板确认后才会下发\n", open)
+	}
+	return nil
+}
+
+func readZipJSON(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("导出包中缺少 %s", name)
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	if err := json.NewDecoder(r).Decode(v); err != nil {
+		return fmt.Errorf("%s 解析失败: %w", name, err)
+	}
+	return nil
+}
+
+// extractScreenshot 把包内截图解压到截图目录，返回保存在任务中的路径（与服务保存截图时的格式相同）。
+// 只接受 screenshots/ 下的单层文件名，避免路径穿越；同名文件已存在时直接复用。
+func extractScreenshot(files map[string]*zip.File, entry string, cfg *config.Config) (string, error) {
+	base := path.Base(entry)
+	f, ok := files[entry]
+	if !ok || entry != "screenshots/"+base || base == "." || base == ".." {
+		return "", fmt.Errorf("导出包中的截图路径 %q 无效", entry)
+	}
+	payload := filepath.Join(cfg.ScreenshotDir, base)
+	target := cfg.Resolve(payload)
+	if _, err := os.Stat(target); err == nil {
+		return payload, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	dst, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(dst, r)
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(target)
+		return "", err
+	}
+	return payload, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"ArknightsMaaRemoter/config"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 命令行子命令 ──────────────────────────────────────────────
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands []command
+
+func init() {
+	// 在 init 中赋值，避免 help 引用 commands 造成初始化循环
+	commands = []command{
+		{"serve", "启动服务（不带命令时的默认行为）", serve},
+		{"export", "把任务和截图导出为 zip 包", runExport},
+		{"import", "从 export 生成的 zip 包导入任务和截图", runImport},
+		{"prune", "按保留天数离线清理已结束的任务和截图", runPrune},
+		{"doctor", "检查数据目录权限、数据文件完整性和截图路径", runDoctor},
+		{"help", "显示本帮助", func([]string) error { usage(); return nil }},
+	}
+}
+
+func runCommand(name string, args []string) error {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			if name != "serve" {
+				// 子命令的输出给人看，不需要时间戳
+				log.SetFlags(0)
+			}
+			return cmd.run(args)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "未知命令 %q\n\n", name)
+	usage()
+	os.Exit(2)
+	return nil
+}
+
+func usage() {
+	prog := filepath.Base(os.Args[0])
+	fmt.Fprintf(os.Stderr, "用法: %s [命令] [参数]\n\n命令:\n", prog)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\n每个命令都支持 -config 指定配置文件，用 %s <命令> -h 查看参数。\n", prog)
+}
+
+// newFlagSet 创建子命令的参数集，并注册公共的 -config 参数。
+// positional 是参数之后的位置参数说明，用于帮助信息。
+func newFlagSet(name, positional string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	configFlag := flags.String("config", "", "配置文件路径（默认读取 MAA_CONFIG 或当前目录的 config.yaml）")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "用法: %s %s [参数] %s\n", filepath.Base(os.Args[0]), name, positional)
+		flags.PrintDefaults()
+	}
+	return flags, configFlag
+}
+
+// ensureStopped 确认服务没有在运行。服务把任务保存在内存中并随时写回文件，
+// 离线修改 tasks.json 会被运行中的服务覆盖。只能检测本机上监听同一端口的服务。
+func ensureStopped(cfg *config.Config) error {
+	host, port, _ := net.SplitHostPort(cfg.Listen)
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), 500*time.Millisecond)
+	if err != nil {
+		return nil
+	}
+	conn.Close()
+	return fmt.Errorf("服务似乎正在运行（%s 可以连接），请先停止服务，或加 -force 跳过检查", cfg.Listen)
+}
+
+// screenshotRefs 返回任务引用的截图文件，键为绝对路径
+func screenshotRefs(cfg *config.Config, tasks []*store.Task) map[string]bool {
+	refs := make(map[string]bool)
+	for _, t := range tasks {
+		if file := cfg.Resolve(t.ScreenshotFile()); file != "" {
+			if abs, err := filepath.Abs(file); err == nil {
+				refs[abs] = true
+			}
+		}
+	}
+	return refs
+}
+
+// orphanScreenshots 返回截图目录中没有被 refs 引用的文件
+func orphanScreenshots(dir string, refs map[string]bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var orphans []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".png") {
+			continue
+		}
+		file := filepath.Join(dir, e.Name())
+		if abs, err := filepath.Abs(file); err == nil && !refs[abs] {
+			orphans = append(orphans, file)
+		}
+	}
+	return orphans, nil
+}
@@ -0,0 +1,351 @@
+// Package client 是 MAA Remote 管理 API 的 Go 客户端。
+//
+//	c := client.New("https://maa.example.com", client.WithToken("maa_..."))
+//	t, err := c.SubmitTask(ctx, api.SubmitTaskRequest{Type: "LinkStart", Device: "..."})
+//	t, err = c.WaitForTask(ctx, t.ID, 0)
+//
+// 请求和响应类型定义在 api 包中，与服务端共用。
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ArknightsMaaRemoter/api"
+)
+
+// Client 是管理 API 客户端，可以在多个 goroutine 中并发使用
+type Client struct {
+	base     string
+	token    string
+	user     string
+	password string
+	http     *http.Client
+	retries  int
+	backoff  time.Duration
+}
+
+type Option func(*Client)
+
+// WithToken 使用 API 密钥（或旧版 ADMIN_TOKEN）认证
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithBasicAuth 使用用户名和密码认证
+func WithBasicAuth(user, password string) Option {
+	return func(c *Client) { c.user, c.password = user, password }
+}
+
+// WithHTTPClient 替换默认的 http.Client，如需要信任自签 CA 时
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// maxRetryAfter 是 429 响应的 Retry-After 最多等待的时间，超过时直接返回 429
+const maxRetryAfter = 30 * time.Second
+
+// WithRetries 设置失败后的最多重试次数和首次重试的等待时间（之后每次翻倍），默认 3 次、500ms。
+// 网络错误和 5xx 只对 GET 重试；429 对所有请求重试，因为被限流的请求不会被处理，
+// 但 Retry-After 超过 30 秒时不再等待，直接返回状态码 429 的 *Error。
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = n, backoff }
+}
+
+// New 创建客户端，baseURL 为服务器地址，如 https://maa.example.com
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		base:    strings.TrimRight(baseURL, "/"),
+		http:    &http.Client{Timeout: 60 * time.Second},
+		retries: 3,
+		backoff: 500 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error 是服务端返回的错误响应
+type Error struct {
+	StatusCode int
+	Code       string // 机器可读的错误代码，如 not_found，见 api.Problem
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("%s（HTTP %d）", e.Message, e.StatusCode)
+}
+
+// IsNotFound 判断错误是否表示对象不存在
+func IsNotFound(err error) bool {
+	return statusOf(err) == http.StatusNotFound
+}
+
+// IsUnauthorized 判断错误是否表示认证失败
+func IsUnauthorized(err error) bool {
+	return statusOf(err) == http.StatusUnauthorized
+}
+
+func statusOf(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// do 发送请求，body 非空时编码为 JSON，按重试策略重试。状态码不是 2xx 时返回 *Error。
+func (c *Client) do(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	wait := c.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.send(ctx, method, path, data)
+		retry := false
+		switch {
+		case err != nil:
+			retry = method == http.MethodGet && ctx.Err() == nil
+		case resp.StatusCode == http.StatusTooManyRequests:
+			retry = true
+			if s, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil {
+				after := time.Duration(s) * time.Second
+				retry = after <= maxRetryAfter
+				if after > wait {
+					wait = after
+				}
+			}
+		case resp.StatusCode >= 500:
+			retry = method == http.MethodGet
+		}
+		if !retry || attempt >= c.retries {
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode >= 300 {
+				return nil, readError(resp)
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > maxRetryAfter {
+			wait = maxRetryAfter
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, data []byte) (*http.Response, error) {
+	var reader io.Reader
+	if data != nil {
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.user != "":
+		req.SetBasicAuth(c.user, c.password)
+	}
+	return c.http.Do(req)
+}
+
+// readError 解析错误响应，兼容 /api/v1 的信封格式和 /admin 的 {"error": "说明"}
+func readError(resp *http.Response) error {
+	defer resp.Body.Close()
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	e := &Error{StatusCode: resp.StatusCode}
+	var p api.Problem
+	if json.Unmarshal(body.Error, &e.Message) != nil && json.Unmarshal(body.Error, &p) == nil {
+		e.Code, e.Message = p.Code, p.Message
+	}
+	return e
+}
+
+// call 发送请求并把 JSON 响应解码到 out
+func (c *Client) call(ctx context.Context, method, path string, body, out any) error {
+	resp, err := c.do(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	return nil
+}
+
+// Me 返回当前认证身份
+func (c *Client) Me(ctx context.Context) (*api.Identity, error) {
+	var me api.Identity
+	return &me, c.call(ctx, http.MethodGet, "/api/v1/me", nil, &me)
+}
+
+// SubmitTask 下发任务。需要双人确认的任务返回时状态为 api.StatusAwaitingApproval。
+func (c *Client) SubmitTask(ctx context.Context, req api.SubmitTaskRequest) (*api.Task, error) {
+	var t api.Task
+	return &t, c.call(ctx, http.MethodPost, "/api/v1/tasks", req, &t)
+}
+
+// SubmitTasks 按顺序批量下发任务。任一任务校验失败时返回错误，且没有任务入队。
+func (c *Client) SubmitTasks(ctx context.Context, reqs []api.SubmitTaskRequest) ([]*api.Task, error) {
+	var resp api.SubmitBatchResponse
+	if err := c.call(ctx, http.MethodPost, "/api/v1/tasks/batch", api.SubmitBatchRequest{Tasks: reqs}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tasks, nil
+}
+
+// Queue 返回尚未下发给 MAA 的任务，顺序即下发顺序
+func (c *Client) Queue(ctx context.Context) ([]*api.Task, error) {
+	tasks := make([]*api.Task, 0)
+	return tasks, c.call(ctx, http.MethodGet, "/api/v1/queue", nil, &tasks)
+}
+
+// MoveTask 调整尚未下发任务的顺序，返回调整后的队列。已下发的任务返回 409。
+func (c *Client) MoveTask(ctx context.Context, id string, to api.Move) ([]*api.Task, error) {
+	tasks := make([]*api.Task, 0)
+	return tasks, c.call(ctx, http.MethodPost, "/api/v1/queue/"+url.PathEscape(id)+"/move", api.MoveTaskRequest{To: to}, &tasks)
+}
+
+// QueryTasks 查询一页任务。继续翻页时把返回的 NextCursor 填入 q.Cursor，其他条件保持不变。
+func (c *Client) QueryTasks(ctx context.Context, q api.TaskQuery) (*api.TaskList, error) {
+	path := "/api/v1/tasks"
+	if v := q.Values(); len(v) > 0 {
+		path += "?" + v.Encode()
+	}
+	var list api.TaskList
+	return &list, c.call(ctx, http.MethodGet, path, nil, &list)
+}
+
+// ListTasks 逐页取回所有可见的任务，最新的在前
+func (c *Client) ListTasks(ctx context.Context) ([]*api.Task, error) {
+	q := api.TaskQuery{Limit: api.MaxPageSize}
+	tasks := make([]*api.Task, 0)
+	for {
+		page, err := c.QueryTasks(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, page.Items...)
+		if page.NextCursor == "" {
+			return tasks, nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// GetTask 按 ID 获取任务，不存在时返回的错误满足 IsNotFound
+func (c *Client) GetTask(ctx context.Context, id string) (*api.Task, error) {
+	var t api.Task
+	if err := c.call(ctx, http.MethodGet, "/api/v1/tasks/"+url.PathEscape(id), nil, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// WaitTask 在服务端等待任务结束，最多等待 timeout（不超过 5 分钟，且应小于 HTTP 客户端的超时）。
+// 超时时返回任务的当前状态，不是错误，用 Task.Done 判断是否已结束。
+func (c *Client) WaitTask(ctx context.Context, id string, timeout time.Duration) (*api.TaskResult, error) {
+	var r api.TaskResult
+	path := "/api/v1/tasks/" + url.PathEscape(id) + "/wait?timeout=" + url.QueryEscape(timeout.String())
+	if err := c.call(ctx, http.MethodGet, path, nil, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// WaitForTask 等待任务结束（成功、失败或被拒绝），超时由 ctx 控制。
+// 任务一结束服务端就会返回，interval 是每次请求在服务端等待的最长时间，为 0 时 30 秒。
+func (c *Client) WaitForTask(ctx context.Context, id string, interval time.Duration) (*api.Task, error) {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	for {
+		r, err := c.WaitTask(ctx, id, interval)
+		if err != nil {
+			return nil, err
+		}
+		if r.Task.Done() {
+			return r.Task, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return r.Task, err
+		}
+	}
+}
+
+// GetScreenshot 把截图任务的 PNG 写入 w
+func (c *Client) GetScreenshot(ctx context.Context, id string, w io.Writer) error {
+	resp, err := c.do(ctx, http.MethodGet, "/api/v1/screenshot/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// CaptureScreenshot 让设备立即截图并把 PNG 写入 w，返回截图任务的 ID。
+// timeout 为服务端最长等待时间（为 0 时 30 秒），设备离线或超时返回状态码 504 的 *Error。
+func (c *Client) CaptureScreenshot(ctx context.Context, device string, timeout time.Duration, w io.Writer) (string, error) {
+	path := "/api/v1/devices/" + url.PathEscape(device) + "/screenshot"
+	if timeout > 0 {
+		path += "?timeout=" + url.QueryEscape(timeout.String())
+	}
+	resp, err := c.do(ctx, http.MethodPost, path, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return resp.Header.Get("X-Task-ID"), err
+}
+
+// ListDevices 返回服务启动以来轮询过的设备，最近活跃的在前
+func (c *Client) ListDevices(ctx context.Context) ([]api.Device, error) {
+	var devices []api.Device
+	return devices, c.call(ctx, http.MethodGet, "/api/v1/devices", nil, &devices)
+}
+
+// ListAlerts 返回最近的卡死告警，最新的在前
+func (c *Client) ListAlerts(ctx context.Context) ([]api.Alert, error) {
+	var alerts []api.Alert
+	return alerts, c.call(ctx, http.MethodGet, "/api/v1/alerts", nil, &alerts)
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer 前 fail 次请求返回 status，之后返回 200
+func countingServer(t *testing.T, fail int32, status int, retryAfter string) (*Client, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= fail {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		_, _ = w.Write([]byte(`{"username":"admin"}`))
+	}))
+	t.Cleanup(srv.Close)
+	return New(srv.URL, WithRetries(3, time.Millisecond)), &calls
+}
+
+func TestRetries(t *testing.T) {
+	ctx := context.Background()
+
+	c, calls := countingServer(t, 2, http.StatusBadGateway, "")
+	if me, err := c.Me(ctx); err != nil || me.Username != "admin" || *calls != 3 {
+		t.Errorf("GET 遇到 5xx: %v, %v，请求了 %d 次", me, err, *calls)
+	}
+
+	c, calls = countingServer(t, 10, http.StatusBadGateway, "")
+	if _, err := c.Me(ctx); statusOf(err) != http.StatusBadGateway || *calls != 4 {
+		t.Errorf("一直 5xx 时返回 %v，请求了 %d 次", err, *calls)
+	}
+
+	// POST 遇到 5xx 不重试，以免重复下发
+	c, calls = countingServer(t, 10, http.StatusInternalServerError, "")
+	if err := c.call(ctx, http.MethodPost, "/api/v1/tasks", struct{}{}, nil); statusOf(err) != http.StatusInternalServerError || *calls != 1 {
+		t.Errorf("POST 遇到 5xx 返回 %v，请求了 %d 次", err, *calls)
+	}
+
+	// 被限流的请求没有被处理，POST 也会重试
+	c, calls = countingServer(t, 1, http.StatusTooManyRequests, "0")
+	if err := c.call(ctx, http.MethodPost, "/api/v1/tasks", struct{}{}, nil); err != nil || *calls != 2 {
+		t.Errorf("429 后重试返回 %v，请求了 %d 次", err, *calls)
+	}
+
+	// Retry-After 太长时直接返回 429
+	c, calls = countingServer(t, 1, http.StatusTooManyRequests, "3600")
+	start := time.Now()
+	if _, err := c.Me(ctx); statusOf(err) != http.StatusTooManyRequests || *calls != 1 || time.Since(start) > 5*time.Second {
+		t.Errorf("Retry-After 为 1 小时时返回 %v，请求了 %d 次，耗时 %s", err, *calls, time.Since(start))
+	}
+
+	// 等待重试时 ctx 取消立即返回
+	c, _ = countingServer(t, 1, http.StatusTooManyRequests, "20")
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.Me(cctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("等待重试时 ctx 超时返回 %v", err)
+	}
+}
+
+func TestReadError(t *testing.T) {
+	for _, c := range []struct {
+		name, body       string
+		status           int
+		code, message    string
+		notFound, unauth bool
+	}{
+		{"信封格式", `{"error":{"code":"not_found","message":"任务不存在","status":404}}`, 404, "not_found", "任务不存在", true, false},
+		{"旧格式", `{"error":"认证失败"}`, 401, "", "认证失败", false, true},
+		{"非 JSON", `<html>bad gateway</html>`, 502, "", "", false, false},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(c.status)
+			_, _ = w.Write([]byte(c.body))
+		}))
+		_, err := New(srv.URL, WithRetries(0, 0)).GetTask(context.Background(), "x")
+		srv.Close()
+
+		var e *Error
+		if !errors.As(err, &e) {
+			t.Fatalf("%s: 返回 %v，期望 *Error", c.name, err)
+		}
+		if e.StatusCode != c.status || e.Code != c.code || e.Message != c.message {
+			t.Errorf("%s: 解析为 %+v", c.name, e)
+		}
+		if IsNotFound(err) != c.notFound || IsUnauthorized(err) != c.unauth {
+			t.Errorf("%s: IsNotFound=%v IsUnauthorized=%v", c.name, IsNotFound(err), IsUnauthorized(err))
+		}
+	}
+	if got := (&Error{StatusCode: 502}).Error(); got != "HTTP 502" {
+		t.Errorf("没有说明时 Error() = %q", got)
+	}
+}
@@ -0,0 +1,192 @@
+package client
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"ArknightsMaaRemoter/api"
+)
+
+type EventType string
+
+const (
+	EventTaskCreated EventType = "task.created" // 出现了新任务
+	EventTaskUpdated EventType = "task.updated" // 任务状态变化，包括被下发、完成、确认和拒绝
+	EventAlert       EventType = "alert"        // 新的卡死告警
+)
+
+// Event 是 StreamEvents 产生的事件，Task 和 Alert 按类型二选一
+type Event struct {
+	Type  EventType
+	Time  time.Time
+	Task  *api.Task
+	Alert *api.Alert
+}
+
+// StreamEvents 每隔 interval（为 0 时 2 秒）轮询一次变化并对每个变化调用 fn，
+// 直到 ctx 结束或 fn 返回错误。开始时已存在的任务和告警不会产生事件。
+// 服务端没有推送接口，事件由轮询得出，间隔内的多次变化只体现为最后一次。
+// 每次只查询上次之后创建的任务和尚未结束的任务，不会重新下载全部历史。
+func (c *Client) StreamEvents(ctx context.Context, interval time.Duration, fn func(Event) error) error {
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	w := &taskWatcher{c: c, seen: make(map[string]bool), open: make(map[string]string), alerts: make(map[string]bool)}
+	// 从最新的任务开始，之前的任务只记录状态
+	latest, err := c.QueryTasks(ctx, api.TaskQuery{Limit: 1})
+	if err != nil {
+		return err
+	}
+	if len(latest.Items) > 0 {
+		w.since = latest.Items[0].CreatedAt
+	}
+	quiet := func(Event) error { return nil }
+	if err := w.poll(ctx, quiet); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		if err := w.poll(ctx, fn); err != nil {
+			return err
+		}
+	}
+}
+
+// taskWatcher 是 StreamEvents 的轮询进度
+type taskWatcher struct {
+	c      *Client
+	since  time.Time         // 已见过的最新任务的创建时间，下次从这里查询
+	seen   map[string]bool   // 创建时间等于 since 的已见过的任务
+	open   map[string]string // 未结束的任务及其状态
+	alerts map[string]bool
+}
+
+func (w *taskWatcher) poll(ctx context.Context, fn func(Event) error) error {
+	now := time.Now()
+
+	// 新创建的任务，按创建时间先后
+	err := w.each(ctx, api.TaskQuery{Since: w.since, Sort: "created_at"}, func(t *api.Task) error {
+		if w.seen[t.ID] {
+			return nil
+		}
+		if t.CreatedAt.After(w.since) {
+			w.since = t.CreatedAt
+			w.seen = make(map[string]bool)
+		}
+		w.seen[t.ID] = true
+		if isOpen(t) {
+			w.open[t.ID] = taskState(t)
+		}
+		return fn(Event{Type: EventTaskCreated, Time: now, Task: t})
+	})
+	if err != nil {
+		return err
+	}
+
+	// 未结束任务的状态变化；从列表中消失的任务已经结束，单独取回最终状态
+	still := make(map[string]bool)
+	open := api.TaskQuery{Status: []api.Status{api.StatusPending, api.StatusAwaitingApproval}, Sort: "created_at"}
+	err = w.each(ctx, open, func(t *api.Task) error {
+		prev, known := w.open[t.ID]
+		if !known && w.isNew(t) {
+			return nil // 两次查询之间刚创建的，留到下次作为新任务
+		}
+		still[t.ID] = true
+		state := taskState(t)
+		w.open[t.ID] = state
+		switch {
+		case !known:
+			// 导入的任务等创建时间早于已见过的最新任务
+			return fn(Event{Type: EventTaskCreated, Time: now, Task: t})
+		case prev != state:
+			return fn(Event{Type: EventTaskUpdated, Time: now, Task: t})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	finished := make([]string, 0)
+	for id := range w.open {
+		if !still[id] {
+			finished = append(finished, id)
+		}
+	}
+	sort.Strings(finished)
+	for _, id := range finished {
+		prev := w.open[id]
+		delete(w.open, id)
+		t, err := w.c.GetTask(ctx, id)
+		if IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if taskState(t) != prev {
+			if err := fn(Event{Type: EventTaskUpdated, Time: now, Task: t}); err != nil {
+				return err
+			}
+		}
+	}
+
+	alertList, err := w.c.ListAlerts(ctx)
+	if err != nil {
+		return err
+	}
+	for i := len(alertList) - 1; i >= 0; i-- {
+		a := alertList[i]
+		key := a.TaskID + "|" + a.CreatedAt.String()
+		if w.alerts[key] {
+			continue
+		}
+		w.alerts[key] = true
+		if err := fn(Event{Type: EventAlert, Time: a.CreatedAt, Alert: &a}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// each 逐页查询并对每个任务调用 fn
+func (w *taskWatcher) each(ctx context.Context, q api.TaskQuery, fn func(*api.Task) error) error {
+	q.Limit = api.MaxPageSize
+	for {
+		page, err := w.c.QueryTasks(ctx, q)
+		if err != nil {
+			return err
+		}
+		for _, t := range page.Items {
+			if err := fn(t); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		q.Cursor = page.NextCursor
+	}
+}
+
+// isNew 判断任务是否在上次查询新任务之后才创建
+func (w *taskWatcher) isNew(t *api.Task) bool {
+	return t.CreatedAt.After(w.since) || (t.CreatedAt.Equal(w.since) && !w.seen[t.ID])
+}
+
+func isOpen(t *api.Task) bool {
+	return t.Status == api.StatusPending || t.Status == api.StatusAwaitingApproval
+}
+
+// taskState 是判断任务是否变化的依据：状态、是否已下发和画面标签
+func taskState(t *api.Task) string {
+	state := string(t.Status)
+	if t.DispatchedAt != nil {
+		state += "|dispatched"
+	}
+	return state + "|" + t.Screen
+}
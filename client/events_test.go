@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/api"
+	"ArknightsMaaRemoter/handler"
+	"ArknightsMaaRemoter/store"
+)
+
+// eventServer 用真实的处理函数提供 StreamEvents 用到的接口，并记录任务列表请求的查询参数
+type eventServer struct {
+	store *store.Store
+	mu    sync.Mutex
+	lists []string
+}
+
+func (s *eventServer) queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.lists...)
+}
+
+func newEventServer(t *testing.T) (*eventServer, *Client) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	es := &eventServer{store: store.New(dir)}
+	h := handler.New(dir, es.store, store.NewReferences(dir), store.NewUsers(dir), store.NewAPIKeys(dir), store.NewSessions(dir, time.Hour), store.NewDeviceTokens(dir), store.NewAudit(dir), store.NewSettings(dir))
+	r := gin.New()
+	r.GET("/api/v1/tasks", func(c *gin.Context) {
+		es.mu.Lock()
+		es.lists = append(es.lists, c.Request.URL.RawQuery)
+		es.mu.Unlock()
+	}, h.QueryTasks)
+	r.GET("/api/v1/tasks/:id", h.GetTaskByID)
+	r.GET("/api/v1/alerts", h.ListAlerts)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return es, New(srv.URL)
+}
+
+func TestStreamEvents(t *testing.T) {
+	es, c := newEventServer(t)
+	s := es.store
+	old := s.Add("LinkStart", "")
+	s.Pending("u1", "dev1")
+	if err := s.Complete(old.ID, "u1", "dev1", "SUCCESS", ""); err != nil {
+		t.Fatal(err)
+	}
+	open := s.AddFor("dev1", "LinkStart-Base", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan Event, 100)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.StreamEvents(ctx, 10*time.Millisecond, func(e Event) error {
+			events <- e
+			return nil
+		})
+	}()
+	// 等开始时的三次查询（最新任务、新任务、未结束任务）完成，之后的变化才会产生事件
+	for deadline := time.Now().Add(5 * time.Second); len(es.queries()) < 3; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("StreamEvents 没有开始轮询")
+		}
+	}
+
+	var got []Event
+	waitFor := func(what string, match func(Event) bool) {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case e := <-events:
+				got = append(got, e)
+				if match(e) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("没有收到%s事件，已收到 %d 个", what, len(got))
+			}
+		}
+	}
+
+	fresh := s.AddFor("dev2", "LinkStart-Mall", "")
+	waitFor("新任务", func(e Event) bool { return e.Type == EventTaskCreated && e.Task.ID == fresh.ID })
+
+	s.Pending("u1", "dev1")
+	if err := s.Complete(open.ID, "u1", "dev1", "FAILED", ""); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("任务结束", func(e Event) bool {
+		return e.Type == EventTaskUpdated && e.Task.ID == open.ID && e.Task.Status == api.StatusFailed
+	})
+
+	// 导入的任务创建时间较早，作为未结束的任务被发现
+	s.Import([]*store.Task{{ID: "imported", Type: "LinkStart", Status: store.StatusPending, CreatedAt: time.Now().Add(-time.Hour)}})
+	waitFor("导入的任务", func(e Event) bool { return e.Type == EventTaskCreated && e.Task.ID == "imported" })
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("ctx 取消后返回 %v", err)
+	}
+	created := 0
+	for _, e := range got {
+		if e.Task != nil && e.Task.ID == old.ID {
+			t.Errorf("开始前已结束的任务产生了事件 %s", e.Type)
+		}
+		if e.Type == EventTaskCreated && e.Task.ID == fresh.ID {
+			created++
+		}
+	}
+	if created != 1 {
+		t.Errorf("新任务产生了 %d 次创建事件", created)
+	}
+	// 除了开始时取最新的一条，每次都按创建时间或状态过滤
+	for _, q := range es.queries()[1:] {
+		if !strings.Contains(q, "since=") && !strings.Contains(q, "status=") {
+			t.Errorf("请求了完整的任务列表: %q", q)
+		}
+	}
+}
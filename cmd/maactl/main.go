@@ -0,0 +1,294 @@
+// maactl 是 MAA Remote 管理 API 的命令行客户端，用于在其他机器的脚本中下发任务、
+// 等待结果和下载截图。
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"ArknightsMaaRemoter/client"
+)
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(args []string) error
+}
+
+var commands []command
+
+// ctx 在按下 Ctrl-C 时取消，用于中断等待和 tail
+var ctx context.Context
+
+var profileFlag = flag.String("profile", "", "使用的服务器配置（默认为 maactl use 选中的配置）")
+
+func init() {
+	// 在 init 中赋值，避免 help 引用 commands 造成初始化循环
+	commands = []command{
+		{"login", "[-server 地址] [-token 令牌] [-ca 文件] [-device 设备] [名称]", "保存服务器地址和令牌并设为当前配置", runLogin},
+		{"logout", "[名称]", "删除配置中保存的令牌", runLogout},
+		{"profiles", "", "列出已保存的服务器配置", runProfiles},
+		{"use", "<名称>", "切换当前配置", runUse},
+		{"types", "", "列出可用的任务名称", runTypes},
+		{"submit", "[-device 设备] [-params 参数] [-priority 优先级] [-wait] <任务>", "下发任务", runSubmit},
+		{"wait", "[-timeout 时长] <任务 ID>", "等待任务完成并显示进度", runWait},
+		{"tasks", "[-n 条数] [-device 设备]", "列出最近的任务", runTasks},
+		{"queue", "[<任务 ID> up|down|front]", "列出尚未下发的任务，或调整其顺序", runQueue},
+		{"tail", "[-interval 间隔]", "持续输出任务状态变化和卡死告警", runTail},
+		{"devices", "", "列出轮询过的设备", runDevices},
+		{"screenshot", "[-device 设备] [-new] [-o 文件]", "下载最新截图，-new 先截一张新的", runScreenshot},
+		{"help", "", "显示本帮助", func([]string) error { usage(); return nil }},
+	}
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	name, args := flag.Arg(0), flag.Args()[1:]
+	var stop context.CancelFunc
+	ctx, stop = signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	for _, cmd := range commands {
+		if cmd.name == name {
+			err := cmd.run(args)
+			if client.IsUnauthorized(err) {
+				err = errors.New("认证失败，请用 maactl login 重新设置令牌")
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "maactl: %v\n", err)
+				stop()
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "未知命令 %q\n\n", name)
+	usage()
+	os.Exit(2)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: maactl [-profile 名称] <命令> [参数]")
+	fmt.Fprintln(os.Stderr, "\n命令:")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(os.Stderr, "\n环境变量 MAACTL_PROFILE、MAACTL_SERVER、MAACTL_TOKEN 可代替保存的配置。")
+}
+
+// newFlagSet 创建子命令的参数集，帮助信息中显示该命令的用法
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		for _, cmd := range commands {
+			if cmd.name == name {
+				fmt.Fprintf(os.Stderr, "用法: maactl %s %s\n%s\n", name, cmd.args, cmd.summary)
+			}
+		}
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseArgs 解析参数并返回位置参数。参数可以写在位置参数之后，
+// 如 maactl submit stage -params 1-7
+func parseArgs(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		_ = flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+// newClient 按配置创建客户端，配置了 CA 文件时只信任该 CA
+func newClient(p *Profile) (*client.Client, error) {
+	opts := []client.Option{client.WithToken(p.Token)}
+	if p.CA != "" {
+		pem, err := os.ReadFile(p.CA)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CA 文件失败: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s 中没有有效的证书", p.CA)
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+		opts = append(opts, client.WithHTTPClient(&http.Client{Transport: transport, Timeout: 60 * time.Second}))
+	}
+	return client.New(p.Server, opts...), nil
+}
+
+// connect 按当前配置创建客户端
+func connect() (*client.Client, *Profile, error) {
+	pf, err := loadProfiles()
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := pf.resolve(*profileFlag)
+	if err != nil {
+		return nil, nil, err
+	}
+	c, err := newClient(p)
+	return c, p, err
+}
+
+func runLogin(args []string) error {
+	flags := newFlagSet("login")
+	server := flags.String("server", "", "服务器地址，如 https://maa.example.com")
+	token := flags.String("token", "", "API 密钥（不指定时从标准输入读取）")
+	ca := flags.String("ca", "", "自签证书的 CA 文件")
+	device := flags.String("device", "", "默认设备")
+	args = parseArgs(flags, args)
+
+	pf, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	name := arg(args, 0)
+	if name == "" {
+		name = *profileFlag
+	}
+	if name == "" {
+		name = "default"
+	}
+	p := pf.Profiles[name]
+	if p == nil {
+		p = &Profile{}
+	}
+	if *server != "" {
+		p.Server = strings.TrimRight(*server, "/")
+	}
+	if p.Server == "" {
+		return errors.New("请用 -server 指定服务器地址")
+	}
+	if *ca != "" {
+		p.CA = *ca
+	}
+	if *device != "" {
+		p.Device = *device
+	}
+	p.Token = *token
+	if p.Token == "" {
+		// 从标准输入读取，避免令牌出现在 shell 历史里
+		fmt.Fprint(os.Stderr, "API 密钥: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return errors.New("未读取到令牌")
+		}
+		p.Token = strings.TrimSpace(line)
+	}
+
+	c, err := newClient(p)
+	if err != nil {
+		return err
+	}
+	me, err := c.Me(ctx)
+	if err != nil {
+		return err
+	}
+	pf.Profiles[name] = p
+	pf.Current = name
+	if err := pf.save(); err != nil {
+		return err
+	}
+	who := me.Username
+	if who == "" {
+		who = "密钥 " + me.Name
+	}
+	fmt.Printf("已登录 %s（%s），配置 %s 已设为当前配置\n", p.Server, who, name)
+	return nil
+}
+
+func runLogout(args []string) error {
+	flags := newFlagSet("logout")
+	args = parseArgs(flags, args)
+	pf, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	name := arg(args, 0)
+	if name == "" {
+		name = pf.Current
+	}
+	p, ok := pf.Profiles[name]
+	if !ok {
+		return fmt.Errorf("配置 %q 不存在", name)
+	}
+	p.Token = ""
+	if err := pf.save(); err != nil {
+		return err
+	}
+	fmt.Printf("已删除配置 %s 的令牌\n", name)
+	return nil
+}
+
+func runProfiles(args []string) error {
+	pf, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	if len(pf.Profiles) == 0 {
+		fmt.Println("尚未保存任何配置，请先运行 maactl login -server <地址>")
+		return nil
+	}
+	for _, name := range pf.names() {
+		p := pf.Profiles[name]
+		mark := " "
+		if name == pf.Current {
+			mark = "*"
+		}
+		state := "已保存令牌"
+		if p.Token == "" {
+			state = "未登录"
+		}
+		fmt.Printf("%s %-12s %s（%s）\n", mark, name, p.Server, state)
+	}
+	return nil
+}
+
+func runUse(args []string) error {
+	if len(args) != 1 {
+		return errors.New("用法: maactl use <名称>")
+	}
+	pf, err := loadProfiles()
+	if err != nil {
+		return err
+	}
+	if _, ok := pf.Profiles[args[0]]; !ok {
+		return fmt.Errorf("配置 %q 不存在，可用 maactl profiles 查看", args[0])
+	}
+	pf.Current = args[0]
+	if err := pf.save(); err != nil {
+		return err
+	}
+	fmt.Printf("当前配置: %s\n", args[0])
+	return nil
+}
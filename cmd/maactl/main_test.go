@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/client"
+	"ArknightsMaaRemoter/handler"
+	"ArknightsMaaRemoter/store"
+)
+
+func init() {
+	ctx = context.Background()
+	gin.SetMode(gin.TestMode)
+}
+
+// useProfileFile 让配置读写临时目录中的文件，并清除会覆盖配置的环境变量
+func useProfileFile(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "maactl", "config.json")
+	t.Setenv("MAACTL_CONFIG", path)
+	for _, name := range []string{"MAACTL_PROFILE", "MAACTL_SERVER", "MAACTL_TOKEN"} {
+		t.Setenv(name, "")
+	}
+	return path
+}
+
+func TestProfiles(t *testing.T) {
+	path := useProfileFile(t)
+	pf, err := loadProfiles()
+	if err != nil || len(pf.Profiles) != 0 {
+		t.Fatalf("配置文件不存在时返回 %+v, %v", pf, err)
+	}
+	if _, err := pf.resolve(""); err == nil {
+		t.Error("没有配置时 resolve 成功")
+	}
+
+	pf.Profiles["home"] = &Profile{Server: "https://home.example", Token: "maa_home", Device: "dev1"}
+	pf.Profiles["work"] = &Profile{Server: "https://work.example", Token: "maa_work"}
+	pf.Current = "home"
+	if err := pf.save(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("配置文件权限为 %v（%v），期望 0600", info.Mode().Perm(), err)
+	}
+
+	pf, err = loadProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(pf.names(), ","); got != "home,work" {
+		t.Errorf("names = %s", got)
+	}
+	if p, _ := pf.resolve(""); p.Server != "https://home.example" || p.Device != "dev1" {
+		t.Errorf("未指定时使用了 %+v，期望当前配置", p)
+	}
+	if p, _ := pf.resolve("work"); p.Token != "maa_work" {
+		t.Errorf("-profile work 使用了 %+v", p)
+	}
+	t.Setenv("MAACTL_PROFILE", "work")
+	if p, _ := pf.resolve(""); p.Token != "maa_work" {
+		t.Errorf("MAACTL_PROFILE=work 时使用了 %+v", p)
+	}
+	t.Setenv("MAACTL_TOKEN", "maa_env")
+	if p, _ := pf.resolve("home"); p.Token != "maa_env" || p.Server != "https://home.example" {
+		t.Errorf("MAACTL_TOKEN 没有覆盖保存的令牌: %+v", p)
+	}
+	if pf.Profiles["home"].Token != "maa_home" {
+		t.Error("环境变量修改了保存的配置")
+	}
+	if _, err := pf.resolve("nope"); err == nil {
+		t.Error("不存在的配置 resolve 成功")
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadProfiles(); err == nil {
+		t.Error("格式错误的配置文件加载成功")
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	flags := newFlagSet("submit")
+	device := flags.String("device", "", "")
+	params := flags.String("params", "", "")
+	wait := flags.Bool("wait", false, "")
+	args := parseArgs(flags, []string{"-device", "dev1", "stage", "-params", "1-7", "-wait"})
+	if strings.Join(args, ",") != "stage" || *device != "dev1" || *params != "1-7" || !*wait {
+		t.Errorf("位置参数 %v，device=%q params=%q wait=%v", args, *device, *params, *wait)
+	}
+	if arg(args, 0) != "stage" || arg(args, 1) != "" {
+		t.Errorf("arg 返回 %q %q", arg(args, 0), arg(args, 1))
+	}
+}
+
+func TestResolveType(t *testing.T) {
+	for name, want := range map[string]string{
+		"stage":      "Settings-Stage1",
+		"STAGE":      "Settings-Stage1",
+		"刷关卡":        "LinkStart-Combat",
+		"linkstart":  "LinkStart",
+		"screenshot": "CaptureImageNow",
+	} {
+		if got, err := resolveType(name); err != nil || got != want {
+			t.Errorf("%s: %s, %v，期望 %s", name, got, err, want)
+		}
+	}
+	if _, err := resolveType("nope"); err == nil {
+		t.Error("未知的任务名解析成功")
+	}
+}
+
+func TestShortID(t *testing.T) {
+	if shortID("0123456789abcdef") != "01234567" || shortID("abc") != "abc" {
+		t.Errorf("shortID 返回 %q %q", shortID("0123456789abcdef"), shortID("abc"))
+	}
+}
+
+// testServer 用真实的处理函数提供 /api/v1/tasks，并记录列表请求的查询参数
+func testServer(t *testing.T) (*store.Store, *client.Client, *[]string) {
+	dir := t.TempDir()
+	s := store.New(dir)
+	h := handler.New(dir, s, store.NewReferences(dir), store.NewUsers(dir), store.NewAPIKeys(dir), store.NewSessions(dir, time.Hour), store.NewDeviceTokens(dir), store.NewAudit(dir), store.NewSettings(dir))
+	var queries []string
+	r := gin.New()
+	r.GET("/api/v1/tasks", func(c *gin.Context) { queries = append(queries, c.Request.URL.RawQuery) }, h.QueryTasks)
+	r.GET("/api/v1/tasks/:id", h.GetTaskByID)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return s, client.New(srv.URL), &queries
+}
+
+func TestFindTask(t *testing.T) {
+	s, c, queries := testServer(t)
+	a := s.Add("LinkStart", "")
+	s.Add("LinkStart", "")
+
+	if got, err := findTask(c, a.ID); err != nil || got.ID != a.ID {
+		t.Errorf("完整 ID 查找到 %v, %v", got, err)
+	}
+	if len(*queries) != 0 {
+		t.Errorf("完整 ID 查找时请求了任务列表: %v", *queries)
+	}
+	if got, err := findTask(c, a.ID[:8]); err != nil || got.ID != a.ID {
+		t.Errorf("短 ID 查找到 %v, %v", got, err)
+	}
+	if len(*queries) != 1 || !strings.Contains((*queries)[0], "id="+a.ID[:8]) {
+		t.Errorf("短 ID 查找时的请求为 %v，期望按 ID 前缀过滤", *queries)
+	}
+	if _, err := findTask(c, "ffffffff"); err == nil {
+		t.Error("不存在的短 ID 查找成功")
+	}
+	if _, err := findTask(c, a.ID[:4]); err == nil {
+		t.Error("不足 8 位的 ID 查找成功")
+	}
+}
+
+func TestLatestScreenshot(t *testing.T) {
+	s, c, queries := testServer(t)
+	if _, err := latestScreenshot(c, ""); err == nil {
+		t.Error("没有截图时返回成功")
+	}
+
+	shot := func(device, status string) *store.Task {
+		task := s.AddFor(device, "CaptureImageNow", "")
+		s.Pending("u1", device)
+		if err := s.Complete(task.ID, "u1", device, status, "screenshots/x.png"); err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+	dev1 := shot("dev1", "SUCCESS")
+	dev2 := shot("dev2", "SUCCESS")
+	shot("dev1", "FAILED")
+	s.AddFor("dev1", "LinkStart", "")
+
+	if got, err := latestScreenshot(c, "dev1"); err != nil || got.ID != dev1.ID {
+		t.Errorf("dev1 最近的截图为 %v, %v，期望 %s", got, err, dev1.ID)
+	}
+	if got, err := latestScreenshot(c, ""); err != nil || got.ID != dev2.ID {
+		t.Errorf("最近的截图为 %v, %v，期望 %s", got, err, dev2.ID)
+	}
+	for _, q := range *queries {
+		if !strings.Contains(q, "limit=1") || !strings.Contains(q, "status=SUCCESS") {
+			t.Errorf("查找截图时的请求为 %s，期望只取一条成功的截图", q)
+		}
+	}
+}
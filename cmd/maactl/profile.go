@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ── 服务器配置 ────────────────────────────────────────────────
+
+// Profile 是一台 MAA Remote 服务器的连接信息
+type Profile struct {
+	Server string `json:"server"`           // 如 https://maa.example.com
+	Token  string `json:"token"`            // API 密钥或旧版 ADMIN_TOKEN
+	CA     string `json:"ca,omitempty"`     // 自签证书的 CA 文件（TLS_SELF_SIGNED 生成的 tls/ca.pem）
+	Device string `json:"device,omitempty"` // 默认设备，submit 和 screenshot 未指定 -device 时使用
+}
+
+type profileFile struct {
+	Current  string              `json:"current"`
+	Profiles map[string]*Profile `json:"profiles"`
+}
+
+// profilePath 返回配置文件路径，可用 MAACTL_CONFIG 环境变量覆盖
+func profilePath() (string, error) {
+	if v := os.Getenv("MAACTL_CONFIG"); v != "" {
+		return v, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "maactl", "config.json"), nil
+}
+
+func loadProfiles() (*profileFile, error) {
+	pf := &profileFile{Profiles: make(map[string]*Profile)}
+	path, err := profilePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return pf, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, pf); err != nil {
+		return nil, fmt.Errorf("%s 格式错误: %w", path, err)
+	}
+	if pf.Profiles == nil {
+		pf.Profiles = make(map[string]*Profile)
+	}
+	return pf, nil
+}
+
+// save 保存配置。文件里有令牌，只允许当前用户读写。
+func (pf *profileFile) save() error {
+	path, err := profilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, _ := json.MarshalIndent(pf, "", "  ")
+	return os.WriteFile(path, data, 0600)
+}
+
+func (pf *profileFile) names() []string {
+	names := make([]string, 0, len(pf.Profiles))
+	for name := range pf.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolve 按 -profile 参数、MAACTL_PROFILE 环境变量、当前配置的顺序选出要用的配置，
+// 再用 MAACTL_SERVER、MAACTL_TOKEN 环境变量覆盖，方便在脚本中不落盘使用。
+func (pf *profileFile) resolve(name string) (*Profile, error) {
+	if name == "" {
+		name = os.Getenv("MAACTL_PROFILE")
+	}
+	if name == "" {
+		name = pf.Current
+	}
+	p := &Profile{}
+	if name != "" {
+		saved, ok := pf.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("配置 %q 不存在，可用 maactl profiles 查看", name)
+		}
+		*p = *saved
+	}
+	if v := os.Getenv("MAACTL_SERVER"); v != "" {
+		p.Server = v
+	}
+	if v := os.Getenv("MAACTL_TOKEN"); v != "" {
+		p.Token = v
+	}
+	if p.Server == "" {
+		return nil, errors.New("尚未配置服务器，请先运行 maactl login -server <地址>")
+	}
+	return p, nil
+}
@@ -0,0 +1,436 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"ArknightsMaaRemoter/api"
+	"ArknightsMaaRemoter/client"
+)
+
+// ── 任务 ──────────────────────────────────────────────────────
+
+// taskNames 是任务的简称和中文名，与控制面板的下拉框一致
+var taskNames = []struct {
+	alias, taskType, zh string
+}{
+	{"start", "LinkStart", "一键长草"},
+	{"base", "LinkStart-Base", "基建"},
+	{"wakeup", "LinkStart-WakeUp", "唤醒登录"},
+	{"combat", "LinkStart-Combat", "刷关卡"},
+	{"recruit", "LinkStart-Recruiting", "公开招募"},
+	{"mall", "LinkStart-Mall", "商店"},
+	{"mission", "LinkStart-Mission", "日常任务"},
+	{"roguelike", "LinkStart-AutoRoguelike", "自动肉鸽"},
+	{"reclamation", "LinkStart-Reclamation", "生息演算"},
+	{"screenshot", "CaptureImageNow", "立刻截图"},
+	{"capture", "CaptureImage", "排队截图"},
+	{"heartbeat", "HeartBeat", "心跳检测"},
+	{"stop", "StopTask", "停止当前任务"},
+	{"gacha", "Toolbox-GachaOnce", "牛牛抽卡单次"},
+	{"gacha10", "Toolbox-GachaTenTimes", "牛牛抽卡十连"},
+	{"address", "Settings-ConnectionAddress", "修改连接地址"},
+	{"stage", "Settings-Stage1", "修改关卡"},
+}
+
+// resolveType 把简称、中文名或任务类型（不区分大小写）转成任务类型
+func resolveType(name string) (string, error) {
+	for _, n := range taskNames {
+		if strings.EqualFold(name, n.alias) || name == n.zh || strings.EqualFold(name, n.taskType) {
+			return n.taskType, nil
+		}
+	}
+	return "", fmt.Errorf("未知的任务 %q，可用 maactl types 查看", name)
+}
+
+func typeName(taskType string) string {
+	for _, n := range taskNames {
+		if n.taskType == taskType {
+			return n.zh
+		}
+	}
+	return taskType
+}
+
+var statusNames = map[api.Status]string{
+	api.StatusPending:          "待执行",
+	api.StatusSuccess:          "成功",
+	api.StatusFailed:           "失败",
+	api.StatusAwaitingApproval: "待确认",
+	api.StatusRejected:         "已拒绝",
+}
+
+// statusText 返回任务状态的中文描述，已下发但未完成的任务显示为执行中
+func statusText(t *api.Task) string {
+	if t.Status == api.StatusPending && t.DispatchedAt != nil {
+		return "执行中"
+	}
+	if s, ok := statusNames[t.Status]; ok {
+		return s
+	}
+	return string(t.Status)
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func runTypes(args []string) error {
+	for _, n := range taskNames {
+		fmt.Printf("%-12s %-28s %s\n", n.alias, n.taskType, n.zh)
+	}
+	return nil
+}
+
+func runSubmit(args []string) error {
+	flags := newFlagSet("submit")
+	device := flags.String("device", "", "只下发给该设备（默认使用配置中的设备）")
+	params := flags.String("params", "", "任务参数，Settings-* 任务需要")
+	priority := flags.Int("priority", 0, "优先级，越大越先下发")
+	wait := flags.Bool("wait", false, "等待任务完成")
+	timeout := flags.Duration("timeout", 30*time.Minute, "配合 -wait，最长等待时间")
+	args = parseArgs(flags, args)
+	if len(args) != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	taskType, err := resolveType(args[0])
+	if err != nil {
+		return err
+	}
+	c, p, err := connect()
+	if err != nil {
+		return err
+	}
+	if *device == "" {
+		*device = p.Device
+	}
+
+	t, err := c.SubmitTask(ctx, api.SubmitTaskRequest{Type: taskType, Params: *params, Device: *device, Priority: *priority})
+	if err != nil {
+		return err
+	}
+	fmt.Println(t.ID)
+	if t.Status == api.StatusAwaitingApproval {
+		fmt.Fprintln(os.Stderr, "该任务需要另一位用户在控制面板确认后才会下发")
+	}
+	if !*wait {
+		return nil
+	}
+	_, err = waitTask(c, t.ID, *timeout)
+	return err
+}
+
+func runWait(args []string) error {
+	flags := newFlagSet("wait")
+	timeout := flags.Duration("timeout", 30*time.Minute, "最长等待时间")
+	args = parseArgs(flags, args)
+	if len(args) != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	c, _, err := connect()
+	if err != nil {
+		return err
+	}
+	_, err = waitTask(c, args[0], *timeout)
+	return err
+}
+
+// findTask 按 ID 或 tasks 命令显示的 8 位短 ID 查找任务
+func findTask(c *client.Client, id string) (*api.Task, error) {
+	if len(id) >= 36 {
+		return c.GetTask(ctx, id)
+	}
+	if len(id) < 8 {
+		return nil, fmt.Errorf("任务 ID %s 太短，至少需要 8 位", id)
+	}
+	list, err := c.QueryTasks(ctx, api.TaskQuery{ID: id, Limit: 2})
+	if err != nil {
+		return nil, err
+	}
+	switch len(list.Items) {
+	case 0:
+		return nil, fmt.Errorf("任务 %s 不存在", id)
+	case 1:
+		return list.Items[0], nil
+	}
+	return nil, fmt.Errorf("有多个任务以 %s 开头，请输入更长的 ID", id)
+}
+
+// latestScreenshot 返回设备最近一张成功的截图，device 为空表示不限设备
+func latestScreenshot(c *client.Client, device string) (*api.Task, error) {
+	q := api.TaskQuery{
+		Status: []api.Status{api.StatusSuccess},
+		Type:   []string{"CaptureImage", "CaptureImageNow"},
+		Device: device,
+		Limit:  1,
+	}
+	list, err := c.QueryTasks(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Items) == 0 {
+		return nil, errors.New("没有找到截图，可加 -new 先截一张")
+	}
+	return list.Items[0], nil
+}
+
+var spinner = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// waitTask 等待任务结束。终端中在同一行刷新进度，否则只在状态变化时输出一行。
+// 任务失败、被拒绝或超时返回错误，脚本可以据此判断。
+func waitTask(c *client.Client, id string, timeout time.Duration) (*api.Task, error) {
+	t, err := findTask(c, id)
+	if err != nil {
+		return nil, err
+	}
+	tty := isTerminal(os.Stderr)
+	start := time.Now()
+	last := ""
+	for i := 0; ; i++ {
+		status := statusText(t)
+		elapsed := time.Since(start).Round(time.Second)
+		if t.Done() {
+			if tty {
+				fmt.Fprint(os.Stderr, "\r\033[K")
+			}
+			fmt.Fprintf(os.Stderr, "任务 %s（%s）%s，耗时 %s\n", shortID(t.ID), typeName(t.Type), status, elapsed)
+			if t.Status != api.StatusSuccess {
+				return t, fmt.Errorf("任务%s", status)
+			}
+			return t, nil
+		}
+		if tty {
+			fmt.Fprintf(os.Stderr, "\r\033[K%s 任务 %s（%s）%s，已等待 %s", spinner[i%len(spinner)], shortID(t.ID), typeName(t.Type), status, elapsed)
+		} else if status != last {
+			fmt.Fprintf(os.Stderr, "任务 %s %s\n", shortID(t.ID), status)
+		}
+		last = status
+		if elapsed >= timeout {
+			if tty {
+				fmt.Fprintln(os.Stderr)
+			}
+			return t, fmt.Errorf("等待超时（%s），任务仍处于%s状态", timeout, status)
+		}
+		// 服务端最多等 1 秒就返回，以便刷新进度；任务结束时立即返回
+		r, err := c.WaitTask(ctx, t.ID, time.Second)
+		if ctx.Err() != nil {
+			if tty {
+				fmt.Fprintln(os.Stderr)
+			}
+			return t, errors.New("已中断")
+		}
+		if err != nil {
+			return nil, err
+		}
+		t = r.Task
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func runTasks(args []string) error {
+	flags := newFlagSet("tasks")
+	n := flags.Int("n", 20, fmt.Sprintf("显示条数，最多 %d", api.MaxPageSize))
+	device := flags.String("device", "", "只显示该设备的任务")
+	status := flags.String("status", "", "只显示这些状态的任务，如 PENDING,FAILED")
+	parseArgs(flags, args)
+	q, err := api.ParseTaskQuery(url.Values{"status": {*status}, "limit": {strconv.Itoa(*n)}})
+	if err != nil {
+		return err
+	}
+	q.Device = *device
+	c, _, err := connect()
+	if err != nil {
+		return err
+	}
+	list, err := c.QueryTasks(ctx, q)
+	if err != nil {
+		return err
+	}
+	for _, t := range list.Items {
+		printTask(t)
+	}
+	return nil
+}
+
+// runQueue 列出尚未下发的任务；指定任务 ID 和方向时先调整顺序
+func runQueue(args []string) error {
+	flags := newFlagSet("queue")
+	args = parseArgs(flags, args)
+	if len(args) != 0 && len(args) != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if len(args) == 2 {
+		switch api.Move(args[1]) {
+		case api.MoveUp, api.MoveDown, api.MoveFront:
+		default:
+			return fmt.Errorf("方向应为 up、down 或 front，而不是 %q", args[1])
+		}
+	}
+	c, _, err := connect()
+	if err != nil {
+		return err
+	}
+	queue, err := c.Queue(ctx)
+	if err != nil {
+		return err
+	}
+	if len(args) == 2 {
+		id := args[0]
+		for _, t := range queue {
+			if len(id) >= 8 && strings.HasPrefix(t.ID, id) {
+				id = t.ID
+			}
+		}
+		if queue, err = c.MoveTask(ctx, id, api.Move(args[1])); err != nil {
+			return err
+		}
+	}
+	for _, t := range queue {
+		printTask(t)
+	}
+	return nil
+}
+
+func printTask(t *api.Task) {
+	device := t.Device
+	if device == "" {
+		device = "-"
+	}
+	fmt.Printf("%s  %s  %-8s %-6s %s\n", t.CreatedAt.Local().Format("01-02 15:04:05"), shortID(t.ID), typeName(t.Type), statusText(t), device)
+}
+
+// runTail 持续输出新任务、状态变化和新告警，按 Ctrl-C 退出
+func runTail(args []string) error {
+	flags := newFlagSet("tail")
+	interval := flags.Duration("interval", 2*time.Second, "轮询间隔")
+	parseArgs(flags, args)
+	c, _, err := connect()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(os.Stderr, "正在监视任务变化，按 Ctrl-C 退出")
+	statuses := make(map[string]string)
+	err = c.StreamEvents(ctx, *interval, func(e client.Event) error {
+		switch e.Type {
+		case client.EventTaskCreated:
+			fmt.Printf("%s  新任务  ", e.Time.Format("15:04:05"))
+			printTask(e.Task)
+		case client.EventTaskUpdated:
+			// 只有画面标签变化时状态文字不变，不输出
+			if prev := statuses[e.Task.ID]; prev != statusText(e.Task) {
+				if prev != "" {
+					fmt.Printf("%s  %s → ", e.Time.Format("15:04:05"), prev)
+				} else {
+					fmt.Printf("%s  ", e.Time.Format("15:04:05"))
+				}
+				printTask(e.Task)
+			}
+		case client.EventAlert:
+			a := e.Alert
+			stopped := ""
+			if a.StopQueued {
+				stopped = "，已自动停止"
+			}
+			fmt.Printf("%s  卡死告警  设备 %s 的任务 %s（%s）连续 %d 张截图无变化%s\n",
+				a.CreatedAt.Local().Format("15:04:05"), a.Device, shortID(a.TaskID), typeName(a.TaskType), a.Frames, stopped)
+		}
+		if e.Task != nil {
+			statuses[e.Task.ID] = statusText(e.Task)
+		}
+		return nil
+	})
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+func runDevices(args []string) error {
+	c, _, err := connect()
+	if err != nil {
+		return err
+	}
+	devices, err := c.ListDevices(ctx)
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		fmt.Println("服务启动以来还没有设备轮询过")
+		return nil
+	}
+	for _, d := range devices {
+		ago := time.Since(d.LastSeen).Round(time.Second)
+		fmt.Printf("%-40s %-20s %s 前\n", d.ID, d.User, ago)
+	}
+	return nil
+}
+
+func runScreenshot(args []string) error {
+	flags := newFlagSet("screenshot")
+	device := flags.String("device", "", "设备（默认使用配置中的设备）")
+	fresh := flags.Bool("new", false, "先下发立刻截图任务，等截图完成后下载")
+	out := flags.String("o", "", "保存的文件名（默认 screenshot_<时间>_<ID>.png，- 表示标准输出）")
+	timeout := flags.Duration("timeout", 2*time.Minute, "配合 -new，最长等待时间")
+	parseArgs(flags, args)
+	c, p, err := connect()
+	if err != nil {
+		return err
+	}
+	if *device == "" {
+		*device = p.Device
+	}
+
+	var shot *api.Task
+	if *fresh {
+		t, err := c.SubmitTask(ctx, api.SubmitTaskRequest{Type: "CaptureImageNow", Device: *device})
+		if err != nil {
+			return err
+		}
+		if t.Status == api.StatusAwaitingApproval {
+			return errors.New("截图任务需要确认，无法自动等待")
+		}
+		if shot, err = waitTask(c, t.ID, *timeout); err != nil {
+			return err
+		}
+	} else if shot, err = latestScreenshot(c, *device); err != nil {
+		return err
+	}
+
+	if *out == "-" {
+		return c.GetScreenshot(ctx, shot.ID, os.Stdout)
+	}
+	name := *out
+	if name == "" {
+		name = fmt.Sprintf("screenshot_%s_%s.png", shot.DoneAt.Local().Format("20060102_150405"), shortID(shot.ID))
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = c.GetScreenshot(ctx, shot.ID, f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name)
+		return err
+	}
+	fmt.Println(name)
+	return nil
+}
@@ -0,0 +1,448 @@
+// Package config 读取 YAML 配置文件，并用环境变量覆盖其中的设置。
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Listen        string   `yaml:"listen"`         // 监听地址，如 :8080、127.0.0.1:8080
+	DataDir       string   `yaml:"data_dir"`       // 数据目录，任务、账号等文件都保存在这里
+	ScreenshotDir string   `yaml:"screenshot_dir"` // 截图目录，相对路径相对于 data_dir
+	GinMode       string   `yaml:"gin_mode"`       // debug、release 或 test，留空沿用 Gin 的默认行为
+	Auth          Auth     `yaml:"auth"`
+	Limits        Limits   `yaml:"limits"`
+	TLS           TLS      `yaml:"tls"`
+	Access        Access   `yaml:"access"`
+	Confirm       Confirm  `yaml:"confirm"`
+	Watchdog      Watchdog `yaml:"watchdog"`
+}
+
+type Auth struct {
+	AdminPassword      string   `yaml:"admin_password"` // 首次启动时初始管理员的密码
+	AdminToken         string   `yaml:"admin_token"`    // 兼容旧版的 Bearer 令牌，等同管理员
+	SessionTTL         Duration `yaml:"session_ttl"`
+	TrustedProxies     []string `yaml:"trusted_proxies"`
+	LoginMaxFailures   int      `yaml:"login_max_failures"`   // 同一 IP 连续登录失败多少次后锁定
+	LoginLockout       Duration `yaml:"login_lockout"`        // 锁定时长
+	RequireDeviceToken bool     `yaml:"require_device_token"` // 拒绝所有不带设备令牌的 MAA 请求
+}
+
+type Limits struct {
+	MaxBody       ByteSize `yaml:"max_body"`        // 除汇报端点外的请求体上限
+	MaxReportBody ByteSize `yaml:"max_report_body"` // /maa/reportStatus 的请求体上限，截图较大
+	RateMAA       float64  `yaml:"rate_maa"`
+	RateAdmin     float64  `yaml:"rate_admin"`
+}
+
+type TLS struct {
+	Cert         string   `yaml:"cert"` // 证书和私钥文件（PEM），两者须同时设置，相对路径相对于 data_dir
+	Key          string   `yaml:"key"`
+	SelfSigned   bool     `yaml:"self_signed"`   // 未指定证书时生成自签 CA 和服务器证书
+	Hosts        []string `yaml:"hosts"`         // 自签证书额外包含的域名或 IP
+	RedirectPort int      `yaml:"redirect_port"` // 在该端口监听 HTTP 并跳转到 HTTPS，0 表示不启用
+}
+
+// Enabled 判断是否启用 HTTPS
+func (t TLS) Enabled() bool {
+	return t.Cert != "" || t.SelfSigned
+}
+
+// Access 按端点分组的 IP 访问控制，规则为 CIDR 或单个 IP
+type Access struct {
+	Admin     IPRules `yaml:"admin"`     // /admin/*、/api/v1/* 和 OpenAPI 文档
+	Dashboard IPRules `yaml:"dashboard"` // 控制面板、登录页和 /static/*
+	MAA       IPRules `yaml:"maa"`       // /maa/*
+}
+
+// IPRules 命中 deny 的请求一律拒绝；allow 非空时只放行命中 allow 的请求
+type IPRules struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+}
+
+type Confirm struct {
+	Types     []string `yaml:"types"`      // 需要确认的任务类型，末尾 * 表示前缀匹配，留空关闭
+	SelfDelay Duration `yaml:"self_delay"` // 提交者本人至少等待多久才能自己确认，0 表示必须由他人确认
+}
+
+type Watchdog struct {
+	Interval  Duration `yaml:"interval"`  // 截图间隔，0 表示不启用
+	Frames    int      `yaml:"frames"`    // 连续多少帧几乎相同视为卡死
+	Threshold int      `yaml:"threshold"` // 感知哈希的最大汉明距离
+	Stop      bool     `yaml:"stop"`      // 检测到卡死后自动下发 StopTask
+}
+
+// Default 返回默认配置
+func Default() *Config {
+	return &Config{
+		Listen:        ":8080",
+		ScreenshotDir: "screenshots",
+		Auth: Auth{
+			SessionTTL:       Duration(7 * 24 * time.Hour),
+			LoginMaxFailures: 5,
+			LoginLockout:     Duration(15 * time.Minute),
+		},
+		Limits: Limits{
+			MaxBody:       16 << 20,
+			MaxReportBody: 100 << 20,
+			RateMAA:       5,
+			RateAdmin:     10,
+		},
+		Confirm: Confirm{
+			Types:     []string{"Toolbox-Gacha*", "Settings-*"},
+			SelfDelay: Duration(5 * time.Minute),
+		},
+		Watchdog: Watchdog{
+			Frames:    3,
+			Threshold: 5,
+		},
+	}
+}
+
+// Load 读取配置文件（path 为空时只使用默认值），再用环境变量覆盖并校验。
+// 所有问题会一次性列出，方便对照修改。
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取配置文件失败: %w", err)
+		}
+		defer f.Close()
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s 格式错误: %w", path, err)
+		}
+	}
+
+	var problems []string
+	problems = append(problems, cfg.applyEnv()...)
+	problems = append(problems, cfg.validate()...)
+	if len(problems) > 0 {
+		name := path
+		if name == "" {
+			name = "环境变量"
+		}
+		return nil, fmt.Errorf("配置无效（%s）:\n  - %s", name, strings.Join(problems, "\n  - "))
+	}
+	return cfg, nil
+}
+
+// applyEnv 用环境变量覆盖配置，返回无法解析的变量
+func (c *Config) applyEnv() []string {
+	var problems []string
+	str := func(name string, dst *string) {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	parse := func(name string, set func(string) error) {
+		if v := os.Getenv(name); v != "" {
+			if err := set(v); err != nil {
+				problems = append(problems, fmt.Sprintf("环境变量 %s: %v", name, err))
+			}
+		}
+	}
+	integer := func(name string, dst *int) {
+		parse(name, func(v string) (err error) {
+			if *dst, err = strconv.Atoi(v); err != nil {
+				return fmt.Errorf("%q 不是整数", v)
+			}
+			return nil
+		})
+	}
+	boolean := func(name string, dst *bool) {
+		parse(name, func(v string) (err error) {
+			if *dst, err = strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("%q 无效，应为 1 或 0", v)
+			}
+			return nil
+		})
+	}
+	list := func(name string, dst *[]string) {
+		parse(name, func(v string) error {
+			*dst = splitList(v)
+			return nil
+		})
+	}
+
+	if port := os.Getenv("PORT"); port != "" {
+		c.Listen = ":" + port
+	}
+	str("LISTEN_ADDR", &c.Listen)
+	str("DATA_DIR", &c.DataDir)
+	str("SCREENSHOT_DIR", &c.ScreenshotDir)
+	str("GIN_MODE", &c.GinMode)
+	str("ADMIN_PASSWORD", &c.Auth.AdminPassword)
+	str("ADMIN_TOKEN", &c.Auth.AdminToken)
+	parse("SESSION_TTL", c.Auth.SessionTTL.set)
+	list("TRUSTED_PROXIES", &c.Auth.TrustedProxies)
+	integer("LOGIN_MAX_FAILURES", &c.Auth.LoginMaxFailures)
+	parse("LOGIN_LOCKOUT", c.Auth.LoginLockout.set)
+	boolean("MAA_REQUIRE_TOKEN", &c.Auth.RequireDeviceToken)
+	parse("MAX_BODY", c.Limits.MaxBody.set)
+	parse("MAX_REPORT_BODY", c.Limits.MaxReportBody.set)
+	parse("RATE_LIMIT_MAA", func(v string) (err error) {
+		c.Limits.RateMAA, err = strconv.ParseFloat(v, 64)
+		return err
+	})
+	parse("RATE_LIMIT_ADMIN", func(v string) (err error) {
+		c.Limits.RateAdmin, err = strconv.ParseFloat(v, 64)
+		return err
+	})
+	str("TLS_CERT", &c.TLS.Cert)
+	str("TLS_KEY", &c.TLS.Key)
+	boolean("TLS_SELF_SIGNED", &c.TLS.SelfSigned)
+	list("TLS_HOSTS", &c.TLS.Hosts)
+	integer("HTTP_REDIRECT_PORT", &c.TLS.RedirectPort)
+	list("ADMIN_ALLOW", &c.Access.Admin.Allow)
+	list("ADMIN_DENY", &c.Access.Admin.Deny)
+	list("DASHBOARD_ALLOW", &c.Access.Dashboard.Allow)
+	list("DASHBOARD_DENY", &c.Access.Dashboard.Deny)
+	list("MAA_ALLOW", &c.Access.MAA.Allow)
+	list("MAA_DENY", &c.Access.MAA.Deny)
+	// 设为空字符串表示关闭确认，所以不能用 list
+	if v, ok := os.LookupEnv("CONFIRM_TYPES"); ok {
+		c.Confirm.Types = splitList(v)
+	}
+	parse("CONFIRM_SELF_DELAY", c.Confirm.SelfDelay.set)
+	parse("WATCHDOG_INTERVAL", c.Watchdog.Interval.set)
+	integer("WATCHDOG_FRAMES", &c.Watchdog.Frames)
+	integer("WATCHDOG_THRESHOLD", &c.Watchdog.Threshold)
+	boolean("WATCHDOG_STOP", &c.Watchdog.Stop)
+	return problems
+}
+
+func (c *Config) validate() []string {
+	var problems []string
+	add := func(field, format string, args ...any) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+
+	if _, port, err := net.SplitHostPort(c.Listen); err != nil {
+		add("listen", "%q 不是有效的监听地址，应如 :8080 或 127.0.0.1:8080", c.Listen)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		add("listen", "端口 %q 无效，应在 1-65535 之间", port)
+	}
+	if c.DataDir != "" {
+		if info, err := os.Stat(c.DataDir); err == nil && !info.IsDir() {
+			add("data_dir", "%q 不是目录", c.DataDir)
+		}
+	}
+	if c.ScreenshotDir == "" {
+		add("screenshot_dir", "不能为空")
+	}
+	switch c.GinMode {
+	case "", "debug", "release", "test":
+	default:
+		add("gin_mode", "%q 无效，应为 debug、release 或 test", c.GinMode)
+	}
+	if c.Auth.AdminPassword != "" && len(c.Auth.AdminPassword) < 8 {
+		add("auth.admin_password", "至少 8 位")
+	}
+	if c.Auth.SessionTTL <= 0 {
+		add("auth.session_ttl", "必须大于 0，如 168h")
+	}
+	ipList := func(field string, items []string) {
+		for _, p := range items {
+			if net.ParseIP(p) == nil {
+				if _, _, err := net.ParseCIDR(p); err != nil {
+					add(field, "%q 不是 IP 或 CIDR", p)
+				}
+			}
+		}
+	}
+	ipList("auth.trusted_proxies", c.Auth.TrustedProxies)
+	if c.Auth.LoginMaxFailures < 1 {
+		add("auth.login_max_failures", "至少为 1")
+	}
+	if c.Auth.LoginLockout <= 0 {
+		add("auth.login_lockout", "必须大于 0，如 15m")
+	}
+	if c.Limits.MaxBody < 1<<10 {
+		add("limits.max_body", "至少 1KB")
+	}
+	if c.Limits.MaxReportBody < 1<<10 {
+		add("limits.max_report_body", "至少 1KB")
+	}
+	if c.Limits.RateMAA < 0 {
+		add("limits.rate_maa", "不能为负数（0 表示不限流）")
+	}
+	if c.Limits.RateAdmin < 0 {
+		add("limits.rate_admin", "不能为负数（0 表示不限流）")
+	}
+	if (c.TLS.Cert == "") != (c.TLS.Key == "") {
+		add("tls", "cert 和 key 必须同时设置")
+	}
+	if c.TLS.RedirectPort != 0 {
+		if c.TLS.RedirectPort < 1 || c.TLS.RedirectPort > 65535 {
+			add("tls.redirect_port", "%d 无效，应在 1-65535 之间", c.TLS.RedirectPort)
+		} else if !c.TLS.Enabled() {
+			add("tls.redirect_port", "只在启用 HTTPS（cert/key 或 self_signed）时有效")
+		}
+	}
+	ipList("access.admin.allow", c.Access.Admin.Allow)
+	ipList("access.admin.deny", c.Access.Admin.Deny)
+	ipList("access.dashboard.allow", c.Access.Dashboard.Allow)
+	ipList("access.dashboard.deny", c.Access.Dashboard.Deny)
+	ipList("access.maa.allow", c.Access.MAA.Allow)
+	ipList("access.maa.deny", c.Access.MAA.Deny)
+	for _, t := range c.Confirm.Types {
+		if t == "" || t == "*" {
+			add("confirm.types", "%q 无效，应为任务类型，末尾 * 表示前缀匹配", t)
+		}
+	}
+	if c.Confirm.SelfDelay < 0 {
+		add("confirm.self_delay", "不能为负数（0 表示必须由他人确认）")
+	}
+	if c.Watchdog.Interval < 0 {
+		add("watchdog.interval", "不能为负数（0 表示不启用）")
+	}
+	if c.Watchdog.Frames < 2 {
+		add("watchdog.frames", "至少为 2")
+	}
+	if c.Watchdog.Threshold < 0 || c.Watchdog.Threshold > 64 {
+		add("watchdog.threshold", "应在 0-64 之间")
+	}
+	return problems
+}
+
+// Restart 返回 next 相对 c 修改了、但必须重启才能生效的设置
+func (c *Config) Restart(next *Config) []string {
+	var changed []string
+	if c.Listen != next.Listen {
+		changed = append(changed, "listen")
+	}
+	if c.DataDir != next.DataDir {
+		changed = append(changed, "data_dir")
+	}
+	if c.ScreenshotDir != next.ScreenshotDir {
+		changed = append(changed, "screenshot_dir")
+	}
+	if c.GinMode != next.GinMode {
+		changed = append(changed, "gin_mode")
+	}
+	if c.Auth.SessionTTL != next.Auth.SessionTTL {
+		changed = append(changed, "auth.session_ttl")
+	}
+	if strings.Join(c.Auth.TrustedProxies, ",") != strings.Join(next.Auth.TrustedProxies, ",") {
+		changed = append(changed, "auth.trusted_proxies")
+	}
+	if c.TLS.Cert != next.TLS.Cert || c.TLS.Key != next.TLS.Key || c.TLS.SelfSigned != next.TLS.SelfSigned ||
+		strings.Join(c.TLS.Hosts, ",") != strings.Join(next.TLS.Hosts, ",") || c.TLS.RedirectPort != next.TLS.RedirectPort {
+		changed = append(changed, "tls")
+	}
+	if c.Watchdog.Interval != next.Watchdog.Interval {
+		changed = append(changed, "watchdog.interval")
+	}
+	return changed
+}
+
+// Resolve 把相对路径解析为 data_dir 中的路径，绝对路径和空字符串原样返回
+func (c *Config) Resolve(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(c.DataDir, p)
+}
+
+// Path 返回配置文件路径：优先使用 flagValue，其次 MAA_CONFIG 环境变量，
+// 都未指定时若当前目录存在 config.yaml 则使用它，否则返回空字符串。
+func Path(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if v := os.Getenv("MAA_CONFIG"); v != "" {
+		return v
+	}
+	if _, err := os.Stat("config.yaml"); err == nil {
+		return "config.yaml"
+	}
+	return ""
+}
+
+func splitList(v string) []string {
+	var result []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Duration 是可以写成 168h、30m 的时长
+type Duration time.Duration
+
+func (d *Duration) set(v string) error {
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%q 不是有效的时长，应如 30m、168h", v)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	if err := d.set(node.Value); err != nil {
+		return fmt.Errorf("第 %d 行: %w", node.Line, err)
+	}
+	return nil
+}
+
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+// ByteSize 是可以写成 512KB、64MB 的字节数
+type ByteSize int64
+
+var byteUnits = []struct {
+	suffix string
+	size   int64
+}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+func (b *ByteSize) set(v string) error {
+	s := strings.ToUpper(strings.TrimSpace(v))
+	mult := int64(1)
+	for _, u := range byteUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, mult = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return fmt.Errorf("%q 不是有效的大小，应如 512KB、64MB", v)
+	}
+	*b = ByteSize(n * mult)
+	return nil
+}
+
+func (b *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	if err := b.set(node.Value); err != nil {
+		return fmt.Errorf("第 %d 行: %w", node.Line, err)
+	}
+	return nil
+}
+
+func (b ByteSize) MarshalYAML() (any, error) {
+	for _, u := range byteUnits[:3] {
+		if b > 0 && int64(b)%u.size == 0 {
+			return strconv.FormatInt(int64(b)/u.size, 10) + u.suffix, nil
+		}
+	}
+	return int64(b), nil
+}
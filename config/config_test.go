@@ -0,0 +1,171 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultValid(t *testing.T) {
+	if problems := Default().validate(); len(problems) > 0 {
+		t.Fatalf("默认配置无效: %v", problems)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	cfg, err := Load(writeConfig(t, `
+auth:
+  login_max_failures: 3
+  login_lockout: 1h
+  require_device_token: true
+tls:
+  self_signed: true
+  hosts: [maa.example.com]
+  redirect_port: 8081
+access:
+  admin:
+    allow: [192.168.0.0/16, 10.0.0.1]
+confirm:
+  types: []
+  self_delay: 0s
+watchdog:
+  interval: 2m
+  frames: 4
+  stop: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Auth.LoginMaxFailures != 3 || time.Duration(cfg.Auth.LoginLockout) != time.Hour || !cfg.Auth.RequireDeviceToken {
+		t.Errorf("auth = %+v", cfg.Auth)
+	}
+	if !cfg.TLS.Enabled() || cfg.TLS.RedirectPort != 8081 || len(cfg.TLS.Hosts) != 1 {
+		t.Errorf("tls = %+v", cfg.TLS)
+	}
+	if len(cfg.Access.Admin.Allow) != 2 || cfg.Access.MAA.Allow != nil {
+		t.Errorf("access = %+v", cfg.Access)
+	}
+	// 空列表关闭确认，不能退回默认值
+	if len(cfg.Confirm.Types) != 0 || cfg.Confirm.SelfDelay != 0 {
+		t.Errorf("confirm = %+v", cfg.Confirm)
+	}
+	if time.Duration(cfg.Watchdog.Interval) != 2*time.Minute || cfg.Watchdog.Frames != 4 || cfg.Watchdog.Threshold != 5 || !cfg.Watchdog.Stop {
+		t.Errorf("watchdog = %+v", cfg.Watchdog)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("LOGIN_MAX_FAILURES", "7")
+	t.Setenv("MAA_REQUIRE_TOKEN", "1")
+	t.Setenv("TLS_CERT", "cert.pem")
+	t.Setenv("TLS_KEY", "key.pem")
+	t.Setenv("HTTP_REDIRECT_PORT", "80")
+	t.Setenv("MAA_DENY", "10.0.0.0/8, 192.168.1.1")
+	t.Setenv("CONFIRM_TYPES", "")
+	t.Setenv("WATCHDOG_INTERVAL", "30s")
+	t.Setenv("WATCHDOG_STOP", "true")
+
+	cfg, err := Load(writeConfig(t, "confirm:\n  types: [Toolbox-Gacha*]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Auth.LoginMaxFailures != 7 || !cfg.Auth.RequireDeviceToken {
+		t.Errorf("auth = %+v", cfg.Auth)
+	}
+	if cfg.TLS.Cert != "cert.pem" || cfg.TLS.Key != "key.pem" || cfg.TLS.RedirectPort != 80 {
+		t.Errorf("tls = %+v", cfg.TLS)
+	}
+	if strings.Join(cfg.Access.MAA.Deny, ",") != "10.0.0.0/8,192.168.1.1" {
+		t.Errorf("access.maa.deny = %v", cfg.Access.MAA.Deny)
+	}
+	// 环境变量设为空字符串同样关闭确认，并覆盖配置文件
+	if len(cfg.Confirm.Types) != 0 {
+		t.Errorf("confirm.types = %v", cfg.Confirm.Types)
+	}
+	if time.Duration(cfg.Watchdog.Interval) != 30*time.Second || !cfg.Watchdog.Stop {
+		t.Errorf("watchdog = %+v", cfg.Watchdog)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	t.Setenv("WATCHDOG_STOP", "maybe")
+	t.Setenv("LOGIN_LOCKOUT", "soon")
+
+	_, err := Load(writeConfig(t, `
+listen: "8080"
+auth:
+  login_max_failures: 0
+tls:
+  cert: cert.pem
+  redirect_port: 70000
+access:
+  maa:
+    allow: [192.168.0.0/33]
+  dashboard:
+    deny: [localhost]
+confirm:
+  types: ["*"]
+  self_delay: -1m
+watchdog:
+  interval: -1s
+  frames: 1
+  threshold: 65
+`))
+	if err == nil {
+		t.Fatal("无效配置加载成功")
+	}
+	// 所有问题一次性列出
+	for _, want := range []string{
+		"WATCHDOG_STOP", "LOGIN_LOCKOUT", "listen", "auth.login_max_failures", "tls: cert 和 key",
+		"tls.redirect_port", "access.maa.allow", "access.dashboard.deny", "confirm.types",
+		"confirm.self_delay", "watchdog.interval", "watchdog.frames", "watchdog.threshold",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("错误信息缺少 %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestRedirectPortRequiresTLS(t *testing.T) {
+	cfg := Default()
+	cfg.TLS.RedirectPort = 80
+	if problems := cfg.validate(); len(problems) != 1 || !strings.HasPrefix(problems[0], "tls.redirect_port") {
+		t.Errorf("未启用 HTTPS 时设置 redirect_port: %v", problems)
+	}
+}
+
+func TestRestart(t *testing.T) {
+	cur := Default()
+	next := Default()
+	next.Limits.RateMAA = 1
+	next.Confirm.Types = nil
+	next.Access.Admin.Allow = []string{"10.0.0.0/8"}
+	next.Watchdog.Frames = 5
+	if changed := cur.Restart(next); len(changed) != 0 {
+		t.Errorf("可热更新的设置被要求重启: %v", changed)
+	}
+
+	next.ScreenshotDir = "shots"
+	next.TLS.SelfSigned = true
+	next.Watchdog.Interval = Duration(time.Minute)
+	if got := strings.Join(cur.Restart(next), ","); got != "screenshot_dir,tls,watchdog.interval" {
+		t.Errorf("Restart = %s", got)
+	}
+}
+
+func TestExampleConfig(t *testing.T) {
+	if _, err := Load("../config.example.yaml"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"ArknightsMaaRemoter/config"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 清理与检查 ────────────────────────────────────────────────
+
+func runPrune(args []string) error {
+	flags, configFlag := newFlagSet("prune", "")
+	days := flags.Int("days", 0, "保留最近 N 天内结束的任务，更早的任务及其截图会被删除（必填）")
+	orphans := flags.Bool("orphans", false, "同时删除截图目录中没有对应任务的截图")
+	dryRun := flags.Bool("dry-run", false, "只列出将要删除的内容，不实际删除")
+	force := flags.Bool("force", false, "不检查服务是否正在运行")
+	_ = flags.Parse(args)
+	if *days <= 0 {
+		return fmt.Errorf("请用 -days 指定保留天数，如 -days 30")
+	}
+	cfg, _, err := openDataDir(*configFlag, false)
+	if err != nil {
+		return err
+	}
+	if !*dryRun && !*force {
+		if err := ensureStopped(cfg); err != nil {
+			return err
+		}
+	}
+
+	s := store.New(cfg.DataDir)
+	before := time.Now().AddDate(0, 0, -*days)
+	var expired []*store.Task
+	if *dryRun {
+		for _, t := range s.All() {
+			if t.FinishedBefore(before) {
+				expired = append(expired, t)
+			}
+		}
+	} else {
+		expired = s.Prune(before)
+	}
+
+	var files []string
+	for _, t := range expired {
+		if file := cfg.Resolve(t.ScreenshotFile()); file != "" {
+			files = append(files, file)
+		}
+	}
+	if *orphans {
+		// 过期任务的截图已在上面列出，不再算作孤立文件
+		extra, err := orphanScreenshots(cfg.Resolve(cfg.ScreenshotDir), screenshotRefs(cfg, append(s.All(), expired...)))
+		if err != nil {
+			return err
+		}
+		files = append(files, extra...)
+	}
+
+	verb := "已删除"
+	if *dryRun {
+		verb = "将删除"
+		for _, file := range files {
+			fmt.Println(file)
+		}
+	} else {
+		for _, file := range files {
+			if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+				fmt.Printf("删除 %s 失败: %v\n", file, err)
+			}
+		}
+	}
+	fmt.Printf("%s %d 个 %s 之前结束的任务、%d 张截图\n", verb, len(expired), before.Format("2006-01-02 15:04"), len(files))
+	return nil
+}
+
+// doctor 收集检查结果，problem 为需要处理的问题，note 只是提示
+type doctor struct {
+	problems int
+}
+
+func (d *doctor) ok(format string, args ...any) {
+	fmt.Printf("[正常] "+format+"\n", args...)
+}
+
+func (d *doctor) problem(format string, args ...any) {
+	d.problems++
+	fmt.Printf("[问题] "+format+"\n", args...)
+}
+
+func (d *doctor) note(format string, args ...any) {
+	fmt.Printf("[提示] "+format+"\n", args...)
+}
+
+func runDoctor(args []string) error {
+	flags, configFlag := newFlagSet("doctor", "")
+	_ = flags.Parse(args)
+	cfg, _, err := openDataDir(*configFlag, false)
+	if err != nil {
+		return err
+	}
+	d := &doctor{}
+	dir, _ := filepath.Abs(cfg.DataDir)
+	d.checkWritable("数据目录", dir)
+	screenshotDir := cfg.Resolve(cfg.ScreenshotDir)
+	if _, err := os.Stat(screenshotDir); err == nil {
+		d.checkWritable("截图目录", screenshotDir)
+	}
+	d.checkPermissions(cfg)
+
+	problems := store.CheckFiles(cfg.DataDir)
+	s := store.New(cfg.DataDir)
+	problems = append(problems, s.Check()...)
+	for _, p := range problems {
+		d.problem("%s", p)
+	}
+	if len(problems) == 0 {
+		d.ok("数据文件可以正常解析")
+	}
+
+	tasks := s.All()
+	dangling := 0
+	for _, t := range tasks {
+		if file := t.ScreenshotFile(); file != "" {
+			if _, err := os.Stat(cfg.Resolve(file)); err != nil {
+				dangling++
+				d.problem("任务 %s 的截图 %s 不存在", t.ID, file)
+			}
+		}
+	}
+	for _, ref := range store.NewReferences(cfg.DataDir).All() {
+		if _, err := os.Stat(cfg.Resolve(ref.File)); err != nil {
+			dangling++
+			d.problem("参考图 %s（%s）的文件 %s 不存在", ref.ID, ref.Label, ref.File)
+		}
+	}
+	if dangling == 0 {
+		d.ok("任务截图和参考图的路径都有效")
+	}
+	if orphans, err := orphanScreenshots(screenshotDir, screenshotRefs(cfg, tasks)); err != nil {
+		d.problem("读取截图目录失败: %v", err)
+	} else if len(orphans) > 0 {
+		d.note("截图目录中有 %d 个文件没有对应的任务，可用 prune -orphans 清理", len(orphans))
+	}
+
+	if d.problems > 0 {
+		return fmt.Errorf("发现 %d 个问题", d.problems)
+	}
+	fmt.Println("未发现问题")
+	return nil
+}
+
+// checkWritable 检查目录是否存在且可写
+func (d *doctor) checkWritable(label, dir string) {
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		d.problem("%s %s 不可写: %v", label, dir, err)
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+	d.ok("%s %s 可写", label, dir)
+}
+
+// checkPermissions 检查含密钥的文件是否只对运行用户可读。Windows 不使用 Unix 权限位，跳过。
+func (d *doctor) checkPermissions(cfg *config.Config) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	var files []string
+	for _, f := range store.Files() {
+		if f.Secret {
+			files = append(files, cfg.Resolve(f.Name))
+		}
+	}
+	// share.key 是截图分享链接的签名密钥，tls/*.key 是自签证书的私钥
+	files = append(files, cfg.Resolve("share.key"))
+	keys, _ := filepath.Glob(filepath.Join(cfg.Resolve(tlsDir), "*.key"))
+	files = append(files, keys...)
+
+	loose := 0
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+		if perm := info.Mode().Perm(); perm&0077 != 0 {
+			loose++
+			d.problem("%s 的权限为 %04o，其他用户可读，建议执行 chmod 600 %s", file, perm, file)
+		}
+	}
+	if loose == 0 {
+		d.ok("含密钥的文件只对当前用户可读")
+	}
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── API 密钥 ─────────────────────────────────────────────────
+
+type createKeyReq struct {
+	Name      string        `json:"name"`
+	Scopes    []store.Scope `json:"scopes"`
+	Types     []string      `json:"types"`
+	Devices   []string      `json:"devices"`
+	ExpiresIn string        `json:"expires_in"` // 有效期，如 720h；为空表示永不过期
+}
+
+type createKeyResp struct {
+	Key  string        `json:"key"` // 明文密钥，只返回这一次
+	Info *store.APIKey `json:"info"`
+}
+
+// ListKeys 返回所有 API 密钥及其最后使用时间
+func (h *Handler) ListKeys(c *gin.Context) {
+	c.JSON(http.StatusOK, h.keys.List())
+}
+
+// CreateKey 创建 API 密钥，明文密钥只在响应中出现这一次
+func (h *Handler) CreateKey(c *gin.Context) {
+	var req createKeyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	key := store.APIKey{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		Types:     req.Types,
+		Devices:   req.Devices,
+		CreatedBy: currentUser(c).Username,
+	}
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in 无效，应为正的时长，如 720h"})
+			return
+		}
+		exp := time.Now().Add(d)
+		key.ExpiresAt = &exp
+	}
+
+	created, secret, err := h.keys.Create(key)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "key.create", created.ID, map[string]any{
+		"name": created.Name, "scopes": created.Scopes, "types": created.Types, "devices": created.Devices,
+	})
+	c.JSON(http.StatusOK, createKeyResp{Key: secret, Info: created})
+}
+
+// DeleteKey 吊销 API 密钥
+func (h *Handler) DeleteKey(c *gin.Context) {
+	if !h.keys.Delete(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	h.audit(c, "key.delete", c.Param("id"), nil)
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// ListDevices 返回本次启动以来轮询过的 MAA 设备
+func (h *Handler) ListDevices(c *gin.Context) {
+	result := make([]store.Device, 0)
+	for _, d := range h.store.Devices() {
+		if deviceAllowed(c, d.ID) {
+			result = append(result, d)
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ArknightsMaaRemoter/store"
+)
+
+// serveKey 以 Bearer API 密钥发送请求
+func serveKey(r http.Handler, secret, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+secret)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func createKey(t *testing.T, h *Handler, key store.APIKey) string {
+	t.Helper()
+	key.Name = "test"
+	_, secret, err := h.keys.Create(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	read := createKey(t, h, store.APIKey{Scopes: []store.Scope{store.ScopeTasksRead}})
+	submit := createKey(t, h, store.APIKey{Scopes: []store.Scope{store.ScopeTasksSubmit}, Types: []string{"LinkStart*"}})
+	dev1 := createKey(t, h, store.APIKey{Scopes: []store.Scope{store.ScopeTasksSubmit, store.ScopeTasksRead}, Types: []string{"*"}, Devices: []string{"dev1"}})
+	other := h.store.AddFor("dev2", "LinkStart", "")
+
+	for _, c := range []struct {
+		name, key, method, path, body string
+		want                          int
+	}{
+		{"只读密钥查看队列", read, "GET", "/admin/queue", "", http.StatusOK},
+		{"只读密钥下发", read, "POST", "/admin/task", `{"type":"LinkStart"}`, http.StatusForbidden},
+		{"下发密钥查看队列", submit, "GET", "/admin/queue", "", http.StatusForbidden},
+		{"下发允许的类型", submit, "POST", "/admin/task", `{"type":"LinkStart-Base"}`, http.StatusOK},
+		{"下发不允许的类型", submit, "POST", "/admin/task", `{"type":"CaptureImage"}`, http.StatusForbidden},
+		{"下发给其他设备", dev1, "POST", "/admin/task", `{"type":"LinkStart","device":"dev2"}`, http.StatusForbidden},
+		{"等待其他设备的任务", dev1, "GET", "/admin/tasks/" + other.ID + "/wait?timeout=0", "", http.StatusNotFound},
+		{"密钥确认任务", dev1, "POST", "/admin/task/" + other.ID + "/approve", "", http.StatusForbidden},
+		{"密钥管理用户", dev1, "POST", "/admin/users", `{"username":"eve","password":"password1","role":"admin"}`, http.StatusForbidden},
+		{"错误的密钥", "maa_wrong", "GET", "/admin/queue", "", http.StatusUnauthorized},
+	} {
+		if w := serveKey(r, c.key, c.method, c.path, c.body); w.Code != c.want {
+			t.Errorf("%s: 返回 %d，期望 %d: %s", c.name, w.Code, c.want, w.Body)
+		}
+	}
+
+	// 只限定了一台设备的密钥不指定设备时，任务下发给该设备；队列中看不到其他设备的任务
+	if w := serveKey(r, dev1, "POST", "/admin/task", `{"type":"LinkStart"}`); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"device":"dev1"`) {
+		t.Errorf("不指定设备下发返回 %d: %s", w.Code, w.Body)
+	}
+	if w := serveKey(r, dev1, "GET", "/admin/queue", ""); strings.Contains(w.Body.String(), other.ID) {
+		t.Errorf("队列中出现了其他设备的任务: %s", w.Body)
+	}
+}
+
+func TestAPIKeyExpiry(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	exp := time.Now().Add(time.Hour)
+	secret := createKey(t, h, store.APIKey{Scopes: []store.Scope{store.ScopeTasksRead}, ExpiresAt: &exp})
+	if w := serveKey(r, secret, "GET", "/admin/queue", ""); w.Code != http.StatusOK {
+		t.Fatalf("未过期的密钥返回 %d", w.Code)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	expired := createKey(t, h, store.APIKey{Scopes: []store.Scope{store.ScopeTasksRead}, ExpiresAt: &past})
+	if w := serveKey(r, expired, "GET", "/admin/queue", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("过期的密钥返回 %d，期望 401", w.Code)
+	}
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/api"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── /api/v1 ───────────────────────────────────────────────────
+
+// problemCodes 是各状态码对应的错误代码
+var problemCodes = map[int]string{
+	http.StatusBadRequest:            "invalid_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusConflict:              "conflict",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusTooManyRequests:       "rate_limited",
+	http.StatusInternalServerError:   "internal",
+	http.StatusServiceUnavailable:    "unavailable",
+	http.StatusGatewayTimeout:        "timeout",
+}
+
+func problem(status int, message string) api.ErrorEnvelope {
+	code, ok := problemCodes[status]
+	if !ok {
+		code = "error"
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return api.ErrorEnvelope{Error: api.Problem{Code: code, Message: message, Status: status}}
+}
+
+// envelopeWriter 缓存错误响应的响应体，成功的响应（包括截图等文件）直接写出
+type envelopeWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *envelopeWriter) Write(data []byte) (int, error) {
+	if w.Status() >= 400 {
+		return w.buf.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *envelopeWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// ErrorEnvelope 把 /api/v1 下的错误响应统一改写为 {"error": {"code", "message", "status"}}。
+// 处理函数与 /admin 共用，仍按 {"error": "说明"} 返回错误，由这里转换。
+func ErrorEnvelope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		w := &envelopeWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		status := w.Status()
+		if status < 400 {
+			return
+		}
+		var body struct {
+			Error json.RawMessage `json:"error"`
+		}
+		message := ""
+		if json.Unmarshal(w.buf.Bytes(), &body) == nil {
+			_ = json.Unmarshal(body.Error, &message)
+		}
+		c.Writer.Header().Del("Content-Length")
+		c.Writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		data, _ := json.Marshal(problem(status, message))
+		_, _ = c.Writer.Write(data)
+	}
+}
+
+// NoRoute 对 /api/ 下不存在的路径返回错误信封，其他路径保持 Gin 默认的 404
+func NoRoute(c *gin.Context) {
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+		c.JSON(http.StatusNotFound, problem(http.StatusNotFound, "接口不存在"))
+		return
+	}
+	c.String(http.StatusNotFound, "404 page not found")
+}
+
+// taskCursor 是编码在 next_cursor 中的分页位置，带上排序方向以免混用
+type taskCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+	Ascending bool      `json:"asc,omitempty"`
+}
+
+func encodeCursor(t *store.Task, ascending bool) string {
+	data, _ := json.Marshal(taskCursor{CreatedAt: t.CreatedAt, ID: t.ID, Ascending: ascending})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, ascending bool) (*store.TaskCursor, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+	var cur taskCursor
+	if json.Unmarshal(data, &cur) != nil || cur.ID == "" || cur.Ascending != ascending {
+		return nil, false
+	}
+	return &store.TaskCursor{CreatedAt: cur.CreatedAt, ID: cur.ID}, true
+}
+
+// QueryTasks 分页查询任务，参数见 api.TaskQuery
+func (h *Handler) QueryTasks(c *gin.Context) {
+	q, err := api.ParseTaskQuery(c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var after *store.TaskCursor
+	if q.Cursor != "" {
+		var ok bool
+		if after, ok = decodeCursor(q.Cursor, q.Ascending()); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cursor 无效或与 sort 不匹配"})
+			return
+		}
+	}
+	filter := store.TaskFilter{
+		IDPrefix: q.ID,
+		Statuses: q.Status,
+		Types:    q.Type,
+		Device:   q.Device,
+		Screen:   q.Screen,
+		Since:    q.Since,
+		Until:    q.Until,
+		Allow:    func(t *store.Task) bool { return deviceAllowed(c, t.Device) },
+	}
+	tasks, more := h.store.Query(filter, q.Ascending(), after, q.Limit)
+	resp := api.TaskList{Items: tasks}
+	if more {
+		resp.NextCursor = encodeCursor(tasks[len(tasks)-1], q.Ascending())
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetTaskByID 返回单个任务，API 密钥看不到的设备的任务同样返回 404
+func (h *Handler) GetTaskByID(c *gin.Context) {
+	t := h.store.Get(c.Param("id"))
+	if t == nil || !deviceAllowed(c, t.Device) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/api"
+	"ArknightsMaaRemoter/store"
+)
+
+func v1Router(h *Handler) *gin.Engine {
+	r := gin.New()
+	viewer := store.RoleViewer
+	v1 := r.Group("/api/v1", ErrorEnvelope(), h.Authenticate())
+	v1.GET("/tasks", h.Allow(viewer, store.ScopeTasksRead), h.QueryTasks)
+	v1.GET("/tasks/:id", h.Allow(viewer, store.ScopeTasksRead), h.GetTaskByID)
+	v1.GET("/screenshot/:id", h.Allow(viewer, store.ScopeScreenshotsRead), h.GetScreenshot)
+	r.NoRoute(NoRoute)
+	return r
+}
+
+// problemOf 解析错误信封，格式不对时返回 nil
+func problemOf(t *testing.T, body []byte) *api.Problem {
+	t.Helper()
+	var env api.ErrorEnvelope
+	if err := json.Unmarshal(body, &env); err != nil || env.Error.Code == "" {
+		t.Errorf("响应不是错误信封: %s", body)
+		return nil
+	}
+	return &env.Error
+}
+
+func TestQueryTasksPaging(t *testing.T) {
+	h := newTestHandler(t)
+	r := v1Router(h)
+	addUser(t, h, "alice", store.RoleViewer)
+	for i := 0; i < 5; i++ {
+		h.store.Add("LinkStart", "")
+	}
+
+	for _, sort := range []string{"created_at", "-created_at"} {
+		var got []string
+		cursor := ""
+		for pages := 0; pages < 5; pages++ {
+			path := "/api/v1/tasks?limit=2&sort=" + sort
+			if cursor != "" {
+				path += "&cursor=" + url.QueryEscape(cursor)
+			}
+			w := serveAs(r, "alice", "GET", path, "")
+			var list api.TaskList
+			if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || w.Code != http.StatusOK {
+				t.Fatalf("%s 返回 %d: %s", path, w.Code, w.Body)
+			}
+			for _, task := range list.Items {
+				got = append(got, task.ID)
+			}
+			if cursor = list.NextCursor; cursor == "" {
+				break
+			}
+		}
+		// 创建时间相同的任务按 ID 排序，以一次取完的结果为准
+		tasks, _ := h.store.Query(store.TaskFilter{}, sort == "created_at", nil, 0)
+		var want []string
+		for _, task := range tasks {
+			want = append(want, task.ID)
+		}
+		if len(want) != 5 || strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("sort=%s 逐页得到 %v，期望 %v", sort, got, want)
+		}
+	}
+}
+
+func TestCursor(t *testing.T) {
+	h := newTestHandler(t)
+	task := h.store.Add("LinkStart", "")
+	s := encodeCursor(task, true)
+	cur, ok := decodeCursor(s, true)
+	if !ok || cur.ID != task.ID || !cur.CreatedAt.Equal(task.CreatedAt) {
+		t.Errorf("解码得到 %+v, %v", cur, ok)
+	}
+	for _, bad := range []string{"", "!!!", "e30", encodeCursor(task, false)} {
+		if _, ok := decodeCursor(bad, true); ok {
+			t.Errorf("%q 解码成功", bad)
+		}
+	}
+}
+
+func TestV1ErrorEnvelope(t *testing.T) {
+	h := newTestHandler(t)
+	r := v1Router(h)
+	addUser(t, h, "alice", store.RoleViewer)
+	desc := encodeCursor(h.store.Add("LinkStart", ""), false)
+
+	for _, c := range []struct {
+		name, user, path string
+		status           int
+		code, message    string
+	}{
+		{"错误的状态", "alice", "/api/v1/tasks?status=DONE", 400, "invalid_request", "DONE"},
+		{"无效的游标", "alice", "/api/v1/tasks?cursor=!!!", 400, "invalid_request", "cursor"},
+		{"游标与排序不符", "alice", "/api/v1/tasks?sort=created_at&cursor=" + desc, 400, "invalid_request", "cursor"},
+		{"任务不存在", "alice", "/api/v1/tasks/nope", 404, "not_found", "任务不存在"},
+		{"截图不存在", "alice", "/api/v1/screenshot/nope", 404, "not_found", ""},
+		{"未认证", "", "/api/v1/tasks", 401, "unauthorized", ""},
+		{"接口不存在", "alice", "/api/v1/nope", 404, "not_found", "接口不存在"},
+	} {
+		var w *httptest.ResponseRecorder
+		if c.user == "" {
+			w = serve(r, "GET", c.path, "")
+		} else {
+			w = serveAs(r, c.user, "GET", c.path, "")
+		}
+		if w.Code != c.status {
+			t.Errorf("%s: 返回 %d，期望 %d", c.name, w.Code, c.status)
+			continue
+		}
+		if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+			t.Errorf("%s: Content-Type 为 %s", c.name, w.Header().Get("Content-Type"))
+		}
+		p := problemOf(t, w.Body.Bytes())
+		if p != nil && (p.Code != c.code || p.Status != c.status || p.Message == "" || !strings.Contains(p.Message, c.message)) {
+			t.Errorf("%s: 错误为 %+v", c.name, p)
+		}
+	}
+}
+
+func TestV1SuccessPassesThrough(t *testing.T) {
+	h := newTestHandler(t)
+	r := v1Router(h)
+	addUser(t, h, "alice", store.RoleViewer)
+	addScreenshots(t, h, 1)
+	shot := h.store.All()[0]
+
+	w := serveAs(r, "alice", "GET", "/api/v1/screenshot/"+shot.ID, "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" || !strings.HasPrefix(w.Body.String(), "\x89PNG") {
+		t.Errorf("下载截图返回 %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	w = serveAs(r, "alice", "GET", "/api/v1/tasks/"+shot.ID, "")
+	var task api.Task
+	if err := json.Unmarshal(w.Body.Bytes(), &task); err != nil || task.ID != shot.ID {
+		t.Errorf("获取任务返回 %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestV1DeviceKey(t *testing.T) {
+	h := newTestHandler(t)
+	r := v1Router(h)
+	dev1 := createKey(t, h, store.APIKey{Scopes: []store.Scope{store.ScopeTasksRead}, Devices: []string{"dev1"}})
+	mine := h.store.AddFor("dev1", "LinkStart", "")
+	other := h.store.AddFor("dev2", "LinkStart", "")
+
+	w := serveKey(r, dev1, "GET", "/api/v1/tasks", "")
+	if !strings.Contains(w.Body.String(), mine.ID) || strings.Contains(w.Body.String(), other.ID) {
+		t.Errorf("限定设备的密钥查询到 %s", w.Body)
+	}
+	w = serveKey(r, dev1, "GET", "/api/v1/tasks/"+other.ID, "")
+	if p := problemOf(t, w.Body.Bytes()); w.Code != http.StatusNotFound || p == nil || p.Code != "not_found" {
+		t.Errorf("获取其他设备的任务返回 %d: %s", w.Code, w.Body)
+	}
+}
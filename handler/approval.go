@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 双人确认 ──────────────────────────────────────────────────
+//
+// 需要确认的任务类型和提交者本人的等待时间见 Options.ConfirmTypes、Options.ConfirmSelfDelay。
+
+// ApproveTask 确认一个待确认的任务。确认人须有权下发该类型的任务，
+// 参数须符合当前的 Settings 规则（导入的任务提交时没有经过检查）；
+// 提交者本人只能在 ConfirmSelfDelay 之后确认。
+func (h *Handler) ApproveTask(c *gin.Context) {
+	t := h.store.Get(c.Param("id"))
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if !h.typeAllowed(c, t.Type) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权确认该类型任务"})
+		return
+	}
+	if err := h.settings.Check(t.Device, t.Type, t.Params); err != nil {
+		c.JSON(settingsErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	t, err := h.store.Approve(t.ID, actorName(c), h.options().ConfirmSelfDelay)
+	if err != nil {
+		c.JSON(approvalErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "task.approve", t.ID, map[string]any{"type": t.Type, "submitted_by": t.SubmittedBy})
+	c.JSON(http.StatusOK, t)
+}
+
+// RejectTask 拒绝一个待确认的任务，提交者本人可随时撤回
+func (h *Handler) RejectTask(c *gin.Context) {
+	t := h.store.Get(c.Param("id"))
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if t.SubmittedBy != actorName(c) && !h.typeAllowed(c, t.Type) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权拒绝该类型任务"})
+		return
+	}
+	t, err := h.store.Reject(t.ID, actorName(c))
+	if err != nil {
+		c.JSON(approvalErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "task.reject", t.ID, map[string]any{"type": t.Type, "submitted_by": t.SubmittedBy})
+	c.JSON(http.StatusOK, t)
+}
+
+func approvalErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, store.ErrTaskNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrNotAwaiting):
+		return http.StatusConflict
+	default:
+		return http.StatusForbidden
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"ArknightsMaaRemoter/store"
+)
+
+func TestApproveChecksSettings(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	addUser(t, h, "bob", store.RoleAdmin)
+
+	// 导入的任务没有经过提交时的检查，确认时才校验参数
+	old := time.Now().Add(-time.Hour)
+	tasks := []*store.Task{
+		{ID: "public", Type: "Settings-ConnectionAddress", Params: "8.8.8.8:5555", Status: store.StatusPending, SubmittedBy: "alice", CreatedAt: old},
+		{ID: "type", Type: "Settings-Penguin", Params: "x", Status: store.StatusPending, SubmittedBy: "alice", CreatedAt: old},
+		{ID: "ok", Type: "Settings-Stage1", Params: "1-7", Status: store.StatusPending, SubmittedBy: "alice", CreatedAt: old},
+	}
+	h.store.Import(tasks)
+
+	for id, want := range map[string]int{"public": http.StatusBadRequest, "type": http.StatusForbidden, "ok": http.StatusOK} {
+		if w := serveAs(r, "bob", "POST", "/admin/task/"+id+"/approve", ""); w.Code != want {
+			t.Errorf("确认 %s 返回 %d，期望 %d: %s", id, w.Code, want, w.Body)
+		}
+	}
+	if got := h.store.Get("public"); got.Status != store.StatusAwaitingApproval {
+		t.Errorf("不符合规则的任务状态变为 %s", got.Status)
+	}
+}
+
+func TestApproval(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	addUser(t, h, "op1", store.RoleOperator)
+	addUser(t, h, "op2", store.RoleOperator)
+	addUser(t, h, "boss", store.RoleAdmin)
+
+	submit := func(user, body string) *store.Task {
+		t.Helper()
+		w := serveAs(r, user, "POST", "/admin/task", body)
+		var task store.Task
+		if w.Code != http.StatusAccepted || json.Unmarshal(w.Body.Bytes(), &task) != nil {
+			t.Fatalf("%s 提交 %s 返回 %d: %s", user, body, w.Code, w.Body)
+		}
+		if task.Status != store.StatusAwaitingApproval || task.SubmittedBy != user {
+			t.Fatalf("提交后任务为 %+v", task)
+		}
+		return &task
+	}
+	act := func(user, id, action string) int {
+		return serveAs(r, user, "POST", "/admin/task/"+id+"/"+action, "").Code
+	}
+
+	gacha := submit("op1", `{"type":"Toolbox-GachaOnce"}`)
+	if got := h.store.Pending("u1", "dev1"); len(got) != 0 {
+		t.Errorf("待确认的任务被下发: %+v", got)
+	}
+	if code := act("op1", gacha.ID, "approve"); code != http.StatusForbidden {
+		t.Errorf("提交者立即确认返回 %d，期望 403", code)
+	}
+	if code := act("op2", gacha.ID, "approve"); code != http.StatusOK {
+		t.Errorf("他人确认返回 %d", code)
+	}
+	if got := h.store.Get(gacha.ID); got.Status != store.StatusPending || got.ApprovedBy != "op2" {
+		t.Errorf("确认后任务为 %s，确认人 %q", got.Status, got.ApprovedBy)
+	}
+	if code := act("op2", gacha.ID, "approve"); code != http.StatusConflict {
+		t.Errorf("重复确认返回 %d，期望 409", code)
+	}
+	if code := act("op2", gacha.ID, "reject"); code != http.StatusConflict {
+		t.Errorf("拒绝已确认的任务返回 %d，期望 409", code)
+	}
+	if code := act("op2", "missing", "approve"); code != http.StatusNotFound {
+		t.Errorf("确认不存在的任务返回 %d，期望 404", code)
+	}
+
+	// 角色不能下发的类型也不能确认或拒绝，但提交者本人可以撤回
+	settings := submit("boss", `{"type":"Settings-Stage1","params":"1-7"}`)
+	for _, action := range []string{"approve", "reject"} {
+		if code := act("op1", settings.ID, action); code != http.StatusForbidden {
+			t.Errorf("无权的角色 %s 返回 %d，期望 403", action, code)
+		}
+	}
+	if code := act("boss", settings.ID, "reject"); code != http.StatusOK {
+		t.Errorf("提交者撤回返回 %d", code)
+	}
+	if got := h.store.Get(settings.ID); got.Status != store.StatusRejected || got.DoneAt == nil {
+		t.Errorf("撤回后任务为 %s", got.Status)
+	}
+	own := submit("op1", `{"type":"Toolbox-GachaTenTimes"}`)
+	if code := act("op1", own.ID, "reject"); code != http.StatusOK {
+		t.Errorf("操作员撤回自己的任务返回 %d", code)
+	}
+}
+
+func TestApproveSelfAfterDelay(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	addUser(t, h, "op1", store.RoleOperator)
+
+	h.store.Import([]*store.Task{
+		{ID: "old", Type: "Toolbox-GachaOnce", Status: store.StatusAwaitingApproval, SubmittedBy: "op1", CreatedAt: time.Now().Add(-time.Hour)},
+	})
+	if w := serveAs(r, "op1", "POST", "/admin/task/old/approve", ""); w.Code != http.StatusOK {
+		t.Errorf("超过等待时间后本人确认返回 %d: %s", w.Code, w.Body)
+	}
+
+	// ConfirmSelfDelay 为 0 时本人始终不能确认
+	opts := defaultOptions
+	opts.ConfirmSelfDelay = 0
+	h.SetOptions(opts)
+	h.store.Import([]*store.Task{
+		{ID: "never", Type: "Toolbox-GachaOnce", Status: store.StatusAwaitingApproval, SubmittedBy: "op1", CreatedAt: time.Now().Add(-time.Hour)},
+	})
+	if w := serveAs(r, "op1", "POST", "/admin/task/never/approve", ""); w.Code != http.StatusForbidden {
+		t.Errorf("ConfirmSelfDelay 为 0 时本人确认返回 %d，期望 403", w.Code)
+	}
+}
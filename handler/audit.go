@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 审计日志 ──────────────────────────────────────────────────
+
+const defaultAuditLimit = 200
+
+// audit 以当前登录用户或 API 密钥的身份记录一次操作
+func (h *Handler) audit(c *gin.Context, action, target string, detail map[string]any) {
+	h.auditAs(c, actorName(c), action, target, detail)
+}
+
+// auditAs 以指定身份记录一次操作，用于登录等尚未建立身份的场景
+func (h *Handler) auditAs(c *gin.Context, actor, action, target string, detail map[string]any) {
+	h.record(store.AuditEntry{
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+		Detail:    detail,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	})
+}
+
+// record 写入审计日志，失败时只打日志，不影响操作本身
+func (h *Handler) record(e store.AuditEntry) {
+	if err := h.auditLog.Record(e); err != nil {
+		log.Printf("写入审计日志失败: %v", err)
+	}
+}
+
+func actorName(c *gin.Context) string {
+	if key := currentKey(c); key != nil {
+		return "key:" + key.Name
+	}
+	if user := currentUser(c); user != nil {
+		return user.Username
+	}
+	return ""
+}
+
+// auditFilter 从查询参数解析过滤条件：actor、action（末尾 * 前缀匹配）、since、until（RFC3339）、limit
+func auditFilter(c *gin.Context, defLimit int) (store.AuditFilter, bool) {
+	f := store.AuditFilter{Actor: c.Query("actor"), Action: c.Query("action"), Limit: defLimit}
+	for name, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := c.Query(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": name + " 应为 RFC3339 时间，如 2024-01-02T15:04:05+08:00"})
+				return f, false
+			}
+			*dst = t
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须是非负整数"})
+			return f, false
+		}
+		f.Limit = n
+	}
+	return f, true
+}
+
+// ListAudit 查询审计日志，最新的在前，默认返回最近 200 条（limit=0 表示全部）
+func (h *Handler) ListAudit(c *gin.Context) {
+	filter, ok := auditFilter(c, defaultAuditLimit)
+	if !ok {
+		return
+	}
+	entries, err := h.auditLog.Query(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// ExportAudit 导出审计日志，format 为 jsonl（默认）或 csv，过滤条件同 ListAudit，默认不限条数
+func (h *Handler) ExportAudit(c *gin.Context) {
+	format := c.DefaultQuery("format", "jsonl")
+	if format != "jsonl" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 必须是 jsonl 或 csv"})
+		return
+	}
+	filter, ok := auditFilter(c, 0)
+	if !ok {
+		return
+	}
+	entries, err := h.auditLog.Query(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := "audit_" + time.Now().Format("20060102_150405") + "." + format
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	if format == "jsonl" {
+		c.Header("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(c.Writer)
+		for _, e := range entries {
+			_ = enc.Encode(e)
+		}
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	// 加 BOM，Excel 打开时才能正确识别中文
+	_, _ = c.Writer.WriteString("\ufeff")
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"time", "actor", "action", "target", "detail", "ip", "user_agent"})
+	for _, e := range entries {
+		detail := ""
+		if len(e.Detail) > 0 {
+			b, _ := json.Marshal(e.Detail)
+			detail = string(b)
+		}
+		_ = w.Write([]string{e.Time.Format(time.RFC3339), e.Actor, e.Action, e.Target, detail, e.IP, e.UserAgent})
+	}
+	w.Flush()
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/store"
+)
+
+func auditRouter(h *Handler) *gin.Engine {
+	r := gin.New()
+	r.GET("/audit", h.ListAudit)
+	r.GET("/audit/export", h.ExportAudit)
+	return r
+}
+
+func TestListAudit(t *testing.T) {
+	h := newTestHandler(t)
+	r := auditRouter(h)
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	h.record(store.AuditEntry{Time: base, Actor: "admin", Action: "user.create", Target: "alice"})
+	h.record(store.AuditEntry{Time: base.Add(time.Hour), Actor: "alice", Action: "task.submit", Target: "t1"})
+
+	w := serve(r, "GET", "/audit?actor=alice", "")
+	var entries []store.AuditEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil || len(entries) != 1 || entries[0].Target != "t1" {
+		t.Errorf("按操作者查询返回 %d: %s", w.Code, w.Body)
+	}
+	w = serve(r, "GET", "/audit?since=2024-01-02T10:30:00Z", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil || len(entries) != 1 || entries[0].Action != "task.submit" {
+		t.Errorf("按时间查询返回 %d: %s", w.Code, w.Body)
+	}
+	for _, path := range []string{"/audit?since=yesterday", "/audit?limit=-1", "/audit/export?format=xml"} {
+		if w := serve(r, "GET", path, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s 返回 %d", path, w.Code)
+		}
+	}
+}
+
+func TestExportAudit(t *testing.T) {
+	h := newTestHandler(t)
+	r := auditRouter(h)
+	h.record(store.AuditEntry{Actor: "admin", Action: "user.create", Target: "alice", Detail: map[string]any{"role": "viewer"}})
+	h.record(store.AuditEntry{Actor: "alice", Action: "task.submit", Target: "t1"})
+
+	w := serve(r, "GET", "/audit/export", "")
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if w.Header().Get("Content-Type") != "application/x-ndjson" || len(lines) != 2 {
+		t.Fatalf("jsonl 导出 %s: %s", w.Header().Get("Content-Type"), w.Body)
+	}
+	var e store.AuditEntry
+	if err := json.Unmarshal([]byte(lines[1]), &e); err != nil || e.Action != "user.create" || e.Detail["role"] != "viewer" {
+		t.Errorf("jsonl 第二行为 %s", lines[1])
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), ".jsonl") {
+		t.Errorf("Content-Disposition = %s", w.Header().Get("Content-Disposition"))
+	}
+
+	w = serve(r, "GET", "/audit/export?format=csv&action=user.*", "")
+	body := w.Body.String()
+	if !strings.HasPrefix(body, "\ufeff") {
+		t.Error("csv 导出缺少 BOM")
+	}
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(body, "\ufeff"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0][0] != "time" {
+		t.Fatalf("csv 导出 %q", records)
+	}
+	if got := records[1]; got[1] != "admin" || got[2] != "user.create" || got[3] != "alice" || got[4] != `{"role":"viewer"}` {
+		t.Errorf("csv 记录为 %q", got)
+	}
+}
+
+func TestAuditTimelapse(t *testing.T) {
+	h := newTestHandler(t)
+	t.Cleanup(h.Close)
+	r := timelapseRouter(h)
+	addScreenshots(t, h, 1)
+	job := createTimelapse(t, r)
+	waitTimelapse(t, h, job.ID)
+	if w := serve(r, "DELETE", "/timelapse/"+job.ID, ""); w.Code != http.StatusOK {
+		t.Fatalf("删除返回 %d", w.Code)
+	}
+
+	entries, err := h.auditLog.Query(store.AuditFilter{Action: "timelapse.*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Action != "timelapse.delete" || entries[1].Action != "timelapse.create" || entries[1].Target != job.ID {
+		t.Errorf("审计记录为 %+v", entries)
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 认证与授权 ────────────────────────────────────────────────
+
+const (
+	ctxUser = "user"
+	ctxKey  = "apikey"
+)
+
+// Authenticate 是管理端点的认证中间件，取代原来只比对 ADMIN_TOKEN 的 AdminAuth。支持：
+//   - 控制面板的登录会话 Cookie，写请求须携带 X-CSRF-Token
+//   - HTTP Basic（用户名 + 密码）
+//   - Bearer API 密钥（maa_ 开头），权限由密钥的 scope 决定
+//   - 配置了 auth.admin_token（或 ADMIN_TOKEN）时的 Bearer 令牌，视为管理员，兼容旧脚本
+func (h *Handler) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if sess, user := h.sessionUser(c); sess != nil {
+			if !csrfOK(c, sess) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "csrf token 无效"})
+				return
+			}
+			c.Set(ctxUser, user)
+			c.Set(ctxSession, sess)
+			c.Next()
+			return
+		}
+
+		username, password, basic := c.Request.BasicAuth()
+		bearer, hasBearer := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !basic && !hasBearer {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		// 携带了凭证才计入失败次数，锁定期间不再校验，避免被用来爆破
+		if h.guard.abortLocked(c) {
+			return
+		}
+
+		legacy := h.options().AdminToken
+		ok := false
+		switch {
+		case basic:
+			if user := h.users.Authenticate(username, password); user != nil {
+				c.Set(ctxUser, user)
+				ok = true
+			}
+		case legacy != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(legacy)) == 1:
+			c.Set(ctxUser, &store.User{Username: "token", Role: store.RoleAdmin})
+			ok = true
+		default:
+			if key := h.keys.Lookup(bearer); key != nil {
+				c.Set(ctxKey, key)
+				ok = true
+			}
+		}
+		if !ok {
+			h.guard.fail(c.ClientIP())
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		h.guard.succeed(c.ClientIP())
+		c.Next()
+	}
+}
+
+// Allow 要求登录用户至少拥有 min 角色，或 API 密钥拥有 scope。
+// scope 为空表示 API 密钥不能访问。
+func (h *Handler) Allow(min store.Role, scope store.Scope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed := false
+		if key := currentKey(c); key != nil {
+			allowed = scope != "" && key.HasScope(scope)
+		} else if user := currentUser(c); user != nil {
+			allowed = user.Role.AtLeast(min)
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func currentUser(c *gin.Context) *store.User {
+	v, ok := c.Get(ctxUser)
+	if !ok {
+		return nil
+	}
+	return v.(*store.User)
+}
+
+func currentKey(c *gin.Context) *store.APIKey {
+	v, ok := c.Get(ctxKey)
+	if !ok {
+		return nil
+	}
+	return v.(*store.APIKey)
+}
+
+// typeAllowed 判断当前用户或密钥能否下发该类型的任务
+func (h *Handler) typeAllowed(c *gin.Context, taskType string) bool {
+	if key := currentKey(c); key != nil {
+		return key.TypeAllowed(taskType)
+	}
+	return h.users.TypeAllowed(currentUser(c).Role, taskType)
+}
+
+// deviceAllowed 判断当前凭证能否访问该设备的任务，只有限定了设备的 API 密钥会受限
+func deviceAllowed(c *gin.Context, device string) bool {
+	if key := currentKey(c); key != nil {
+		return key.DeviceAllowed(device)
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/api"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 批量下发 ──────────────────────────────────────────────────
+
+// maxBatchSize 是单次批量下发的任务数上限
+const maxBatchSize = 100
+
+// SubmitBatch 校验并按顺序下发一组任务：任何一个不通过都不会入队，
+// 全部通过则一次性加入队列，MAA 按提交顺序依次执行。
+// 需要确认的任务类型不能批量下发，否则确认前后顺序会被打乱；
+// 同一批任务的优先级也必须相同，否则队列按优先级排序后不再是提交顺序。
+func (h *Handler) SubmitBatch(c *gin.Context) {
+	var req api.SubmitBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Tasks) == 0 || len(req.Tasks) > maxBatchSize {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("tasks 应包含 1-%d 个任务", maxBatchSize)})
+		return
+	}
+
+	tasks := make([]*store.Task, 0, len(req.Tasks))
+	for i := range req.Tasks {
+		item := &req.Tasks[i]
+		if status, err := h.checkSubmit(c, item); err != nil {
+			c.JSON(status, gin.H{"error": fmt.Sprintf("第 %d 个任务: %v", i+1, err)})
+			return
+		}
+		if h.options().confirmRequired(item.Type) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("第 %d 个任务: %s 需要确认，不能批量下发", i+1, item.Type)})
+			return
+		}
+		if item.Priority != req.Tasks[0].Priority {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("第 %d 个任务: 同一批任务的 priority 必须相同", i+1)})
+			return
+		}
+		tasks = append(tasks, &store.Task{Type: item.Type, Params: item.Params, Status: store.StatusPending, Device: item.Device, Priority: item.Priority})
+	}
+
+	tasks = h.store.AddBatch(tasks)
+	for i, t := range tasks {
+		h.audit(c, "task.submit", t.ID, map[string]any{"type": t.Type, "params": t.Params, "device": t.Device, "priority": t.Priority, "batch": tasks[0].ID, "index": i})
+	}
+	c.JSON(http.StatusOK, api.SubmitBatchResponse{Tasks: tasks})
+}
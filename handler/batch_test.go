@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"ArknightsMaaRemoter/api"
+	"ArknightsMaaRemoter/store"
+)
+
+func TestSubmitBatchAllOrNothing(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	addUser(t, h, "op", store.RoleOperator)
+	addUser(t, h, "boss", store.RoleAdmin)
+
+	for _, c := range []struct {
+		name, user, body string
+		want             int
+	}{
+		{"无权下发的类型", "op", `{"tasks":[{"type":"LinkStart"},{"type":"Settings-Stage1","params":"1-7"}]}`, http.StatusForbidden},
+		{"参数不符合规则", "boss", `{"tasks":[{"type":"LinkStart"},{"type":"Settings-ConnectionAddress","params":"8.8.8.8:5555"}]}`, http.StatusBadRequest},
+		{"需要确认的类型", "boss", `{"tasks":[{"type":"LinkStart"},{"type":"Toolbox-GachaOnce"}]}`, http.StatusBadRequest},
+		{"缺少类型", "op", `{"tasks":[{"type":"LinkStart"},{"params":"x"}]}`, http.StatusBadRequest},
+		{"优先级不同", "op", `{"tasks":[{"type":"LinkStart"},{"type":"LinkStart-Base","priority":1}]}`, http.StatusBadRequest},
+	} {
+		if w := serveAs(r, c.user, "POST", "/admin/tasks/batch", c.body); w.Code != c.want {
+			t.Errorf("%s: 返回 %d，期望 %d: %s", c.name, w.Code, c.want, w.Body)
+		}
+		if all := h.store.All(); len(all) != 0 {
+			t.Fatalf("%s: 有 %d 个任务入队，期望一个都不加入", c.name, len(all))
+		}
+	}
+}
+
+func TestSubmitBatchKeepsOrder(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	maa := maaRouter(h)
+	addUser(t, h, "op", store.RoleOperator)
+
+	h.store.Add("LinkStart-Mall", "")
+	// 整批插到优先级 0 的任务之前，批内仍按提交顺序
+	w := serveAs(r, "op", "POST", "/admin/tasks/batch", `{"tasks":[
+		{"type":"LinkStart-WakeUp","priority":5},
+		{"type":"LinkStart-Combat","priority":5},
+		{"type":"CaptureImage","priority":5}
+	]}`)
+	var resp api.SubmitBatchResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK || len(resp.Tasks) != 3 {
+		t.Fatalf("批量下发返回 %d: %s", w.Code, w.Body)
+	}
+	if got := polledTypes(t, maa, "dev1"); got != "LinkStart-WakeUp,LinkStart-Combat,CaptureImage,LinkStart-Mall" {
+		t.Errorf("MAA 拿到的顺序为 %s", got)
+	}
+}
@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ── 立即截图并返回 ────────────────────────────────────────────
+
+const (
+	captureTaskType = "CaptureImageNow"
+	// deviceOfflineAfter 内没有轮询过的设备视为离线，MAA 默认每秒轮询一次
+	deviceOfflineAfter = 30 * time.Second
+)
+
+// deviceOnline 判断设备最近是否轮询过
+func (h *Handler) deviceOnline(device string) bool {
+	for _, d := range h.store.Devices() {
+		if d.ID == device {
+			return time.Since(d.LastSeen) < deviceOfflineAfter
+		}
+	}
+	return false
+}
+
+// CaptureScreenshot 给设备下发立刻截图任务，等 MAA 汇报后直接返回 PNG，
+// 省去下发、等待、下载三次请求。可选 ?timeout=（默认 30s，最长 5m）。
+// 设备离线或超时返回 504；超时的任务仍留在队列中，设备上线后照常执行。
+func (h *Handler) CaptureScreenshot(c *gin.Context) {
+	device := c.Param("device")
+	timeout, ok := parseWaitTimeout(c.Query("timeout"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "timeout 应为 0-5m 之间的时长，如 30s，或秒数"})
+		return
+	}
+	if !h.typeAllowed(c, captureTaskType) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权下发该类型任务"})
+		return
+	}
+	if !deviceAllowed(c, device) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权操作该设备"})
+		return
+	}
+	if h.options().confirmRequired(captureTaskType) {
+		c.JSON(http.StatusConflict, gin.H{"error": "截图任务需要确认，无法立即返回"})
+		return
+	}
+	if !h.deviceOnline(device) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": fmt.Sprintf("设备 %s 离线（%s 内没有轮询）", device, deviceOfflineAfter)})
+		return
+	}
+
+	t := h.store.AddFor(device, captureTaskType, "")
+	h.audit(c, "task.submit", t.ID, map[string]any{"type": t.Type, "device": t.Device, "sync": true})
+	_, done := h.store.Watch(t.ID)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": fmt.Sprintf("等待截图超时，任务 %s 仍在队列中", t.ID)})
+		return
+	case <-c.Request.Context().Done():
+		return
+	}
+
+	// 截图由 ReportStatus 经 saveScreenshot 落盘，这里直接返回该文件
+	c.Header("X-Task-ID", t.ID)
+	file, ok := "", false
+	if t = h.store.Get(t.ID); t != nil {
+		file, ok = h.screenshotPath(t)
+	}
+	if !ok {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "MAA 截图失败"})
+		return
+	}
+	c.File(file)
+}
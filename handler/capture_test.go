@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"ArknightsMaaRemoter/store"
+)
+
+func TestCaptureOffline(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	addUser(t, h, "op", store.RoleOperator)
+
+	// 从未轮询过的设备
+	if w := serveAs(r, "op", "POST", "/admin/devices/dev1/screenshot", ""); w.Code != http.StatusGatewayTimeout {
+		t.Errorf("未知设备返回 %d，期望 504", w.Code)
+	}
+	// 只有其他设备在线
+	h.store.Seen("u1", "dev2")
+	if w := serveAs(r, "op", "POST", "/admin/devices/dev1/screenshot", ""); w.Code != http.StatusGatewayTimeout {
+		t.Errorf("离线设备返回 %d，期望 504", w.Code)
+	}
+	// 离线时不下发任务
+	if all := h.store.All(); len(all) != 0 {
+		t.Errorf("设备离线时仍有 %d 个任务入队", len(all))
+	}
+}
+
+func TestCaptureTimeout(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	addUser(t, h, "op", store.RoleOperator)
+	h.store.Seen("u1", "dev1")
+
+	w := serveAs(r, "op", "POST", "/admin/devices/dev1/screenshot?timeout=200ms", "")
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("超时返回 %d，期望 504", w.Code)
+	}
+	// 超时的任务仍留在队列中，设备上线后照常执行
+	queue := h.store.Queue()
+	if len(queue) != 1 || queue[0].Type != captureTaskType || queue[0].Device != "dev1" {
+		t.Errorf("超时后队列为 %v", queue)
+	}
+}
+
+func TestCaptureReturnsPNG(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	maa := maaRouter(h)
+	addUser(t, h, "op", store.RoleOperator)
+	h.store.Seen("u1", "dev1")
+
+	// 模拟 MAA：领取到截图任务后汇报。测试结束前等它退出，避免清理临时目录时还在写截图
+	stop, exited := make(chan struct{}), make(chan struct{})
+	defer func() { close(stop); <-exited }()
+	go func() {
+		defer close(exited)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+			for _, task := range h.store.Pending("u1", "dev1") {
+				report(maa, task.ID, "SUCCESS", testPNG())
+			}
+		}
+	}()
+
+	w := serveAs(r, "op", "POST", "/admin/devices/dev1/screenshot?timeout=10s", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("返回 %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	if id := w.Header().Get("X-Task-ID"); h.store.Get(id) == nil {
+		t.Errorf("X-Task-ID %q 不是任务 ID", id)
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 设备令牌 ──────────────────────────────────────────────────
+
+// deviceTokenOK 校验 MAA 请求携带的设备令牌。令牌可以放在路径
+// /maa/<token>/getTask 中，也可以用查询参数 ?token= 传递。
+// 只要为任何一台设备配置了令牌（或开启了 MAA_REQUIRE_TOKEN），匿名端点就整体停用，
+// 否则不带令牌的请求仍能领取和汇报未指定设备的任务，令牌就形同虚设。
+func (h *Handler) deviceTokenOK(c *gin.Context, device string) bool {
+	token := c.Param("token")
+	if token == "" {
+		token = c.Query("token")
+	}
+	if token == "" {
+		return !h.options().RequireDeviceToken && h.tokens.Empty()
+	}
+	return device != "" && h.tokens.Device(token) == device
+}
+
+type deviceTokenResp struct {
+	Token           string             `json:"token"` // 明文令牌，只返回这一次
+	Info            *store.DeviceToken `json:"info"`
+	GetTaskURL      string             `json:"get_task_url"`
+	ReportStatusURL string             `json:"report_status_url"`
+}
+
+// ListDeviceTokens 列出已配置令牌的设备
+func (h *Handler) ListDeviceTokens(c *gin.Context) {
+	result := make([]store.DeviceToken, 0)
+	for _, t := range h.tokens.List() {
+		if deviceAllowed(c, t.Device) {
+			result = append(result, t)
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// IssueDeviceToken 为设备生成（或轮换）令牌，返回填入 MAA 的端点地址
+func (h *Handler) IssueDeviceToken(c *gin.Context) {
+	device := c.Param("device")
+	if !deviceAllowed(c, device) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权操作该设备"})
+		return
+	}
+	info, token := h.tokens.Issue(device)
+	h.audit(c, "device_token.issue", device, nil)
+	c.JSON(http.StatusOK, deviceTokenResp{
+		Token:           token,
+		Info:            info,
+		GetTaskURL:      "/maa/" + token + "/getTask",
+		ReportStatusURL: "/maa/" + token + "/reportStatus",
+	})
+}
+
+// RevokeDeviceToken 删除设备的令牌
+func (h *Handler) RevokeDeviceToken(c *gin.Context) {
+	device := c.Param("device")
+	if !deviceAllowed(c, device) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权操作该设备"})
+		return
+	}
+	if !h.tokens.Revoke(device) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该设备没有令牌"})
+		return
+	}
+	h.audit(c, "device_token.revoke", device, nil)
+	c.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/store"
+)
+
+func maaRouter(h *Handler) *gin.Engine {
+	r := gin.New()
+	r.POST("/maa/getTask", h.GetTask)
+	r.POST("/maa/reportStatus", h.ReportStatus)
+	r.POST("/maa/:token/getTask", h.GetTask)
+	r.POST("/maa/:token/reportStatus", h.ReportStatus)
+	return r
+}
+
+func TestAnonymousEndpointsDisabledByAnyToken(t *testing.T) {
+	h := newTestHandler(t)
+	r := maaRouter(h)
+	poll := func(path, device string) int {
+		return serve(r, "POST", path, fmt.Sprintf(`{"user":"u1","device":%q}`, device)).Code
+	}
+
+	if code := poll("/maa/getTask", "dev2"); code != http.StatusOK {
+		t.Fatalf("没有令牌时匿名轮询返回 %d", code)
+	}
+	_, token := h.tokens.Issue("dev1")
+
+	// dev2 没有令牌，但匿名端点已经停用
+	if code := poll("/maa/getTask", "dev2"); code != http.StatusForbidden {
+		t.Errorf("配置令牌后匿名轮询返回 %d，期望 403", code)
+	}
+	task := h.store.Add("LinkStart", "")
+	if code := poll("/maa/"+token+"/getTask", "dev1"); code != http.StatusOK {
+		t.Errorf("带令牌轮询返回 %d", code)
+	}
+	if code := poll("/maa/getTask?token="+token, "dev1"); code != http.StatusOK {
+		t.Errorf("查询参数带令牌轮询返回 %d", code)
+	}
+	if code := poll("/maa/"+token+"/getTask", "dev2"); code != http.StatusForbidden {
+		t.Errorf("其他设备使用令牌返回 %d，期望 403", code)
+	}
+	body := fmt.Sprintf(`{"user":"u1","device":"dev2","task":%q,"status":"SUCCESS","payload":""}`, task.ID)
+	if w := serve(r, "POST", "/maa/reportStatus", body); w.Code != http.StatusForbidden {
+		t.Errorf("匿名汇报返回 %d，期望 403", w.Code)
+	}
+	if got := h.store.Get(task.ID); got.Done() {
+		t.Errorf("匿名汇报改变了任务状态: %s", got.Status)
+	}
+
+	h.tokens.Revoke("dev1")
+	if code := poll("/maa/getTask", "dev2"); code != http.StatusOK {
+		t.Errorf("删除所有令牌后匿名轮询返回 %d", code)
+	}
+	opts := defaultOptions
+	opts.RequireDeviceToken = true
+	h.SetOptions(opts)
+	if code := poll("/maa/getTask", "dev2"); code != http.StatusForbidden {
+		t.Errorf("要求令牌时匿名轮询返回 %d，期望 403", code)
+	}
+}
+
+func TestReportStatusChecks(t *testing.T) {
+	h := newTestHandler(t)
+	r := maaRouter(h)
+	named := h.store.AddFor("dev1", "LinkStart", "")
+	broadcast := h.store.Add("LinkStart-Base", "")
+	serve(r, "POST", "/maa/getTask", `{"user":"u1","device":"dev1"}`)
+
+	send := func(user, device, id, status, payload string) int {
+		body := fmt.Sprintf(`{"user":%q,"device":%q,"task":%q,"status":%q,"payload":%q}`, user, device, id, status, payload)
+		return serve(r, "POST", "/maa/reportStatus", body).Code
+	}
+
+	for _, status := range []string{"", "RUNNING", "PENDING", "REJECTED", "success"} {
+		if code := send("u1", "dev1", named.ID, status, ""); code != http.StatusBadRequest {
+			t.Errorf("status=%q 返回 %d，期望 400", status, code)
+		}
+	}
+	if code := send("u2", "dev2", named.ID, "SUCCESS", "forged"); code != http.StatusForbidden {
+		t.Errorf("其他设备汇报指定设备的任务返回 %d，期望 403", code)
+	}
+	if code := send("u1", "dev1", "no-such-task", "SUCCESS", ""); code != http.StatusNotFound {
+		t.Errorf("汇报不存在的任务返回 %d，期望 404", code)
+	}
+	if code := send("u1", "dev1", named.ID, "FAILED", "first"); code != http.StatusOK {
+		t.Fatalf("正常汇报返回 %d", code)
+	}
+
+	// 已结束的任务不再接受汇报，先到的结果不会被覆盖
+	if code := send("u1", "dev1", named.ID, "SUCCESS", "second"); code != http.StatusConflict {
+		t.Errorf("重复汇报返回 %d，期望 409", code)
+	}
+	if code := send("u1", "dev1", broadcast.ID, "SUCCESS", "first"); code != http.StatusOK {
+		t.Fatalf("汇报未指定设备的任务返回 %d", code)
+	}
+	if code := send("u2", "dev2", broadcast.ID, "FAILED", "second"); code != http.StatusConflict {
+		t.Errorf("其他设备重复汇报返回 %d，期望 409", code)
+	}
+	for _, task := range []*store.Task{h.store.Get(named.ID), h.store.Get(broadcast.ID)} {
+		if task.Payload != "first" || task.Device != "dev1" {
+			t.Errorf("任务 %s 被重复汇报覆盖: payload=%q device=%q", task.Type, task.Payload, task.Device)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ── 截图对比 ──────────────────────────────────────────────────
+
+const defaultDiffTolerance = 32
+
+// DiffScreenshots 对比两张截图，返回以后者为底、变化像素标红的 PNG，
+// 变化比例写在 X-Change-Percent 响应头并叠加在图片左上角。
+// 可选 ?tolerance= 指定单通道差值阈值（0-255，默认 32）。
+func (h *Handler) DiffScreenshots(c *gin.Context) {
+	tolerance := defaultDiffTolerance
+	if v := c.Query("tolerance"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tolerance 必须是 0-255 的整数"})
+			return
+		}
+		tolerance = n
+	}
+
+	before, err := h.screenshotImage(c, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	after, err := h.screenshotImage(c, c.Param("other"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if before.Bounds().Size() != after.Bounds().Size() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "两张截图分辨率不同"})
+		return
+	}
+
+	out, percent := diffImages(before, after, tolerance)
+	drawLabel(out, fmt.Sprintf("%.2f%%", percent))
+
+	c.Header("X-Change-Percent", strconv.FormatFloat(percent, 'f', 2, 64))
+	c.Header("Content-Type", "image/png")
+	c.Status(http.StatusOK)
+	_ = png.Encode(c.Writer, out)
+}
+
+func (h *Handler) screenshotImage(c *gin.Context, id string) (image.Image, error) {
+	t := h.store.Get(id)
+	if t == nil || !deviceAllowed(c, t.Device) {
+		return nil, fmt.Errorf("截图 %s 不存在", id)
+	}
+	file, ok := h.screenshotPath(t)
+	if !ok {
+		return nil, fmt.Errorf("截图 %s 不存在", id)
+	}
+	img, err := loadImage(file)
+	if err != nil {
+		return nil, fmt.Errorf("截图 %s 无法读取", id)
+	}
+	return img, nil
+}
+
+// diffImages 逐像素比较，任一通道差值超过 tolerance 即视为变化。
+// 输出图中未变化的区域变暗变灰，变化的像素标为红色。
+func diffImages(a, b image.Image, tolerance int) (*image.RGBA, float64) {
+	ab, bb := a.Bounds(), b.Bounds()
+	w, h := bb.Dx(), bb.Dy()
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	red := color.RGBA{255, 0, 0, 255}
+	changed := 0
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r1, g1, b1, _ := a.At(ab.Min.X+x, ab.Min.Y+y).RGBA()
+			r2, g2, b2, _ := b.At(bb.Min.X+x, bb.Min.Y+y).RGBA()
+			d := maxInt(absDiff(r1, r2), absDiff(g1, g2), absDiff(b1, b2)) >> 8
+			if d > tolerance {
+				out.SetRGBA(x, y, red)
+				changed++
+				continue
+			}
+			gray := uint8((299*r2 + 587*g2 + 114*b2) / 1000 >> 8 / 3)
+			out.SetRGBA(x, y, color.RGBA{gray, gray, gray, 255})
+		}
+	}
+	return out, float64(changed) * 100 / float64(w*h)
+}
+
+func absDiff(a, b uint32) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+func maxInt(vals ...int) int {
+	m := vals[0]
+	for _, v := range vals[1:] {
+		if v > m {
+			m = v
+		}
+	}
+	return m
+}
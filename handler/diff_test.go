@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func filled(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestDiffImages(t *testing.T) {
+	black, white := filled(4, 4, color.Black), filled(4, 4, color.White)
+	if _, p := diffImages(black, filled(4, 4, color.Black), defaultDiffTolerance); p != 0 {
+		t.Errorf("相同图片的变化比例为 %.2f%%", p)
+	}
+	out, p := diffImages(black, white, defaultDiffTolerance)
+	if p != 100 {
+		t.Errorf("完全不同的图片变化比例为 %.2f%%", p)
+	}
+	if got := out.RGBAAt(0, 0); got != (color.RGBA{255, 0, 0, 255}) {
+		t.Errorf("变化的像素为 %v，期望红色", got)
+	}
+
+	// 只有一个像素变化，且不超过阈值的差异不算变化
+	half := filled(4, 4, color.Black)
+	half.Set(0, 0, color.White)
+	half.Set(1, 0, color.Gray{Y: 20})
+	if _, p := diffImages(black, half, defaultDiffTolerance); p != 100.0/16 {
+		t.Errorf("一个像素变化时比例为 %.2f%%", p)
+	}
+}
+
+func TestDiffScreenshots(t *testing.T) {
+	h := newTestHandler(t)
+	addScreenshots(t, h, 2)
+	shots := h.store.All()
+
+	// 再汇报一张 8x8 的截图
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, filled(8, 8, color.White))
+	big := h.store.AddFor("dev1", "CaptureImage", "")
+	sr := screenshotRouter(h)
+	serve(sr, "POST", "/maa/getTask", `{"user":"u1","device":"dev1"}`)
+	if w := report(sr, big.ID, "SUCCESS", base64.StdEncoding.EncodeToString(buf.Bytes())); w.Code != http.StatusOK {
+		t.Fatalf("汇报截图返回 %d", w.Code)
+	}
+
+	r := gin.New()
+	r.GET("/screenshot/:id/diff/:other", h.DiffScreenshots)
+	w := serve(r, "GET", "/screenshot/"+shots[0].ID+"/diff/"+shots[1].ID, "")
+	if w.Code != http.StatusOK || w.Header().Get("X-Change-Percent") != "0.00" {
+		t.Errorf("相同截图返回 %d，变化比例 %q", w.Code, w.Header().Get("X-Change-Percent"))
+	}
+	if _, err := png.Decode(w.Body); err != nil {
+		t.Errorf("返回的不是 PNG: %v", err)
+	}
+
+	for _, c := range []struct {
+		name, path string
+		want       int
+	}{
+		{"分辨率不同", "/screenshot/" + shots[0].ID + "/diff/" + big.ID, http.StatusBadRequest},
+		{"任务不存在", "/screenshot/" + shots[0].ID + "/diff/nope", http.StatusNotFound},
+		{"错误的阈值", "/screenshot/" + shots[0].ID + "/diff/" + shots[1].ID + "?tolerance=300", http.StatusBadRequest},
+	} {
+		if w := serve(r, "GET", c.path, ""); w.Code != c.want {
+			t.Errorf("%s: 返回 %d，期望 %d", c.name, w.Code, c.want)
+		}
+	}
+}
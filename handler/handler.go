@@ -2,21 +2,53 @@ package handler
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/api"
 	"ArknightsMaaRemoter/store"
 )
 
 type Handler struct {
-	store *store.Store
+	dataDir   string
+	store     *store.Store
+	refs      *store.References
+	users     *store.Users
+	keys      *store.APIKeys
+	sessions  *store.Sessions
+	tokens    *store.DeviceTokens
+	auditLog  *store.Audit
+	settings  *store.Settings
+	guard     *loginGuard
+	opts      optionsHolder
+	templates templateCache
+	timelapse timelapseJobs
+	watchdog  *watchdog
+	share     *shareSigner
 }
 
-func New(s *store.Store) *Handler {
-	return &Handler{store: s}
+// New 创建 Handler。分享密钥、参考图、截图和延时回放等文件保存在 dataDir 中，为空时使用当前目录。
+func New(dataDir string, s *store.Store, refs *store.References, users *store.Users, keys *store.APIKeys, sessions *store.Sessions, tokens *store.DeviceTokens, audit *store.Audit, settings *store.Settings) *Handler {
+	return &Handler{
+		dataDir:  dataDir,
+		store:    s,
+		refs:     refs,
+		users:    users,
+		keys:     keys,
+		sessions: sessions,
+		tokens:   tokens,
+		auditLog: audit,
+		settings: settings,
+		guard:    newLoginGuard(),
+		share:    newShareSigner(filepath.Join(dataDir, shareKeyFile)),
+	}
 }
 
 // ── MAA 协议类型 ──────────────────────────────────────────────
@@ -50,8 +82,13 @@ type reportReq struct {
 func (h *Handler) GetTask(c *gin.Context) {
 	var req getTaskReq
 	_ = c.ShouldBindJSON(&req)
+	if !h.deviceTokenOK(c, req.Device) {
+		c.JSON(http.StatusForbidden, gin.H{})
+		return
+	}
+	h.store.Seen(req.User, req.Device)
 
-	pending := h.store.Pending()
+	pending := h.store.Pending(req.User, req.Device)
 	items := make([]taskItem, 0, len(pending))
 	for _, t := range pending {
 		items = append(items, taskItem{
@@ -71,91 +108,204 @@ func (h *Handler) ReportStatus(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{})
 		return
 	}
+	// MAA 只会汇报这两种结果
+	if req.Status != string(store.StatusSuccess) && req.Status != string(store.StatusFailed) {
+		c.JSON(http.StatusBadRequest, gin.H{})
+		return
+	}
+	if !h.deviceTokenOK(c, req.Device) {
+		c.JSON(http.StatusForbidden, gin.H{})
+		return
+	}
+	t := h.store.Get(req.Task)
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{})
+		return
+	}
+	// 先核对状态和设备再落盘截图，避免重复或伪造的汇报写入文件
+	if t.Done() {
+		c.JSON(http.StatusConflict, gin.H{})
+		return
+	}
+	if !t.AssignedTo(req.User, req.Device) {
+		log.Printf("拒绝任务 %s 的汇报：来自 %s/%s，任务下发给 %s/%s", req.Task, req.User, req.Device, t.User, t.Device)
+		c.JSON(http.StatusForbidden, gin.H{})
+		return
+	}
 
-	payload := req.Payload
-	if req.Payload != "" && req.Status == "SUCCESS" {
-		t := h.store.Get(req.Task)
-		if t != nil && isScreenshotTask(t.Type) {
-			if path, err := saveScreenshot(req.Task, req.Payload); err == nil {
+	status, payload := req.Status, req.Payload
+	screenshot := false
+	// 截图任务的 payload 只保存落盘后的文件路径，从不保存 MAA 发来的原始字符串，
+	// 否则伪造的汇报可以让截图下载端点读取任意文件。解码失败视为截图失败。
+	if isScreenshotTask(t.Type) {
+		payload = ""
+		if status == "SUCCESS" {
+			path, err := h.saveScreenshot(req.Task, req.Payload)
+			if err != nil {
+				log.Printf("任务 %s 的截图无法保存，标记为失败: %v", req.Task, err)
+				status = "FAILED"
+			} else {
 				payload = path
+				screenshot = true
 			}
 		}
 	}
 
-	h.store.Complete(req.Task, req.Status, payload)
+	if err := h.store.Complete(req.Task, req.User, req.Device, status, payload); err != nil {
+		if errors.Is(err, store.ErrTaskDone) {
+			c.JSON(http.StatusConflict, gin.H{})
+		} else {
+			c.JSON(http.StatusForbidden, gin.H{})
+		}
+		return
+	}
+	if screenshot {
+		go h.classifyScreenshot(req.Task, h.dataPath(payload))
+	}
 	c.JSON(http.StatusOK, gin.H{})
 }
 
 func isScreenshotTask(taskType string) bool {
-	return taskType == "CaptureImage" || taskType == "CaptureImageNow"
+	return api.IsScreenshotType(taskType)
 }
 
-func saveScreenshot(taskID, b64data string) (string, error) {
-	if err := os.MkdirAll("screenshots", 0755); err != nil {
-		return "", err
-	}
-	filename := fmt.Sprintf("screenshots/%s_%s.png",
-		time.Now().Format("20060102_150405"), taskID[:8])
+// saveScreenshot 把截图写入截图目录，返回保存在任务中的路径（相对路径相对于数据目录）
+func (h *Handler) saveScreenshot(taskID, b64data string) (string, error) {
 	data, err := base64.StdEncoding.DecodeString(b64data)
 	if err != nil {
 		return "", err
 	}
-	return filename, os.WriteFile(filename, data, 0644)
+	if len(data) == 0 {
+		return "", errors.New("截图为空")
+	}
+	dir := h.options().ScreenshotDir
+	if err := os.MkdirAll(h.dataPath(dir), 0755); err != nil {
+		return "", err
+	}
+	filename := filepath.Join(dir, fmt.Sprintf("%s_%s.png",
+		time.Now().Format("20060102_150405"), shortID(taskID)))
+	return filename, os.WriteFile(h.dataPath(filename), data, 0644)
 }
 
-// ── 管理端点 ──────────────────────────────────────────────────
+// shortID 返回 ID 的前 8 个字符，用于文件名
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
 
-type submitTaskReq struct {
-	Type   string `json:"type" binding:"required"`
-	Params string `json:"params"`
+// dataPath 把相对路径解析为数据目录中的路径
+func (h *Handler) dataPath(p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(h.dataDir, p)
 }
 
-// AdminAuth 是可选的 Bearer Token 认证中间件
-// 通过环境变量 ADMIN_TOKEN 配置，不设置则不鉴权
-func (h *Handler) AdminAuth() gin.HandlerFunc {
-	token := os.Getenv("ADMIN_TOKEN")
-	return func(c *gin.Context) {
-		if token == "" {
-			c.Next()
-			return
-		}
-		if c.GetHeader("Authorization") != "Bearer "+token {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
-			return
-		}
-		c.Next()
+// screenshotPath 返回成功的截图任务保存的文件路径。路径必须位于截图目录内，
+// 旧版本或伪造的汇报留下的其他路径一律视为没有截图。
+func (h *Handler) screenshotPath(t *store.Task) (string, bool) {
+	file := t.ScreenshotFile()
+	if file == "" {
+		return "", false
+	}
+	dir, err := filepath.Abs(h.dataPath(h.options().ScreenshotDir))
+	if err != nil {
+		return "", false
+	}
+	abs, err := filepath.Abs(h.dataPath(file))
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
 	}
+	return abs, true
 }
 
-// SubmitTask 向队列添加一个任务
+// ── 管理端点 ──────────────────────────────────────────────────
+
+// SubmitTask 向队列添加一个任务，任务类型须在当前角色或 API 密钥的允许范围内
 func (h *Handler) SubmitTask(c *gin.Context) {
-	var req submitTaskReq
+	var req api.SubmitTaskRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	t := h.store.Add(req.Type, req.Params)
+	if status, err := h.checkSubmit(c, &req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	t := &store.Task{Type: req.Type, Params: req.Params, Status: store.StatusPending, Device: req.Device, Priority: req.Priority}
+	if h.options().confirmRequired(req.Type) {
+		t.Status = store.StatusAwaitingApproval
+		t.SubmittedBy = actorName(c)
+		t = h.store.AddTask(t)
+		h.audit(c, "task.submit", t.ID, map[string]any{"type": t.Type, "params": t.Params, "device": t.Device, "priority": t.Priority, "awaiting_approval": true})
+		c.JSON(http.StatusAccepted, t)
+		return
+	}
+	t = h.store.AddTask(t)
+	h.audit(c, "task.submit", t.ID, map[string]any{"type": t.Type, "params": t.Params, "device": t.Device, "priority": t.Priority})
 	c.JSON(http.StatusOK, t)
 }
 
-// ListTasks 返回所有任务列表（最新在前）
+// checkSubmit 校验下发请求，出错时返回应答的状态码。
+// 限定了唯一设备的 API 密钥未指定设备时，会把 req.Device 填为该设备。
+func (h *Handler) checkSubmit(c *gin.Context, req *api.SubmitTaskRequest) (int, error) {
+	if !h.typeAllowed(c, req.Type) {
+		return http.StatusForbidden, errors.New("无权下发该类型任务")
+	}
+	// 限定了设备的 API 密钥只能给这些设备下发任务
+	if key := currentKey(c); key != nil && len(key.Devices) > 0 {
+		if req.Device == "" && len(key.Devices) == 1 {
+			req.Device = key.Devices[0]
+		}
+		if req.Device == "" || !key.DeviceAllowed(req.Device) {
+			return http.StatusForbidden, errors.New("该密钥无权给此设备下发任务")
+		}
+	}
+	// 参数格式和 Settings-* 的取值限定对所有人生效，包括管理员
+	if err := h.settings.Check(req.Device, req.Type, req.Params); err != nil {
+		return settingsErrorStatus(err), err
+	}
+	return http.StatusOK, nil
+}
+
+// ListTasks 返回所有任务列表（最新在前），可用 ?screen= 按画面标签过滤
 func (h *Handler) ListTasks(c *gin.Context) {
-	c.JSON(http.StatusOK, h.store.All())
+	filter := store.TaskFilter{
+		Screen: c.Query("screen"),
+		Allow:  func(t *store.Task) bool { return deviceAllowed(c, t.Device) },
+	}
+	tasks, _ := h.store.Query(filter, false, nil, 0)
+	c.JSON(http.StatusOK, tasks)
 }
 
 // GetScreenshot 提供截图文件下载
 func (h *Handler) GetScreenshot(c *gin.Context) {
 	id := c.Param("id")
 	t := h.store.Get(id)
-	if t == nil || t.Payload == "" {
+	if t == nil || !deviceAllowed(c, t.Device) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	file, ok := h.screenshotPath(t)
+	if !ok {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 		return
 	}
-	c.File(t.Payload)
+	c.File(file)
 }
 
-// Dashboard 提供简单的 Web 控制面板
+// Dashboard 提供简单的 Web 控制面板，未登录时跳转到登录页
 func (h *Handler) Dashboard(c *gin.Context) {
+	if sess, _ := h.sessionUser(c); sess == nil {
+		c.Redirect(http.StatusSeeOther, "/login")
+		return
+	}
 	c.Header("Content-Type", "text/html; charset=utf-8")
 	c.String(http.StatusOK, dashboardHTML)
 }
@@ -178,7 +328,7 @@ const dashboardHTML = `<!DOCTYPE html>
   .sub { color: #888; font-size: 13px; margin-bottom: 24px; }
   .toolbar { display: flex; gap: 8px; flex-wrap: wrap; align-items: center; margin-bottom: 16px; }
   select, input, button { padding: 7px 12px; border: 1px solid #ddd; border-radius: 6px; font-size: 14px; }
-  input[type=password] { width: 180px; }
+  #whoami { font-size: 13px; color: #374151; }
   button { background: #2563eb; color: #fff; border-color: #2563eb; cursor: pointer; }
   button:hover { background: #1d4ed8; }
   button.secondary { background: #f3f4f6; color: #374151; border-color: #d1d5db; }
@@ -190,7 +340,10 @@ const dashboardHTML = `<!DOCTYPE html>
   .PENDING { color: #92400e; background: #fef3c7; padding: 2px 7px; border-radius: 4px; font-size: 11px; }
   .SUCCESS { color: #065f46; background: #d1fae5; padding: 2px 7px; border-radius: 4px; font-size: 11px; }
   .FAILED  { color: #991b1b; background: #fee2e2; padding: 2px 7px; border-radius: 4px; font-size: 11px; }
+  .AWAITING_APPROVAL { color: #9a3412; background: #ffedd5; padding: 2px 7px; border-radius: 4px; font-size: 11px; }
+  .REJECTED { color: #4b5563; background: #e5e7eb; padding: 2px 7px; border-radius: 4px; font-size: 11px; }
   .id { font-family: monospace; font-size: 11px; color: #6b7280; }
+  .screen { color: #3730a3; background: #e0e7ff; padding: 2px 7px; border-radius: 4px; font-size: 11px; margin-left: 6px; }
   a { color: #2563eb; }
   #params-wrap { display: none; }
   .hint { font-size: 12px; color: #9ca3af; margin-left: 4px; }
@@ -263,9 +416,11 @@ const dashboardHTML = `<!DOCTYPE html>
     <input id="params" type="text" placeholder="参数值" style="width:160px" />
   </span>
   <button onclick="submit()">下发任务</button>
-  <input id="token" type="password" placeholder="Admin Token（可选）" />
   <button class="secondary" onclick="load()">刷新</button>
   <span class="hint" id="status"></span>
+  <span style="flex:1"></span>
+  <span id="whoami"></span>
+  <button class="secondary" onclick="logout()">退出登录</button>
 </div>
 
 <table>
@@ -287,10 +442,22 @@ const TIME_ICON = 'data:image/png;base64,iVBORw0KGgoAAAANSUhEUgAAABIAAAAUCAYAAAC
 const STATUS_NAMES = {
   'SUCCESS': '已完成',
   'FAILED':  '失败',
+  'AWAITING_APPROVAL': '待确认',
+  'REJECTED': '已拒绝',
 };
 
+// esc 转义插入 innerHTML 的文本。任务类型、画面标签、提交者等来自用户输入或导入包，不能当作 HTML
+function esc(s) {
+  return String(s ?? '').replace(/[&<>"']/g, c => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;' }[c]));
+}
+
+// jsArg 把字符串转为可放进 onclick="..." 的 JS 字面量
+function jsArg(s) {
+  return esc(JSON.stringify(String(s)));
+}
+
 function statusBadge(s) {
-  return '<span class="' + s + '">' + (STATUS_NAMES[s] || s) + '</span>';
+  return '<span class="' + esc(s) + '">' + esc(STATUS_NAMES[s] || s) + '</span>';
 }
 
 const TYPE_NAMES = {
@@ -315,7 +482,7 @@ const TYPE_NAMES = {
 
 function typeName(type) {
   const zh = TYPE_NAMES[type];
-  return zh ? zh + ' <span style="color:#9ca3af;font-size:11px">(' + type + ')</span>' : type;
+  return zh ? zh + ' <span style="color:#9ca3af;font-size:11px">(' + esc(type) + ')</span>' : esc(type);
 }
 
 const PARAMS_HINT = {
@@ -332,18 +499,31 @@ function onTypeChange() {
   }
 }
 
+// 登录状态由 HttpOnly Cookie 维持，写请求需附带 CSRF 令牌
+let csrf = '';
+
 function getHeaders() {
-  const token = document.getElementById('token').value;
-  const h = { 'Content-Type': 'application/json' };
-  if (token) h['Authorization'] = 'Bearer ' + token;
-  return h;
+  return { 'Content-Type': 'application/json', 'X-CSRF-Token': csrf };
+}
+
+async function whoami() {
+  const r = await fetch('/admin/me');
+  if (r.status === 401) { location = '/login'; return; }
+  const me = await r.json();
+  csrf = me.csrf || '';
+  document.getElementById('whoami').textContent = me.username + '（' + me.role + '）';
+}
+
+async function logout() {
+  await fetch('/logout', { method: 'POST', headers: getHeaders() });
+  location = '/login';
 }
 
 async function load() {
   document.getElementById('status').textContent = '加载中…';
   try {
     const r = await fetch('/admin/tasks', { headers: getHeaders() });
-    if (r.status === 401) { document.getElementById('status').textContent = 'Token 错误'; return; }
+    if (r.status === 401) { location = '/login'; return; }
     const tasks = await r.json();
     const tbody = document.getElementById('tasks');
     if (!tasks || tasks.length === 0) {
@@ -351,14 +531,21 @@ async function load() {
     } else {
       tbody.innerHTML = tasks.map(t => {
         const isScreenshot = (t.type === 'CaptureImage' || t.type === 'CaptureImageNow');
-        const action = (isScreenshot && t.status === 'SUCCESS')
-          ? '<a href="/admin/screenshot/' + t.id + '" target="_blank">查看截图</a>'
+        let action = (isScreenshot && t.status === 'SUCCESS')
+          ? '<a href="/admin/screenshot/' + encodeURIComponent(t.id) + '" target="_blank">查看截图</a> · ' +
+            '<a href="#" onclick="share(' + jsArg(t.id) + ');return false">复制分享链接</a>' +
+            (t.screen ? '<span class="screen">' + esc(t.screen) + '</span>' : '')
           : '-';
+        if (t.status === 'AWAITING_APPROVAL') {
+          action = esc(t.submitted_by) + ' 提交 · ' +
+            '<a href="#" onclick="decide(' + jsArg(t.id) + ', \'approve\');return false">确认</a> · ' +
+            '<a href="#" onclick="decide(' + jsArg(t.id) + ', \'reject\');return false">拒绝</a>';
+        }
         return '<tr>' +
           '<td><img src="' + TIME_ICON + '" style="width:16px;height:16px;vertical-align:middle;margin-right:5px">' + new Date(t.created_at).toLocaleString('zh-CN') + '</td>' +
           '<td>' + typeName(t.type) + '</td>' +
           '<td>' + statusBadge(t.status) + '</td>' +
-          '<td class="id">' + t.id + '</td>' +
+          '<td class="id">' + esc(t.id) + '</td>' +
           '<td>' + action + '</td>' +
           '</tr>';
       }).join('');
@@ -369,17 +556,36 @@ async function load() {
   }
 }
 
+// 签发一个 24 小时有效的分享链接，无需登录即可打开，方便转发
+async function share(id) {
+  const r = await fetch('/admin/screenshot/' + encodeURIComponent(id) + '/share', { method: 'POST', headers: getHeaders() });
+  if (!r.ok) { alert(r.status === 403 ? '没有权限' : '截图不存在'); return; }
+  const url = location.origin + (await r.json()).url;
+  try { await navigator.clipboard.writeText(url); alert('已复制分享链接（24 小时内有效）'); }
+  catch (e) { prompt('分享链接（24 小时内有效）', url); }
+}
+
+// 确认或拒绝待确认的任务
+async function decide(id, op) {
+  const r = await fetch('/admin/task/' + encodeURIComponent(id) + '/' + op, { method: 'POST', headers: getHeaders() });
+  if (!r.ok) { alert((await r.json()).error || '操作失败'); return; }
+  load();
+}
+
 async function submit() {
   const type = document.getElementById('type').value;
   const params = document.getElementById('params').value;
   const body = { type };
   if (params) body.params = params;
   const r = await fetch('/admin/task', { method: 'POST', headers: getHeaders(), body: JSON.stringify(body) });
-  if (r.status === 401) { alert('Token 错误'); return; }
+  if (r.status === 401) { location = '/login'; return; }
+  if (r.status === 403 || r.status === 400) { alert((await r.json()).error || '当前账号无权下发该任务'); return; }
+  if (r.status === 202) alert('该任务需要确认后才会下发，请他人确认，或稍后自己在任务列表中确认');
   document.getElementById('params').value = '';
   load();
 }
 
+whoami();
 load();
 setInterval(load, 2000);
 </script>
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/store"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// newTestHandler 创建数据目录为临时目录的 Handler
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	dir := t.TempDir()
+	return New(dir, store.New(dir), store.NewReferences(dir), store.NewUsers(dir), store.NewAPIKeys(dir), store.NewSessions(dir, time.Hour), store.NewDeviceTokens(dir), store.NewAudit(dir), store.NewSettings(dir))
+}
+
+func serve(r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func testPNG() string {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)))
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// screenshotRouter 注册 MAA 协议端点和不经认证的截图端点
+func screenshotRouter(h *Handler) *gin.Engine {
+	r := gin.New()
+	r.POST("/maa/getTask", h.GetTask)
+	r.POST("/maa/reportStatus", h.ReportStatus)
+	r.GET("/screenshot/:id", h.GetScreenshot)
+	r.POST("/screenshot/:id/share", h.ShareScreenshot)
+	r.GET("/s/:id", h.SharedScreenshot)
+	return r
+}
+
+func report(r http.Handler, id, status, payload string) *httptest.ResponseRecorder {
+	body := fmt.Sprintf(`{"user":"u1","device":"dev1","task":%q,"status":%q,"payload":%q}`, id, status, payload)
+	return serve(r, "POST", "/maa/reportStatus", body)
+}
+
+func TestReportScreenshotNotImage(t *testing.T) {
+	h := newTestHandler(t)
+	r := screenshotRouter(h)
+	if err := os.WriteFile(filepath.Join(h.dataDir, "secret.txt"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, payload := range []string{"secret.txt", "", "不是 base64"} {
+		task := h.store.AddFor("", "CaptureImageNow", "")
+		serve(r, "POST", "/maa/getTask", `{"user":"u1","device":"dev1"}`)
+		if w := report(r, task.ID, "SUCCESS", payload); w.Code != http.StatusOK {
+			t.Fatalf("汇报返回 %d", w.Code)
+		}
+		got := h.store.Get(task.ID)
+		if got.Status != store.StatusFailed || got.Payload != "" {
+			t.Errorf("payload %q: 状态 %s、payload %q，期望 FAILED 且为空", payload, got.Status, got.Payload)
+		}
+		if w := serve(r, "GET", "/screenshot/"+task.ID, ""); w.Code != http.StatusNotFound {
+			t.Errorf("payload %q: 下载截图返回 %d，期望 404", payload, w.Code)
+		}
+	}
+}
+
+func TestScreenshotOutsideDir(t *testing.T) {
+	h := newTestHandler(t)
+	r := screenshotRouter(h)
+	if err := os.WriteFile(filepath.Join(h.dataDir, "secret.txt"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// 旧版本可能保存了截图目录以外的路径，直接写入存储模拟这种数据
+	for _, path := range []string{"secret.txt", "screenshots/../secret.txt", "screenshots"} {
+		task := h.store.AddFor("dev1", "CaptureImageNow", "")
+		h.store.Pending("u1", "dev1")
+		if err := h.store.Complete(task.ID, "u1", "dev1", "SUCCESS", path); err != nil {
+			t.Fatal(err)
+		}
+		if w := serve(r, "GET", "/screenshot/"+task.ID, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s: 下载截图返回 %d，期望 404", path, w.Code)
+		}
+		if w := serve(r, "POST", "/screenshot/"+task.ID+"/share", ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s: 签发分享链接返回 %d，期望 404", path, w.Code)
+		}
+		link, _ := h.share.url(task.ID, time.Minute)
+		if w := serve(r, "GET", link, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s: 分享链接返回 %d，期望 404", path, w.Code)
+		}
+	}
+
+	// 正常汇报的截图可以下载和分享
+	task := h.store.AddFor("", "CaptureImageNow", "")
+	serve(r, "POST", "/maa/getTask", `{"user":"u1","device":"dev1"}`)
+	report(r, task.ID, "SUCCESS", testPNG())
+	if w := serve(r, "GET", "/screenshot/"+task.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("下载截图返回 %d，期望 200", w.Code)
+	}
+	link, _ := h.share.url(task.ID, time.Minute)
+	if w := serve(r, "GET", link, ""); w.Code != http.StatusOK {
+		t.Errorf("分享链接返回 %d，期望 200", w.Code)
+	}
+}
+
+func TestImportedShortIDScreenshot(t *testing.T) {
+	h := newTestHandler(t)
+	r := screenshotRouter(h)
+	// 导入包中不足 8 位的 ID 也能正常保存截图；含路径的 ID 不会被导入
+	h.store.Import([]*store.Task{
+		{ID: "abc", Type: "CaptureImageNow", Device: "dev1", Status: store.StatusPending, CreatedAt: time.Now()},
+		{ID: "/../../x", Type: "CaptureImageNow", Device: "dev1", Status: store.StatusPending, CreatedAt: time.Now()},
+	})
+	if h.store.Get("/../../x") != nil {
+		t.Fatal("含路径的 ID 被导入")
+	}
+	if _, err := h.store.Approve("abc", "bob", 0); err != nil {
+		t.Fatal(err)
+	}
+	serve(r, "POST", "/maa/getTask", `{"user":"u1","device":"dev1"}`)
+	if w := report(r, "abc", "SUCCESS", testPNG()); w.Code != http.StatusOK {
+		t.Fatalf("汇报返回 %d", w.Code)
+	}
+	got := h.store.Get("abc")
+	if got.Status != store.StatusSuccess || !strings.HasSuffix(got.Payload, "_abc.png") {
+		t.Errorf("汇报后为 %s %q", got.Status, got.Payload)
+	}
+	if w := serve(r, "GET", "/screenshot/abc", ""); w.Code != http.StatusOK {
+		t.Errorf("下载截图返回 %d", w.Code)
+	}
+}
+
+func TestShareTTLBounds(t *testing.T) {
+	h := newTestHandler(t)
+	r := screenshotRouter(h)
+	task := h.store.AddFor("", "CaptureImageNow", "")
+	serve(r, "POST", "/maa/getTask", `{"user":"u1","device":"dev1"}`)
+	report(r, task.ID, "SUCCESS", testPNG())
+
+	for ttl, want := range map[string]int{
+		"":     http.StatusOK,
+		"1m":   http.StatusOK,
+		"720h": http.StatusOK,
+		"1s":   http.StatusBadRequest,
+		"59s":  http.StatusBadRequest,
+		"721h": http.StatusBadRequest,
+		"-1h":  http.StatusBadRequest,
+		"abc":  http.StatusBadRequest,
+	} {
+		if w := serve(r, "POST", "/screenshot/"+task.ID+"/share?ttl="+ttl, ""); w.Code != want {
+			t.Errorf("ttl=%s 返回 %d，期望 %d", ttl, w.Code, want)
+		}
+	}
+}
+
+// adminRouter 注册经过认证和授权的管理端点，角色和 scope 与 main.go 一致
+func adminRouter(h *Handler) *gin.Engine {
+	r := gin.New()
+	viewer, operator := store.RoleViewer, store.RoleOperator
+	admin := r.Group("/admin", h.Authenticate())
+	admin.GET("/tasks/:id/wait", h.Allow(viewer, store.ScopeTasksRead), h.WaitTask)
+	admin.POST("/task", h.Allow(operator, store.ScopeTasksSubmit), h.SubmitTask)
+	admin.POST("/tasks/batch", h.Allow(operator, store.ScopeTasksSubmit), h.SubmitBatch)
+	admin.POST("/task/:id/approve", h.Allow(operator, ""), h.ApproveTask)
+	admin.POST("/task/:id/reject", h.Allow(operator, ""), h.RejectTask)
+	admin.GET("/queue", h.Allow(viewer, store.ScopeTasksRead), h.Queue)
+	admin.POST("/queue/:id/move", h.Allow(operator, store.ScopeTasksSubmit), h.MoveTask)
+	admin.POST("/devices/:device/screenshot", h.Allow(operator, store.ScopeTasksSubmit), h.Allow(viewer, store.ScopeScreenshotsRead), h.CaptureScreenshot)
+	admin.GET("/me", h.Me)
+	manage := admin.Group("", h.Allow(store.RoleAdmin, ""))
+	manage.POST("/users", h.CreateUser)
+	manage.PUT("/users/:name", h.UpdateUser)
+	manage.DELETE("/users/:name", h.DeleteUser)
+	manage.PUT("/roles/:role", h.SetRoleTypes)
+	return r
+}
+
+const testPassword = "test-password"
+
+// addUser 创建密码为 testPassword 的用户
+func addUser(t *testing.T, h *Handler, username string, role store.Role) {
+	t.Helper()
+	if _, err := h.users.Create(username, testPassword, role); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveAs 以 HTTP Basic 认证的 username 发送请求
+func serveAs(r http.Handler, username, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.SetBasicAuth(username, testPassword)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
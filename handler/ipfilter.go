@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ── IP 访问控制 ───────────────────────────────────────────────
+
+// IPFilter 按客户端 IP 过滤请求。allow、deny 为 CIDR 或单个 IP：
+// 命中 deny 的请求一律拒绝；allow 非空时只放行命中 allow 的请求。
+// 客户端 IP 取自 c.ClientIP()，只有来自受信任代理的请求才采用转发头。
+// 两个列表都为空时不做限制。规则可以用 Set 在运行时替换。
+type IPFilter struct {
+	name  string // 用于日志中区分规则组
+	rules atomic.Pointer[ipRules]
+}
+
+type ipRules struct {
+	allow, deny []*net.IPNet
+}
+
+func NewIPFilter(name string, allow, deny []string) (*IPFilter, error) {
+	f := &IPFilter{name: name}
+	if err := f.Set(allow, deny); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Set 替换规则，任一列表无效时保留原规则并返回错误
+func (f *IPFilter) Set(allow, deny []string) error {
+	allowNets, err := parseCIDRs(allow)
+	if err != nil {
+		return fmt.Errorf("%s 允许列表: %w", f.name, err)
+	}
+	denyNets, err := parseCIDRs(deny)
+	if err != nil {
+		return fmt.Errorf("%s 拒绝列表: %w", f.name, err)
+	}
+	f.rules.Store(&ipRules{allow: allowNets, deny: denyNets})
+	return nil
+}
+
+func (f *IPFilter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rules := f.rules.Load()
+		if len(rules.allow) == 0 && len(rules.deny) == 0 {
+			c.Next()
+			return
+		}
+		ip := net.ParseIP(c.ClientIP())
+		if ip == nil || containsIP(rules.deny, ip) || (len(rules.allow) > 0 && !containsIP(rules.allow, ip)) {
+			log.Printf("IP 规则 %s 拒绝 %s 访问 %s %s", f.name, c.ClientIP(), c.Request.Method, c.Request.URL.Path)
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// parseCIDRs 解析 CIDR 列表，单个 IP 视为只含该地址的网段
+func parseCIDRs(items []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, item := range items {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("无效的 IP: %q", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 CIDR: %q", item)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
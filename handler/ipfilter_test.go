@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func filterCode(t *testing.T, f *IPFilter, ip string) int {
+	t.Helper()
+	r := gin.New()
+	r.GET("/", f.Middleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = net.JoinHostPort(ip, "1234")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestIPFilter(t *testing.T) {
+	tests := []struct {
+		name        string
+		allow, deny []string
+		ip          string
+		want        int
+	}{
+		{"无规则", nil, nil, "203.0.113.5", http.StatusOK},
+		{"命中允许网段", []string{"192.168.1.0/24"}, nil, "192.168.1.20", http.StatusOK},
+		{"不在允许列表", []string{"192.168.1.0/24"}, nil, "192.168.2.20", http.StatusForbidden},
+		{"单个 IP", []string{"10.0.0.1"}, nil, "10.0.0.1", http.StatusOK},
+		{"单个 IP 不含邻居", []string{"10.0.0.1"}, nil, "10.0.0.2", http.StatusForbidden},
+		{"拒绝优先于允许", []string{"10.0.0.0/8"}, []string{"10.1.0.0/16"}, "10.1.2.3", http.StatusForbidden},
+		{"只有拒绝列表", nil, []string{"203.0.113.0/24"}, "198.51.100.1", http.StatusOK},
+		{"命中拒绝列表", nil, []string{"203.0.113.0/24"}, "203.0.113.9", http.StatusForbidden},
+		{"IPv6", []string{"fd00::/8"}, nil, "fd00::1", http.StatusOK},
+		{"IPv6 单个地址", []string{"::1"}, nil, "::1", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewIPFilter("test", tt.allow, tt.deny)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := filterCode(t, f, tt.ip); got != tt.want {
+				t.Errorf("%s 返回 %d，期望 %d", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPFilterSet(t *testing.T) {
+	for _, bad := range []string{"", "10.0.0", "10.0.0.0/33", "example.com"} {
+		if _, err := NewIPFilter("test", []string{bad}, nil); err == nil {
+			t.Errorf("允许列表 %q 未报错", bad)
+		}
+		if _, err := NewIPFilter("test", nil, []string{bad}); err == nil {
+			t.Errorf("拒绝列表 %q 未报错", bad)
+		}
+	}
+
+	f, err := NewIPFilter("test", []string{"192.168.1.0/24"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set([]string{"10.0.0.0/8"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if filterCode(t, f, "10.1.2.3") != http.StatusOK || filterCode(t, f, "192.168.1.20") != http.StatusForbidden {
+		t.Error("Set 后规则未生效")
+	}
+	// 无效的规则不替换原规则
+	if err := f.Set(nil, []string{"bad"}); err == nil {
+		t.Error("无效的拒绝列表未报错")
+	}
+	if filterCode(t, f, "10.1.2.3") != http.StatusOK || filterCode(t, f, "192.168.1.20") != http.StatusForbidden {
+		t.Error("Set 失败后规则被改变")
+	}
+}
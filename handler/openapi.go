@@ -0,0 +1,524 @@
+package handler
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/api"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── OpenAPI 文档 ──────────────────────────────────────────────
+//
+// 文档由下面的端点表生成，请求和响应的 schema 通过反射取自处理函数实际使用的 Go 类型，
+// 字段改动后文档随之更新。main_test.go 逐个请求 main.go 注册的所有路由，
+// 检查每个路由都在文档中，且状态码和响应体符合文档。
+
+// content 描述一种响应体：JSON 时 value 为 Go 类型的零值，其他类型时 media 为 MIME 类型
+type content struct {
+	value any
+	media []string
+}
+
+// 常用的响应体
+var (
+	emptyObject = content{value: struct{}{}}
+	maaError    = content{value: struct {
+		Error string `json:"error,omitempty"`
+	}{}}
+	pngImage = content{media: []string{"image/png"}}
+	htmlPage = content{media: []string{"text/html"}}
+	noBody   = content{}
+)
+
+func jsonOf(v any) content { return content{value: v} }
+
+type param struct {
+	name, desc string
+	schema     map[string]any
+}
+
+type endpoint struct {
+	method, path string // path 为 Gin 的写法，如 /tasks/:id
+	summary      string
+	query        []param
+	body         any  // 请求体的 Go 类型零值，nil 表示没有
+	form         bool // 请求体为表单（multipart/form-data 或 urlencoded）
+	responses    map[int]content
+}
+
+var (
+	stringParam  = map[string]any{"type": "string"}
+	intParam     = map[string]any{"type": "integer"}
+	timeParam    = map[string]any{"type": "string", "format": "date-time"}
+	durationDesc = "Go 时长格式，如 30m、24h"
+)
+
+// maaEndpoints 是 MAA 远程控制协议的端点，另有带设备令牌的 /maa/:token/... 版本
+var maaEndpoints = []endpoint{
+	{method: "POST", path: "/getTask", summary: "MAA 轮询获取待执行的任务", body: getTaskReq{},
+		responses: map[int]content{200: jsonOf(getTaskResp{}), 403: maaError}},
+	{method: "POST", path: "/reportStatus", summary: "MAA 汇报任务结果，截图任务的 payload 为 base64 PNG", body: reportReq{},
+		responses: map[int]content{200: emptyObject, 400: maaError, 403: maaError, 404: maaError, 409: maaError}},
+}
+
+// adminEndpoints 是 /admin 和 /api/v1 共有的管理端点
+var adminEndpoints = []endpoint{
+	{method: "GET", path: "/me", summary: "当前认证身份；API 密钥返回密钥信息",
+		responses: map[int]content{200: {value: []any{api.Identity{}, store.APIKey{}}}}},
+	{method: "GET", path: "/alerts", summary: "最近的卡死告警，最新的在前",
+		responses: map[int]content{200: jsonOf([]api.Alert{})}},
+	{method: "GET", path: "/queue", summary: "尚未下发给 MAA 的任务，按下发顺序排列",
+		responses: map[int]content{200: jsonOf([]store.Task{})}},
+	{method: "POST", path: "/queue/:id/move", summary: "调整尚未下发任务的顺序，返回调整后的队列", body: api.MoveTaskRequest{},
+		responses: map[int]content{200: jsonOf([]store.Task{}), 404: {}, 409: {}}},
+	{method: "GET", path: "/devices", summary: "服务启动以来轮询过的设备",
+		responses: map[int]content{200: jsonOf([]store.Device{})}},
+	{method: "GET", path: "/devices/tokens", summary: "已配置令牌的设备",
+		responses: map[int]content{200: jsonOf([]store.DeviceToken{})}},
+	{method: "POST", path: "/devices/:device/token", summary: "生成或轮换设备令牌",
+		responses: map[int]content{200: jsonOf(deviceTokenResp{})}},
+	{method: "DELETE", path: "/devices/:device/token", summary: "删除设备令牌",
+		responses: map[int]content{200: emptyObject, 404: {}}},
+	{method: "POST", path: "/devices/:device/screenshot", summary: "立即截图并直接返回 PNG，任务 ID 见 X-Task-ID 响应头；设备离线或超时返回 504",
+		query:     []param{{"timeout", "最长等待时间，默认 30s，最长 5m；" + durationDesc + "，或秒数", stringParam}},
+		responses: map[int]content{200: pngImage, 400: {}, 409: {}, 502: {}, 504: {}}},
+
+	{method: "GET", path: "/screenshot/:id", summary: "下载截图",
+		responses: map[int]content{200: pngImage, 404: {}}},
+	{method: "GET", path: "/screenshot/:id/diff/:other", summary: "对比两张截图，变化比例见 X-Change-Percent 响应头",
+		query:     []param{{"tolerance", "单通道差值阈值 0-255，默认 32", intParam}},
+		responses: map[int]content{200: pngImage, 400: {}, 404: {}}},
+	{method: "POST", path: "/screenshot/:id/share", summary: "签发截图分享链接",
+		query:     []param{{"ttl", "有效期，默认 24h，最短 1m，最长 720h；" + durationDesc, stringParam}},
+		responses: map[int]content{200: jsonOf(shareResp{}), 400: {}, 404: {}}},
+	{method: "POST", path: "/timelapse", summary: "后台生成延时回放，截图超过 200 张时均匀抽取", body: timelapseReq{},
+		responses: map[int]content{202: jsonOf(timelapseJob{}), 400: {}, 403: {}, 404: {}}},
+	{method: "GET", path: "/timelapse/:id", summary: "延时回放的生成状态",
+		responses: map[int]content{200: jsonOf(timelapseJob{}), 404: {}}},
+	{method: "GET", path: "/timelapse/:id/download", summary: "下载生成好的延时回放",
+		responses: map[int]content{200: {media: []string{"image/gif", "application/zip"}}, 404: {}}},
+	{method: "DELETE", path: "/timelapse/:id", summary: "取消生成中的延时回放，或删除已生成的文件",
+		responses: map[int]content{200: emptyObject, 404: {}}},
+
+	{method: "GET", path: "/references", summary: "画面识别参考图",
+		responses: map[int]content{200: jsonOf([]store.Reference{})}},
+	{method: "POST", path: "/references", summary: "上传参考图（表单字段 label、image，可选 threshold）", form: true,
+		responses: map[int]content{200: jsonOf(store.Reference{}), 400: {}, 500: {}}},
+	{method: "DELETE", path: "/references/:id", summary: "删除参考图",
+		responses: map[int]content{200: emptyObject, 404: {}}},
+	{method: "POST", path: "/share/rotate", summary: "轮换分享签名密钥，已签发的链接全部失效",
+		responses: map[int]content{200: emptyObject, 500: {}}},
+
+	{method: "GET", path: "/users", summary: "所有用户",
+		responses: map[int]content{200: jsonOf([]store.User{})}},
+	{method: "POST", path: "/users", summary: "新建用户", body: userReq{},
+		responses: map[int]content{200: jsonOf(store.User{}), 400: {}, 409: {}}},
+	{method: "PUT", path: "/users/:name", summary: "修改密码或角色", body: userReq{},
+		responses: map[int]content{200: jsonOf(store.User{}), 400: {}, 404: {}, 409: {}}},
+	{method: "DELETE", path: "/users/:name", summary: "删除用户",
+		responses: map[int]content{200: emptyObject, 400: {}, 404: {}, 409: {}}},
+	{method: "GET", path: "/roles", summary: "各角色可下发的任务类型",
+		responses: map[int]content{200: jsonOf(map[store.Role][]string{})}},
+	{method: "PUT", path: "/roles/:role", summary: "设置角色可下发的任务类型", body: roleTypesReq{},
+		responses: map[int]content{200: jsonOf(map[store.Role][]string{}), 400: {}}},
+	{method: "GET", path: "/keys", summary: "所有 API 密钥",
+		responses: map[int]content{200: jsonOf([]store.APIKey{})}},
+	{method: "POST", path: "/keys", summary: "创建 API 密钥", body: createKeyReq{},
+		responses: map[int]content{200: jsonOf(createKeyResp{}), 400: {}}},
+	{method: "DELETE", path: "/keys/:id", summary: "吊销 API 密钥",
+		responses: map[int]content{200: emptyObject, 404: {}}},
+	{method: "GET", path: "/settings-rules", summary: "Settings-* 任务的下发规则",
+		responses: map[int]content{200: jsonOf(store.SettingsRules{})}},
+	{method: "PUT", path: "/settings-rules", summary: "替换 Settings-* 任务的下发规则", body: store.SettingsRules{},
+		responses: map[int]content{200: jsonOf(store.SettingsRules{}), 400: {}}},
+	{method: "GET", path: "/audit", summary: "查询审计日志，最新的在前", query: auditParams(true),
+		responses: map[int]content{200: jsonOf([]store.AuditEntry{}), 400: {}, 500: {}}},
+	{method: "GET", path: "/audit/export", summary: "导出审计日志",
+		query:     append(auditParams(false), param{"format", "jsonl（默认）或 csv", stringParam}),
+		responses: map[int]content{200: {media: []string{"application/x-ndjson", "text/csv"}}, 400: {}, 500: {}}},
+}
+
+// legacyEndpoints 是只在 /admin 下的任务端点
+var legacyEndpoints = []endpoint{
+	{method: "GET", path: "/tasks", summary: "所有任务，最新的在前",
+		query:     []param{{"screen", "画面标签", stringParam}},
+		responses: map[int]content{200: jsonOf([]store.Task{})}},
+	{method: "GET", path: "/tasks/:id/wait", summary: "等待任务结束，超时仍返回 200 和任务当前状态",
+		query:     []param{{"timeout", "最长等待时间，默认 30s，最长 5m；" + durationDesc + "，或秒数", stringParam}},
+		responses: map[int]content{200: jsonOf(api.TaskResult{}), 400: {}, 404: {}}},
+	{method: "POST", path: "/task", summary: "下发任务，需要确认的任务返回 202", body: api.SubmitTaskRequest{},
+		responses: map[int]content{200: jsonOf(store.Task{}), 202: jsonOf(store.Task{}), 400: {}}},
+	{method: "POST", path: "/tasks/batch", summary: "按顺序批量下发任务，任一任务不通过则全部不下发", body: api.SubmitBatchRequest{},
+		responses: map[int]content{200: jsonOf(api.SubmitBatchResponse{}), 400: {}, 403: {}}},
+	{method: "POST", path: "/task/:id/approve", summary: "确认任务，参数不符合 Settings 任务规则时返回 400 或 403",
+		responses: map[int]content{200: jsonOf(store.Task{}), 400: {}, 403: {}, 404: {}, 409: {}}},
+	{method: "POST", path: "/task/:id/reject", summary: "拒绝任务",
+		responses: map[int]content{200: jsonOf(store.Task{}), 404: {}, 409: {}}},
+}
+
+// v1Endpoints 是只在 /api/v1 下的任务端点
+var v1Endpoints = []endpoint{
+	{method: "GET", path: "/tasks", summary: "分页查询任务", query: []param{
+		{"id", "任务 ID 前缀", stringParam},
+		{"status", "状态，逗号分隔", stringParam},
+		{"type", "任务类型，逗号分隔，末尾 * 表示前缀匹配", stringParam},
+		{"device", "设备标识符", stringParam},
+		{"screen", "画面标签", stringParam},
+		{"since", "创建时间下限", timeParam},
+		{"until", "创建时间上限", timeParam},
+		{"sort", "created_at 或 -created_at（默认）", stringParam},
+		{"limit", "每页条数，默认 50，最大 500", intParam},
+		{"cursor", "上一页返回的 next_cursor", stringParam},
+	}, responses: map[int]content{200: jsonOf(api.TaskList{}), 400: {}}},
+	{method: "GET", path: "/tasks/:id", summary: "获取单个任务",
+		responses: map[int]content{200: jsonOf(store.Task{}), 404: {}}},
+	{method: "GET", path: "/tasks/:id/wait", summary: "等待任务结束，超时仍返回 200 和任务当前状态",
+		query:     []param{{"timeout", "最长等待时间，默认 30s，最长 5m；" + durationDesc + "，或秒数", stringParam}},
+		responses: map[int]content{200: jsonOf(api.TaskResult{}), 400: {}, 404: {}}},
+	{method: "POST", path: "/tasks", summary: "下发任务，需要确认的任务返回 202", body: api.SubmitTaskRequest{},
+		responses: map[int]content{200: jsonOf(store.Task{}), 202: jsonOf(store.Task{}), 400: {}}},
+	{method: "POST", path: "/tasks/batch", summary: "按顺序批量下发任务，任一任务不通过则全部不下发", body: api.SubmitBatchRequest{},
+		responses: map[int]content{200: jsonOf(api.SubmitBatchResponse{}), 400: {}, 403: {}}},
+	{method: "POST", path: "/tasks/:id/approve", summary: "确认任务，参数不符合 Settings 任务规则时返回 400 或 403",
+		responses: map[int]content{200: jsonOf(store.Task{}), 400: {}, 403: {}, 404: {}, 409: {}}},
+	{method: "POST", path: "/tasks/:id/reject", summary: "拒绝任务",
+		responses: map[int]content{200: jsonOf(store.Task{}), 404: {}, 409: {}}},
+}
+
+// publicEndpoints 不需要登录
+var publicEndpoints = []endpoint{
+	{method: "GET", path: "/s/:id", summary: "通过分享链接查看截图",
+		query:     []param{{"exp", "过期时间（Unix 秒）", intParam}, {"sig", "签名", stringParam}},
+		responses: map[int]content{200: pngImage, 403: {}, 404: {}, 429: {}}},
+	{method: "GET", path: "/api/openapi.json", summary: "本文档",
+		responses: map[int]content{200: {media: []string{"application/json"}}, 403: {}, 429: {}}},
+	{method: "GET", path: "/", summary: "控制面板，未登录时跳转到登录页",
+		responses: map[int]content{200: htmlPage, 303: noBody, 403: {}}},
+	{method: "GET", path: "/login", summary: "登录页",
+		responses: map[int]content{200: htmlPage, 303: noBody, 403: {}}},
+	{method: "POST", path: "/login", summary: "登录（表单字段 username、password）", form: true,
+		responses: map[int]content{303: noBody, 401: htmlPage, 403: htmlPage, 413: {}, 429: htmlPage}},
+	{method: "POST", path: "/logout", summary: "注销",
+		responses: map[int]content{303: noBody, 403: {}, 413: {}, 429: {}}},
+	{method: "GET", path: "/static/*filepath", summary: "控制面板的静态文件",
+		responses: map[int]content{200: {media: []string{"*/*"}}, 403: {}, 404: {media: []string{"text/plain"}}}},
+	{method: "HEAD", path: "/static/*filepath", summary: "控制面板的静态文件",
+		responses: map[int]content{200: {media: []string{"*/*"}}, 403: {}, 404: {media: []string{"text/plain"}}}},
+}
+
+func auditParams(limit bool) []param {
+	params := []param{
+		{"actor", "操作者，API 密钥为 key:<名称>", stringParam},
+		{"action", "操作，末尾 * 表示前缀匹配", stringParam},
+		{"since", "时间下限", timeParam},
+		{"until", "时间上限", timeParam},
+	}
+	if limit {
+		params = append(params, param{"limit", "条数，默认 200，0 表示全部", intParam})
+	}
+	return params
+}
+
+var openAPI struct {
+	once sync.Once
+	doc  map[string]any
+}
+
+// OpenAPI 返回 OpenAPI 3 文档
+func (h *Handler) OpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, OpenAPIDoc())
+}
+
+// OpenAPIDoc 生成 OpenAPI 3 文档，结果会被缓存
+func OpenAPIDoc() map[string]any {
+	openAPI.once.Do(func() {
+		g := &schemaGen{components: make(map[string]any)}
+		paths := make(map[string]map[string]any)
+		add := func(prefix, tag string, errSchema content, auth bool, endpoints []endpoint) {
+			for _, e := range endpoints {
+				path := openAPIPath(prefix + e.path)
+				if paths[path] == nil {
+					paths[path] = make(map[string]any)
+				}
+				paths[path][strings.ToLower(e.method)] = g.operation(prefix+e.path, tag, e, errSchema, auth)
+			}
+		}
+		add("/maa", "maa", maaError, false, maaEndpoints)
+		add("/maa/:token", "maa", maaError, false, maaEndpoints)
+		add("/admin", "admin", jsonOf(api.Error{}), true, legacyEndpoints)
+		add("/admin", "admin", jsonOf(api.Error{}), true, adminEndpoints)
+		add("/api/v1", "v1", jsonOf(api.ErrorEnvelope{}), true, v1Endpoints)
+		add("/api/v1", "v1", jsonOf(api.ErrorEnvelope{}), true, adminEndpoints)
+		add("", "public", jsonOf(api.Error{}), false, publicEndpoints)
+
+		openAPI.doc = map[string]any{
+			"openapi": "3.0.3",
+			"info": map[string]any{
+				"title":   "MAA Remote",
+				"version": "1",
+				"description": "MAA 远程控制协议端点（/maa）和管理 API。/api/v1 与 /admin 共用处理函数，" +
+					"/api/v1 的错误统一为 ErrorEnvelope，/admin 保持 {\"error\": \"说明\"}。",
+			},
+			"tags": []map[string]any{
+				{"name": "maa", "description": "MAA 轮询的协议端点，匿名或凭设备令牌访问"},
+				{"name": "admin", "description": "管理端点（旧路径）"},
+				{"name": "v1", "description": "版本化管理 API"},
+				{"name": "public", "description": "分享链接与控制面板"},
+			},
+			"paths": paths,
+			"components": map[string]any{
+				"schemas": g.components,
+				"securitySchemes": map[string]any{
+					"basic":   map[string]any{"type": "http", "scheme": "basic"},
+					"bearer":  map[string]any{"type": "http", "scheme": "bearer", "description": "API 密钥（maa_ 开头）或 auth.admin_token"},
+					"session": map[string]any{"type": "apiKey", "in": "cookie", "name": sessionCookie, "description": "控制面板会话，写请求须带 " + csrfHeader + " 头"},
+				},
+			},
+		}
+	})
+	return openAPI.doc
+}
+
+// openAPIPath 把 Gin 的 :id、*filepath 转换为 {id}、{filepath}
+func openAPIPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
+			parts[i] = "{" + p[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (g *schemaGen) operation(path, tag string, e endpoint, errSchema content, auth bool) map[string]any {
+	op := map[string]any{
+		"tags":        []string{tag},
+		"summary":     e.summary,
+		"operationId": strings.ToLower(e.method) + strings.NewReplacer("/", "_", ":", "", "*", "", "-", "_", ".", "_").Replace(path),
+	}
+
+	var params []map[string]any
+	for _, p := range strings.Split(path, "/") {
+		if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
+			params = append(params, map[string]any{"name": p[1:], "in": "path", "required": true, "schema": stringParam})
+		}
+	}
+	for _, p := range e.query {
+		params = append(params, map[string]any{"name": p.name, "in": "query", "description": p.desc, "schema": p.schema})
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	switch {
+	case e.form:
+		op["requestBody"] = map[string]any{"content": map[string]any{
+			"multipart/form-data":               map[string]any{"schema": map[string]any{"type": "object"}},
+			"application/x-www-form-urlencoded": map[string]any{"schema": map[string]any{"type": "object"}},
+		}}
+	case e.body != nil:
+		op["requestBody"] = map[string]any{"required": true, "content": map[string]any{
+			"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(e.body), true)},
+		}}
+	}
+
+	responses := make(map[int]content)
+	for status, c := range e.responses {
+		responses[status] = c
+	}
+	if auth {
+		// 认证、授权、请求体大小和限流由中间件处理，所有管理端点都可能返回
+		for _, status := range []int{400, 401, 403, 413, 429} {
+			if _, ok := responses[status]; !ok && (status != 400 || e.body != nil) {
+				responses[status] = content{}
+			}
+		}
+	}
+	if strings.HasPrefix(path, "/maa") {
+		for _, status := range []int{413, 429} {
+			responses[status] = content{}
+		}
+	}
+	out := make(map[string]any)
+	for status, c := range responses {
+		if c.value == nil && c.media == nil && status >= 400 {
+			c = errSchema
+		}
+		out[strconv.Itoa(status)] = g.response(status, c)
+	}
+	op["responses"] = out
+	if auth {
+		op["security"] = []map[string][]string{{"basic": {}}, {"bearer": {}}, {"session": {}}}
+	}
+	return op
+}
+
+func (g *schemaGen) response(status int, c content) map[string]any {
+	resp := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case c.value != nil:
+		var schema map[string]any
+		if alts, ok := c.value.([]any); ok {
+			var oneOf []map[string]any
+			for _, alt := range alts {
+				oneOf = append(oneOf, g.schema(reflect.TypeOf(alt), false))
+			}
+			schema = map[string]any{"oneOf": oneOf}
+		} else {
+			schema = g.schema(reflect.TypeOf(c.value), false)
+		}
+		resp["content"] = map[string]any{"application/json": map[string]any{"schema": schema}}
+	case c.media != nil:
+		media := make(map[string]any)
+		for _, m := range c.media {
+			media[m] = map[string]any{}
+		}
+		resp["content"] = media
+	}
+	return resp
+}
+
+// ── JSON schema 生成 ─────────────────────────────────────────
+
+// enums 是取值有限的字符串类型
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(store.Status("")): {
+		string(store.StatusPending), string(store.StatusSuccess), string(store.StatusFailed),
+		string(store.StatusAwaitingApproval), string(store.StatusRejected),
+	},
+	reflect.TypeOf(api.Move("")): {string(api.MoveUp), string(api.MoveDown), string(api.MoveFront)},
+	reflect.TypeOf(store.Scope("")): {
+		string(store.ScopeTasksSubmit), string(store.ScopeTasksRead),
+		string(store.ScopeScreenshotsRead), string(store.ScopeDevicesManage),
+	},
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaGen 把 Go 类型转换为 JSON schema。响应中的具名结构体放入 components 按名引用，
+// 字段没有 omitempty 即为必有；请求体的 schema 直接内联，只有 binding:"required" 的字段必填。
+type schemaGen struct {
+	components map[string]any
+}
+
+func (g *schemaGen) schema(t reflect.Type, request bool) map[string]any {
+	t = indirect(t)
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.Name() == "" || request {
+			return g.object(t, request)
+		}
+		name := componentName(t)
+		if _, ok := g.components[name]; !ok {
+			g.components[name] = nil // 先占位，防止递归类型无限展开
+			g.components[name] = g.object(t, false)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "format": "byte"}
+		}
+		return map[string]any{"type": "array", "items": g.schema(t.Elem(), request)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem(), request)}
+	case reflect.Interface:
+		return map[string]any{}
+	case reflect.String:
+		s := map[string]any{"type": "string"}
+		if values, ok := enums[t]; ok {
+			s["enum"] = values
+		}
+		return s
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	panic("openapi: 不支持的类型 " + t.String())
+}
+
+func (g *schemaGen) object(t reflect.Type, request bool) map[string]any {
+	props := make(map[string]any)
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			// 嵌入的结构体字段在 JSON 中展开到外层
+			embedded := g.object(indirect(f.Type), request)
+			for k, v := range embedded["properties"].(map[string]any) {
+				props[k] = v
+			}
+			if r, ok := embedded["required"].([]string); ok {
+				required = append(required, r...)
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		omitempty := strings.Contains(opts, "omitempty")
+		s := g.schema(f.Type, request)
+		switch f.Type.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+			// 没有 omitempty 的 nil 值编码为 null
+			if !omitempty && f.Type != timeType {
+				s = nullable(s)
+			}
+		}
+		props[name] = s
+		if request && strings.Contains(f.Tag.Get("binding"), "required") || !request && !omitempty {
+			required = append(required, name)
+		}
+	}
+	obj := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	if len(required) > 0 {
+		sort.Strings(required)
+		obj["required"] = required
+	}
+	return obj
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+	return t
+}
+
+func nullable(s map[string]any) map[string]any {
+	if _, ok := s["$ref"]; ok {
+		// OpenAPI 3.0 中 $ref 的兄弟字段会被忽略，只能套一层 allOf
+		return map[string]any{"allOf": []map[string]any{s}, "nullable": true}
+	}
+	out := map[string]any{"nullable": true}
+	for k, v := range s {
+		out[k] = v
+	}
+	return out
+}
+
+// componentName 取类型名并首字母大写，如 getTaskResp → GetTaskResp
+func componentName(t reflect.Type) string {
+	name := t.Name()
+	return strings.ToUpper(name[:1]) + name[1:]
+}
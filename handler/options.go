@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 运行时设置 ────────────────────────────────────────────────
+
+// Options 是来自配置文件的设置，收到 SIGHUP 后可以整体替换
+type Options struct {
+	ScreenshotDir string
+	AdminToken    string // 兼容旧版的 Bearer 令牌，为空表示不启用
+	MaxBody       int64
+	MaxReportBody int64
+
+	RequireDeviceToken bool // 拒绝所有不带设备令牌的 MAA 请求
+	LoginMaxFailures   int  // 同一 IP 连续认证失败多少次后锁定
+	LoginLockout       time.Duration
+
+	ConfirmTypes     []string // 需要双人确认的任务类型，末尾 * 表示前缀匹配
+	ConfirmSelfDelay time.Duration
+
+	WatchdogFrames    int // 连续多少帧几乎相同视为卡死
+	WatchdogThreshold int // 感知哈希的最大汉明距离
+	WatchdogStop      bool
+}
+
+var defaultOptions = Options{
+	ScreenshotDir:     "screenshots",
+	MaxBody:           16 << 20,
+	MaxReportBody:     100 << 20,
+	LoginMaxFailures:  5,
+	LoginLockout:      15 * time.Minute,
+	ConfirmTypes:      []string{"Toolbox-Gacha*", "Settings-*"},
+	ConfirmSelfDelay:  5 * time.Minute,
+	WatchdogFrames:    3,
+	WatchdogThreshold: 5,
+}
+
+type optionsHolder struct {
+	p atomic.Pointer[Options]
+}
+
+// SetOptions 替换运行时设置，之后的请求立即生效
+func (h *Handler) SetOptions(o Options) {
+	h.opts.p.Store(&o)
+	h.guard.set(o.LoginMaxFailures, o.LoginLockout)
+}
+
+func (h *Handler) options() *Options {
+	if o := h.opts.p.Load(); o != nil {
+		return o
+	}
+	return &defaultOptions
+}
+
+// confirmRequired 判断该类型的任务是否需要双人确认
+func (o *Options) confirmRequired(taskType string) bool {
+	for _, pattern := range o.ConfirmTypes {
+		if store.MatchType(pattern, taskType) {
+			return true
+		}
+	}
+	return false
+}
+
+// LimitBody 限制请求体大小，report 为真时使用汇报端点的上限
+func (h *Handler) LimitBody(report bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := h.options().MaxBody
+		if report {
+			limit = h.options().MaxReportBody
+		}
+		if c.Request.ContentLength > limit {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "请求体过大"})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
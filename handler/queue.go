@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/api"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 队列顺序 ──────────────────────────────────────────────────
+
+// Queue 返回尚未下发给 MAA 的任务，顺序即 MAA 下次轮询时拿到它们的顺序
+func (h *Handler) Queue(c *gin.Context) {
+	c.JSON(http.StatusOK, visibleTasks(c, h.store.Queue()))
+}
+
+// MoveTask 调整尚未下发任务的顺序（up、down、front），返回调整后的队列。
+// 已下发的任务 MAA 已在执行，无法再调整，返回 409。
+func (h *Handler) MoveTask(c *gin.Context) {
+	var req api.MoveTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	t := h.store.Get(c.Param("id"))
+	if t == nil || !deviceAllowed(c, t.Device) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+	if !h.typeAllowed(c, t.Type) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "无权调整该类型任务"})
+		return
+	}
+	queue, err := h.store.Move(t.ID, req.To)
+	switch {
+	case errors.Is(err, store.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "task.move", t.ID, map[string]any{"type": t.Type, "to": req.To})
+	c.JSON(http.StatusOK, visibleTasks(c, queue))
+}
+
+// visibleTasks 过滤掉限定了设备的 API 密钥无权查看的任务
+func visibleTasks(c *gin.Context, tasks []*store.Task) []*store.Task {
+	visible := make([]*store.Task, 0, len(tasks))
+	for _, t := range tasks {
+		if deviceAllowed(c, t.Device) {
+			visible = append(visible, t)
+		}
+	}
+	return visible
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ArknightsMaaRemoter/store"
+)
+
+// polledTypes 以 device 的身份轮询 /maa/getTask，返回拿到的任务类型
+func polledTypes(t *testing.T, r http.Handler, device string) string {
+	t.Helper()
+	w := serve(r, "POST", "/maa/getTask", fmt.Sprintf(`{"user":"u1","device":%q}`, device))
+	var resp getTaskResp
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	types := make([]string, len(resp.Tasks))
+	for i, item := range resp.Tasks {
+		types[i] = item.Type
+	}
+	return strings.Join(types, ",")
+}
+
+func TestQueueOrderForMAA(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	maa := maaRouter(h)
+	addUser(t, h, "op", store.RoleOperator)
+
+	ids := make(map[string]string)
+	for _, body := range []string{
+		`{"type":"LinkStart-Base"}`,
+		`{"type":"LinkStart-Combat","priority":10}`,
+		`{"type":"LinkStart-Mall","device":"dev2"}`,
+		`{"type":"LinkStart-Recruiting","priority":-1}`,
+		`{"type":"LinkStart-Mission","device":"dev1"}`,
+	} {
+		w := serveAs(r, "op", "POST", "/admin/task", body)
+		var task store.Task
+		if err := json.Unmarshal(w.Body.Bytes(), &task); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("下发 %s 返回 %d: %s", body, w.Code, w.Body)
+		}
+		ids[strings.TrimPrefix(task.Type, "LinkStart-")] = task.ID
+	}
+
+	move := func(name, to string) {
+		t.Helper()
+		w := serveAs(r, "op", "POST", "/admin/queue/"+ids[name]+"/move", `{"to":"`+to+`"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("移动 %s 返回 %d: %s", name, w.Code, w.Body)
+		}
+	}
+	// Recruiting 优先级最低，上移越过 Mission 后采用其优先级 0；再移到最前，采用 Combat 的优先级 10
+	move("Recruiting", "up")
+	move("Recruiting", "front")
+	// Mission 只下发给 dev1，上移时越过只下发给 dev2 的 Mall
+	move("Mission", "up")
+
+	if got := polledTypes(t, maa, "dev1"); got != "LinkStart-Recruiting,LinkStart-Combat,LinkStart-Mission,LinkStart-Base" {
+		t.Errorf("dev1 拿到的顺序为 %s", got)
+	}
+	if got := h.store.Get(ids["Recruiting"]).Priority; got != 10 {
+		t.Errorf("移到最前后优先级为 %d，期望 10", got)
+	}
+	// 已下发的未指定设备的任务按下发顺序排在最前
+	if got := polledTypes(t, maa, "dev2"); got != "LinkStart-Recruiting,LinkStart-Combat,LinkStart-Base,LinkStart-Mall" {
+		t.Errorf("dev2 拿到 %s", got)
+	}
+	// 已下发的任务不能再调整
+	if w := serveAs(r, "op", "POST", "/admin/queue/"+ids["Base"]+"/move", `{"to":"front"}`); w.Code != http.StatusConflict {
+		t.Errorf("移动已下发的任务返回 %d，期望 409", w.Code)
+	}
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ── 限流与防爆破 ──────────────────────────────────────────────
+
+// RateLimiter 是按客户端 IP 限流的令牌桶，rps 为每秒允许的请求数，
+// 突发上限为 4 倍 rps。rps <= 0 时不限流。客户端 IP 取自 c.ClientIP()，
+// 只有来自受信任代理的请求才会采用 X-Forwarded-For。
+type RateLimiter struct {
+	mu          sync.Mutex
+	rate, burst float64
+	buckets     map[string]*bucket
+	lastSweep   time.Time
+}
+
+func NewRateLimiter(rps float64) *RateLimiter {
+	l := &RateLimiter{buckets: make(map[string]*bucket)}
+	l.SetRate(rps)
+	return l
+}
+
+// SetRate 修改限流速率，已有的令牌桶按新速率继续累积
+func (l *RateLimiter) SetRate(rps float64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate, l.burst = rps, math.Max(rps*4, 1)
+}
+
+// Middleware 返回限流中间件
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if wait := l.take(c.ClientIP()); wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "请求过于频繁"})
+			return
+		}
+		c.Next()
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take 消耗一个令牌，令牌不足时返回需要等待的时间
+func (l *RateLimiter) take(ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return 0
+	}
+	now := time.Now()
+	if now.Sub(l.lastSweep) > time.Minute {
+		// 闲置到令牌已回满的桶没有保留的必要
+		for k, b := range l.buckets {
+			if now.Sub(b.last).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b := l.buckets[ip]
+	if b == nil {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// loginGuard 记录每个 IP 的认证失败次数，在 window 内失败 maxFails 次后锁定 lockout
+type loginGuard struct {
+	maxFails int
+	window   time.Duration
+	lockout  time.Duration
+
+	mu       sync.Mutex
+	attempts map[string]*attempt
+}
+
+type attempt struct {
+	fails       int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+func newLoginGuard() *loginGuard {
+	return &loginGuard{
+		maxFails: defaultOptions.LoginMaxFailures,
+		window:   15 * time.Minute,
+		lockout:  defaultOptions.LoginLockout,
+		attempts: make(map[string]*attempt),
+	}
+}
+
+// set 修改失败次数上限和锁定时长，已锁定的 IP 按原时长解锁
+func (g *loginGuard) set(maxFails int, lockout time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.maxFails, g.lockout = maxFails, lockout
+}
+
+// locked 返回该 IP 剩余的锁定时间，未锁定时为 0
+func (g *loginGuard) locked(ip string) time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	a := g.attempts[ip]
+	if a == nil {
+		return 0
+	}
+	if left := time.Until(a.lockedUntil); left > 0 {
+		return left
+	}
+	return 0
+}
+
+// fail 记录一次失败，达到上限时开始锁定
+func (g *loginGuard) fail(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	for k, a := range g.attempts {
+		if now.Sub(a.first) > g.window && now.After(a.lockedUntil) {
+			delete(g.attempts, k)
+		}
+	}
+	a := g.attempts[ip]
+	if a == nil {
+		a = &attempt{first: now}
+		g.attempts[ip] = a
+	}
+	a.fails++
+	if a.fails >= g.maxFails {
+		a.lockedUntil = now.Add(g.lockout)
+		a.fails = 0
+		a.first = now
+		log.Printf("IP %s 认证失败次数过多，锁定 %s", ip, g.lockout)
+	}
+}
+
+// succeed 清除该 IP 的失败记录
+func (g *loginGuard) succeed(ip string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.attempts, ip)
+}
+
+// abortLocked 在 IP 被锁定时返回 429 并中止请求
+func (g *loginGuard) abortLocked(c *gin.Context) bool {
+	left := g.locked(c.ClientIP())
+	if left <= 0 {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(left.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error": fmt.Sprintf("认证失败次数过多，请 %d 分钟后再试", int(math.Ceil(left.Minutes()))),
+	})
+	return true
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ArknightsMaaRemoter/store"
+)
+
+// serveFrom 以指定的用户名密码从 ip 发送请求
+func serveFrom(r http.Handler, ip, username, password, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(""))
+	req.RemoteAddr = ip + ":1234"
+	req.SetBasicAuth(username, password)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoginLockout(t *testing.T) {
+	h := newTestHandler(t)
+	r := loginRouter(h)
+	addUser(t, h, "op", store.RoleOperator)
+	opts := defaultOptions
+	opts.LoginMaxFailures = 3
+	h.SetOptions(opts)
+
+	const ip = "192.0.2.1"
+	for i := 0; i < 3; i++ {
+		if w := serveFrom(r, ip, "op", "wrong-password", "GET", "/admin/me"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("第 %d 次失败返回 %d", i+1, w.Code)
+		}
+	}
+	// 锁定期间正确的密码同样被拒绝
+	w := serveFrom(r, ip, "op", testPassword, "GET", "/admin/me")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("锁定后返回 %d，Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w, _ := login(r, "op", testPassword, ""); w.Code != http.StatusTooManyRequests {
+		t.Errorf("锁定后表单登录返回 %d", w.Code)
+	}
+	// 其他 IP 不受影响
+	if w := serveFrom(r, "192.0.2.2", "op", testPassword, "GET", "/admin/me"); w.Code != http.StatusOK {
+		t.Errorf("其他 IP 返回 %d", w.Code)
+	}
+	// 不带凭证的请求不计入失败，也不受锁定影响
+	if w := serve(r, "GET", "/admin/me", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("锁定后匿名请求返回 %d，期望 401", w.Code)
+	}
+}
+
+func TestLoginLockoutReset(t *testing.T) {
+	h := newTestHandler(t)
+	r := loginRouter(h)
+	addUser(t, h, "op", store.RoleOperator)
+	opts := defaultOptions
+	opts.LoginMaxFailures = 3
+	opts.LoginLockout = 50 * time.Millisecond
+	h.SetOptions(opts)
+
+	const ip = "192.0.2.1"
+	// 成功认证清除失败记录
+	for i := 0; i < 2; i++ {
+		serveFrom(r, ip, "op", "wrong-password", "GET", "/admin/me")
+	}
+	serveFrom(r, ip, "op", testPassword, "GET", "/admin/me")
+	for i := 0; i < 2; i++ {
+		serveFrom(r, ip, "op", "wrong-password", "GET", "/admin/me")
+	}
+	if w := serveFrom(r, ip, "op", testPassword, "GET", "/admin/me"); w.Code != http.StatusOK {
+		t.Fatalf("成功后失败次数未清零，返回 %d", w.Code)
+	}
+
+	// 表单登录（httptest 默认来自 192.0.2.1）的失败同样计数，锁定到期后自动解除
+	for i := 0; i < 3; i++ {
+		login(r, "op", "wrong-password", "")
+	}
+	if w := serveFrom(r, ip, "op", testPassword, "GET", "/admin/me"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("表单登录失败后返回 %d，期望 429", w.Code)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if w := serveFrom(r, ip, "op", testPassword, "GET", "/admin/me"); w.Code != http.StatusOK {
+		t.Errorf("锁定到期后返回 %d", w.Code)
+	}
+}
@@ -0,0 +1,371 @@
+package handler
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 画面识别 ──────────────────────────────────────────────────
+
+const (
+	referenceDir        = "references"
+	screenUnknown       = "unknown"
+	matchWidth          = 480  // 截图缩放到该宽度后再做模板匹配
+	defaultRefThreshold = 0.85 // 归一化互相关得分的默认阈值
+)
+
+// templateCache 缓存已解码的参考图，避免每张截图都重新读盘
+type templateCache struct {
+	mu     sync.Mutex
+	images map[string]image.Image
+}
+
+// get 返回参考图，file 是参考图文件在数据目录中的路径
+func (tc *templateCache) get(ref *store.Reference, file string) (image.Image, error) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if img, ok := tc.images[ref.ID]; ok {
+		return img, nil
+	}
+	img, err := loadImage(file)
+	if err != nil {
+		return nil, err
+	}
+	if tc.images == nil {
+		tc.images = make(map[string]image.Image)
+	}
+	tc.images[ref.ID] = img
+	return img, nil
+}
+
+func (tc *templateCache) drop(id string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	delete(tc.images, id)
+}
+
+// ListReferences 返回所有参考图
+func (h *Handler) ListReferences(c *gin.Context) {
+	c.JSON(http.StatusOK, h.refs.All())
+}
+
+// AddReference 上传一张参考图（multipart 表单：label、image，可选 threshold）
+func (h *Handler) AddReference(c *gin.Context) {
+	label := c.PostForm("label")
+	if label == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 label"})
+		return
+	}
+	threshold := defaultRefThreshold
+	if v := c.PostForm("threshold"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "threshold 必须在 (0, 1] 之间"})
+			return
+		}
+		threshold = f
+	}
+
+	fh, err := c.FormFile("image")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少 image 文件"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无法解析图片: " + err.Error()})
+		return
+	}
+
+	path, err := h.saveReference(img)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	ref := h.refs.Add(label, path, threshold)
+	h.audit(c, "reference.add", ref.ID, map[string]any{"label": label, "threshold": threshold})
+	c.JSON(http.StatusOK, ref)
+}
+
+// DeleteReference 删除参考图及其文件
+func (h *Handler) DeleteReference(c *gin.Context) {
+	ref := h.refs.Remove(c.Param("id"))
+	if ref == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	h.templates.drop(ref.ID)
+	_ = os.Remove(h.dataPath(ref.File))
+	h.audit(c, "reference.delete", ref.ID, map[string]any{"label": ref.Label})
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// saveReference 把参考图写入数据目录，返回相对于数据目录的路径
+func (h *Handler) saveReference(img image.Image) (string, error) {
+	if err := os.MkdirAll(h.dataPath(referenceDir), 0755); err != nil {
+		return "", err
+	}
+	filename := fmt.Sprintf("%s/%s_%s.png", referenceDir,
+		time.Now().Format("20060102_150405"), uuid.NewString()[:8])
+	f, err := os.Create(h.dataPath(filename))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return filename, png.Encode(f, img)
+}
+
+// classifyScreenshot 用所有参考图对截图打分，把得分最高且超过阈值的标签写入任务。
+// 没有参考图时不做任何事；都不匹配时标记为 unknown。
+func (h *Handler) classifyScreenshot(taskID, path string) {
+	refs := h.refs.All()
+	if len(refs) == 0 {
+		return
+	}
+	img, err := loadImage(path)
+	if err != nil {
+		return
+	}
+
+	b := img.Bounds()
+	scale := 1.0
+	if b.Dx() > matchWidth {
+		scale = float64(matchWidth) / float64(b.Dx())
+	}
+	screen := grayResize(img, scaled(b.Dx(), scale), scaled(b.Dy(), scale))
+
+	label, best := screenUnknown, -1.0
+	for _, ref := range refs {
+		tpl, err := h.templates.get(ref, h.dataPath(ref.File))
+		if err != nil {
+			continue
+		}
+		tb := tpl.Bounds()
+		tw, th := scaled(tb.Dx(), scale), scaled(tb.Dy(), scale)
+		if tw < 4 || th < 4 || tw > screen.w || th > screen.h {
+			continue
+		}
+		score := matchTemplate(screen, grayResize(tpl, tw, th))
+		if score >= ref.Threshold && score > best {
+			label, best = ref.Label, score
+		}
+	}
+	h.store.SetScreen(taskID, label)
+}
+
+func scaled(n int, scale float64) int {
+	return int(math.Round(float64(n) * scale))
+}
+
+type grayImage struct {
+	w, h int
+	pix  []float64
+}
+
+// grayResize 把图片缩放为 w×h 的灰度图，每个目标像素取源区域内若干采样点的平均值
+func grayResize(img image.Image, w, h int) *grayImage {
+	b := img.Bounds()
+	k := b.Dx() / w
+	if k < 1 {
+		k = 1
+	}
+	if k > 4 {
+		k = 4
+	}
+	g := &grayImage{w: w, h: h, pix: make([]float64, w*h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum float64
+			for sy := 0; sy < k; sy++ {
+				for sx := 0; sx < k; sx++ {
+					px := b.Min.X + (x*k+sx)*b.Dx()/(w*k)
+					py := b.Min.Y + (y*k+sy)*b.Dy()/(h*k)
+					r, gg, bl, _ := img.At(px, py).RGBA()
+					sum += (0.299*float64(r) + 0.587*float64(gg) + 0.114*float64(bl)) / 257
+				}
+			}
+			g.pix[y*w+x] = sum / float64(k*k)
+		}
+	}
+	return g
+}
+
+const (
+	coarseFactor     = 4 // 粗搜索时截图和模板再缩小的倍数
+	coarseMinSize    = 8 // 模板缩小后短于该边长时不做粗搜索，直接全图匹配
+	coarseCandidates = 8 // 粗搜索保留的候选位置数
+)
+
+// matchTemplate 返回 tpl 在 screen 中的最大归一化互相关得分（-1 到 1）。
+// 先把两者缩小 coarseFactor 倍全图搜索，再回到原尺寸只在得分最高的几个位置附近细化，
+// 计算量约为逐像素滑动的 1/coarseFactor⁴。
+func matchTemplate(screen, tpl *grayImage) float64 {
+	fine := newMatcher(screen, tpl)
+	if tpl.w/coarseFactor < coarseMinSize || tpl.h/coarseFactor < coarseMinSize {
+		return fine.best(0, 0, screen.w-tpl.w, screen.h-tpl.h)
+	}
+	coarse := newMatcher(screen.shrink(coarseFactor), tpl.shrink(coarseFactor))
+	best := -1.0
+	for _, p := range coarse.top(coarseCandidates) {
+		x, y := p.X*coarseFactor, p.Y*coarseFactor
+		if score := fine.best(x-coarseFactor, y-coarseFactor, x+coarseFactor, y+coarseFactor); score > best {
+			best = score
+		}
+	}
+	return best
+}
+
+// shrink 把图片按 f×f 的块取平均缩小 f 倍，不足一块的边缘舍去
+func (g *grayImage) shrink(f int) *grayImage {
+	s := &grayImage{w: g.w / f, h: g.h / f}
+	s.pix = make([]float64, s.w*s.h)
+	for y := 0; y < s.h*f; y++ {
+		for x := 0; x < s.w*f; x++ {
+			s.pix[(y/f)*s.w+x/f] += g.pix[y*g.w+x]
+		}
+	}
+	for i := range s.pix {
+		s.pix[i] /= float64(f * f)
+	}
+	return s
+}
+
+// matcher 计算模板在截图各位置的归一化互相关得分，积分图用于 O(1) 求窗口内的和与平方和
+type matcher struct {
+	screen, tpl *grayImage
+	n           float64
+	tMean       float64
+	tNorm       float64
+	tz          []float64 // 模板减去均值
+	sum, sq     []float64
+}
+
+func newMatcher(screen, tpl *grayImage) *matcher {
+	m := &matcher{screen: screen, tpl: tpl, n: float64(tpl.w * tpl.h)}
+	for _, v := range tpl.pix {
+		m.tMean += v
+	}
+	m.tMean /= m.n
+	m.tz = make([]float64, len(tpl.pix))
+	for i, v := range tpl.pix {
+		m.tz[i] = v - m.tMean
+		m.tNorm += m.tz[i] * m.tz[i]
+	}
+
+	iw := screen.w + 1
+	m.sum = make([]float64, iw*(screen.h+1))
+	m.sq = make([]float64, iw*(screen.h+1))
+	for y := 0; y < screen.h; y++ {
+		for x := 0; x < screen.w; x++ {
+			v := screen.pix[y*screen.w+x]
+			i := (y+1)*iw + x + 1
+			m.sum[i] = v + m.sum[i-1] + m.sum[i-iw] - m.sum[i-iw-1]
+			m.sq[i] = v*v + m.sq[i-1] + m.sq[i-iw] - m.sq[i-iw-1]
+		}
+	}
+	return m
+}
+
+func (m *matcher) rect(a []float64, x, y int) float64 {
+	iw := m.screen.w + 1
+	x2, y2 := x+m.tpl.w, y+m.tpl.h
+	return a[y2*iw+x2] - a[y*iw+x2] - a[y2*iw+x] + a[y*iw+x]
+}
+
+// score 返回模板左上角放在 (x, y) 时的得分
+func (m *matcher) score(x, y int) float64 {
+	s := m.rect(m.sum, x, y)
+	variance := m.rect(m.sq, x, y) - s*s/m.n
+	switch {
+	case m.tNorm == 0 && variance < 1e-6:
+		// 纯色模板只和纯色且亮度相近的区域匹配
+		return 1 - math.Abs(s/m.n-m.tMean)/255
+	case m.tNorm == 0 || variance < 1e-6:
+		return 0
+	}
+	var cross float64
+	for ty := 0; ty < m.tpl.h; ty++ {
+		row := m.screen.pix[(y+ty)*m.screen.w+x:]
+		trow := m.tz[ty*m.tpl.w:]
+		for tx := 0; tx < m.tpl.w; tx++ {
+			cross += trow[tx] * row[tx]
+		}
+	}
+	return cross / math.Sqrt(m.tNorm*variance)
+}
+
+// best 返回左上角在 [x0, x1]×[y0, y1] 范围内（超出截图的部分忽略）的最高得分
+func (m *matcher) best(x0, y0, x1, y1 int) float64 {
+	x0, y0 = max(x0, 0), max(y0, 0)
+	x1, y1 = min(x1, m.screen.w-m.tpl.w), min(y1, m.screen.h-m.tpl.h)
+	best := -1.0
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			if score := m.score(x, y); score > best {
+				best = score
+			}
+		}
+	}
+	return best
+}
+
+// top 返回得分最高的 k 个局部极大值的位置，从高到低排列。
+// 只取局部极大值，避免候选都挤在同一个峰附近。
+func (m *matcher) top(k int) []image.Point {
+	w, h := m.screen.w-m.tpl.w+1, m.screen.h-m.tpl.h+1
+	if w <= 0 || h <= 0 {
+		return nil
+	}
+	scores := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			scores[y*w+x] = m.score(x, y)
+		}
+	}
+	peak := func(x, y int) bool {
+		v := scores[y*w+x]
+		for ny := max(y-1, 0); ny <= min(y+1, h-1); ny++ {
+			for nx := max(x-1, 0); nx <= min(x+1, w-1); nx++ {
+				if scores[ny*w+nx] > v {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	var points []image.Point
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !peak(x, y) {
+				continue
+			}
+			points = append(points, image.Pt(x, y))
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return scores[points[i].Y*w+points[i].X] > scores[points[j].Y*w+points[j].X]
+	})
+	if len(points) > k {
+		points = points[:k]
+	}
+	return points
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"math"
+	"testing"
+)
+
+// pattern 生成平滑变化的灰度图，模拟缩小后的游戏截图
+func pattern(w, h int, fx, fy float64) *grayImage {
+	g := &grayImage{w: w, h: h, pix: make([]float64, w*h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			g.pix[y*w+x] = 128 + 60*math.Sin(float64(x)*fx)*math.Cos(float64(y)*fy) + 40*math.Sin(float64(x+2*y)*fx/3)
+		}
+	}
+	return g
+}
+
+func crop(g *grayImage, x0, y0, w, h int) *grayImage {
+	c := &grayImage{w: w, h: h, pix: make([]float64, w*h)}
+	for y := 0; y < h; y++ {
+		copy(c.pix[y*w:(y+1)*w], g.pix[(y0+y)*g.w+x0:])
+	}
+	return c
+}
+
+func TestMatchTemplate(t *testing.T) {
+	screen := pattern(matchWidth, 270, 0.07, 0.05)
+
+	// 粗搜索（较大的模板）和直接全图匹配（较小的模板），位置不与缩小倍数对齐
+	for _, c := range []struct{ x, y, w, h int }{
+		{123, 77, 120, 64},
+		{301, 150, 150, 100},
+		{57, 201, 20, 16},
+	} {
+		if score := matchTemplate(screen, crop(screen, c.x, c.y, c.w, c.h)); score < 0.99 {
+			t.Errorf("截图中的 %dx%d 区域 (%d, %d) 得分 %.3f，期望接近 1", c.w, c.h, c.x, c.y, score)
+		}
+	}
+
+	other := pattern(120, 64, 0.31, 0.23)
+	if score := matchTemplate(screen, other); score > 0.7 {
+		t.Errorf("截图中没有的图案得分 %.3f", score)
+	}
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 登录会话 ──────────────────────────────────────────────────
+
+const (
+	sessionCookie = "maa_session"
+	csrfHeader    = "X-CSRF-Token"
+	ctxSession    = "session"
+)
+
+// sessionUser 从 Cookie 中取出会话及其对应的用户；用户被删除后会话随之失效
+func (h *Handler) sessionUser(c *gin.Context) (*store.Session, *store.User) {
+	token, err := c.Cookie(sessionCookie)
+	if err != nil {
+		return nil, nil
+	}
+	sess := h.sessions.Get(token)
+	if sess == nil {
+		return nil, nil
+	}
+	user := h.users.Get(sess.Username)
+	if user == nil {
+		return nil, nil
+	}
+	return sess, user
+}
+
+// csrfOK 校验 Cookie 会话发起的写请求是否携带了正确的 CSRF 令牌
+func csrfOK(c *gin.Context, sess *store.Session) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	token := c.GetHeader(csrfHeader)
+	if token == "" {
+		token = c.PostForm("csrf")
+	}
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(sess.CSRF)) == 1
+}
+
+// sameOrigin 拒绝来自其他站点的表单提交（Origin 缺失时放行，兼容老浏览器）
+func sameOrigin(c *gin.Context) bool {
+	origin := c.GetHeader("Origin")
+	if origin == "" || origin == "null" {
+		return origin == ""
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == c.Request.Host
+}
+
+func isSecure(c *gin.Context) bool {
+	return c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+}
+
+func (h *Handler) setSessionCookie(c *gin.Context, token string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(sessionCookie, token, maxAge, "/", "", isSecure(c), true)
+}
+
+// LoginPage 显示登录表单，已登录时直接跳转到控制面板
+func (h *Handler) LoginPage(c *gin.Context) {
+	if sess, _ := h.sessionUser(c); sess != nil {
+		c.Redirect(http.StatusSeeOther, "/")
+		return
+	}
+	renderLogin(c, http.StatusOK, "")
+}
+
+// Login 校验用户名密码并建立会话
+func (h *Handler) Login(c *gin.Context) {
+	if !sameOrigin(c) {
+		renderLogin(c, http.StatusForbidden, "请求来源无效")
+		return
+	}
+	if left := h.guard.locked(c.ClientIP()); left > 0 {
+		renderLogin(c, http.StatusTooManyRequests,
+			fmt.Sprintf("登录失败次数过多，请 %d 分钟后再试", int(math.Ceil(left.Minutes()))))
+		return
+	}
+	user := h.users.Authenticate(c.PostForm("username"), c.PostForm("password"))
+	if user == nil {
+		h.guard.fail(c.ClientIP())
+		h.auditAs(c, c.PostForm("username"), "login.failed", "", nil)
+		renderLogin(c, http.StatusUnauthorized, "用户名或密码错误")
+		return
+	}
+	h.guard.succeed(c.ClientIP())
+	h.auditAs(c, user.Username, "login", "", nil)
+	token, _ := h.sessions.Create(user.Username)
+	h.setSessionCookie(c, token, int(h.sessions.TTL().Seconds()))
+	c.Redirect(http.StatusSeeOther, "/")
+}
+
+// Logout 注销当前会话
+func (h *Handler) Logout(c *gin.Context) {
+	if token, err := c.Cookie(sessionCookie); err == nil {
+		if sess := h.sessions.Get(token); sess != nil {
+			if !csrfOK(c, sess) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "csrf token 无效"})
+				return
+			}
+			h.auditAs(c, sess.Username, "logout", "", nil)
+		}
+		h.sessions.Delete(token)
+	}
+	h.setSessionCookie(c, "", -1)
+	c.Redirect(http.StatusSeeOther, "/login")
+}
+
+func renderLogin(c *gin.Context, status int, msg string) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	_ = loginTmpl.Execute(c.Writer, msg)
+}
+
+var loginTmpl = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="zh">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>登录 · MAA Remote</title>
+<link rel="icon" href="https://prts.wiki/favicon.ico">
+<style>
+  * { box-sizing: border-box; }
+  html { background-image: url('/static/bkg7.png'); background-size: cover; background-attachment: fixed; background-position: center; min-height: 100%; }
+  body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; max-width: 320px; margin: 120px auto; padding: 0 20px; color: #333; }
+  h1 { font-size: 22px; margin-bottom: 20px; }
+  form { display: flex; flex-direction: column; gap: 10px; }
+  input, button { padding: 9px 12px; border: 1px solid #ddd; border-radius: 6px; font-size: 14px; }
+  button { background: #2563eb; color: #fff; border-color: #2563eb; cursor: pointer; }
+  button:hover { background: #1d4ed8; }
+  .error { color: #991b1b; background: #fee2e2; padding: 8px 12px; border-radius: 6px; font-size: 13px; }
+</style>
+</head>
+<body>
+<h1>MAA Remote</h1>
+<form method="post" action="/login">
+  {{if .}}<div class="error">{{.}}</div>{{end}}
+  <input name="username" placeholder="用户名" autocomplete="username" required autofocus />
+  <input name="password" type="password" placeholder="密码" autocomplete="current-password" required />
+  <button type="submit">登录</button>
+</form>
+</body>
+</html>
+`))
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/api"
+	"ArknightsMaaRemoter/store"
+)
+
+func loginRouter(h *Handler) *gin.Engine {
+	r := adminRouter(h)
+	r.POST("/login", h.Login)
+	r.POST("/logout", h.Logout)
+	return r
+}
+
+// login 以表单登录，返回会话 Cookie；失败时返回 nil
+func login(r http.Handler, username, password, origin string) (*httptest.ResponseRecorder, *http.Cookie) {
+	form := url.Values{"username": {username}, "password": {password}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookie && c.Value != "" {
+			return w, c
+		}
+	}
+	return w, nil
+}
+
+// serveCookie 携带会话 Cookie 和可选的 CSRF 令牌发送请求
+func serveCookie(r http.Handler, cookie *http.Cookie, csrf, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.AddCookie(cookie)
+	if csrf != "" {
+		req.Header.Set(csrfHeader, csrf)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoginSession(t *testing.T) {
+	h := newTestHandler(t)
+	r := loginRouter(h)
+	addUser(t, h, "op", store.RoleOperator)
+	addUser(t, h, "boss", store.RoleAdmin)
+
+	if w, cookie := login(r, "op", "wrong-password", ""); w.Code != http.StatusUnauthorized || cookie != nil {
+		t.Errorf("错误的密码登录返回 %d，cookie %v", w.Code, cookie)
+	}
+	if w, cookie := login(r, "op", testPassword, "http://evil.example"); w.Code != http.StatusForbidden || cookie != nil {
+		t.Errorf("跨站登录返回 %d，cookie %v", w.Code, cookie)
+	}
+	w, cookie := login(r, "op", testPassword, "http://example.com")
+	if w.Code != http.StatusSeeOther || cookie == nil {
+		t.Fatalf("登录返回 %d", w.Code)
+	}
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("会话 Cookie 属性 %+v", cookie)
+	}
+
+	var me api.Identity
+	if w := serveCookie(r, cookie, "", "GET", "/admin/me", ""); w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &me) != nil || me.CSRF == "" {
+		t.Fatalf("/admin/me 返回 %d: %s", w.Code, w.Body)
+	}
+
+	// 写请求须携带 CSRF 令牌
+	submit := `{"type":"LinkStart"}`
+	for csrf, want := range map[string]int{"": http.StatusForbidden, "wrong": http.StatusForbidden, me.CSRF: http.StatusOK} {
+		if w := serveCookie(r, cookie, csrf, "POST", "/admin/task", submit); w.Code != want {
+			t.Errorf("CSRF %q 下发返回 %d，期望 %d", csrf, w.Code, want)
+		}
+	}
+
+	// 注销同样需要 CSRF 令牌，注销后会话失效
+	if w := serveCookie(r, cookie, "", "POST", "/logout", ""); w.Code != http.StatusForbidden {
+		t.Errorf("不带 CSRF 注销返回 %d", w.Code)
+	}
+	if w := serveCookie(r, cookie, me.CSRF, "POST", "/logout", ""); w.Code != http.StatusSeeOther {
+		t.Errorf("注销返回 %d", w.Code)
+	}
+	if w := serveCookie(r, cookie, "", "GET", "/admin/me", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("注销后访问返回 %d，期望 401", w.Code)
+	}
+}
+
+func TestPasswordChangeEndsSessions(t *testing.T) {
+	h := newTestHandler(t)
+	r := loginRouter(h)
+	addUser(t, h, "op", store.RoleOperator)
+	addUser(t, h, "boss", store.RoleAdmin)
+
+	_, cookie := login(r, "op", testPassword, "")
+	if cookie == nil {
+		t.Fatal("登录失败")
+	}
+	if w := serveAs(r, "boss", "PUT", "/admin/users/op", `{"password":"new-password"}`); w.Code != http.StatusOK {
+		t.Fatalf("改密码返回 %d", w.Code)
+	}
+	if w := serveCookie(r, cookie, "", "GET", "/admin/me", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("改密码后旧会话返回 %d，期望 401", w.Code)
+	}
+	// 删除用户后会话随之失效
+	_, cookie = login(r, "op", "new-password", "")
+	if w := serveAs(r, "boss", "DELETE", "/admin/users/op", ""); w.Code != http.StatusOK {
+		t.Fatalf("删除用户返回 %d", w.Code)
+	}
+	if w := serveCookie(r, cookie, "", "GET", "/admin/me", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("删除用户后会话返回 %d，期望 401", w.Code)
+	}
+}
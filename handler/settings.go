@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── Settings 任务规则 ─────────────────────────────────────────
+
+// GetSettingsRules 返回 Settings-* 任务的下发规则
+func (h *Handler) GetSettingsRules(c *gin.Context) {
+	c.JSON(http.StatusOK, h.settings.Rules())
+}
+
+// SetSettingsRules 替换 Settings-* 任务的下发规则
+func (h *Handler) SetSettingsRules(c *gin.Context) {
+	var req store.SettingsRules
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.settings.SetRules(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "settings_rules.update", "", map[string]any{"rules": req})
+	c.JSON(http.StatusOK, h.settings.Rules())
+}
+
+func settingsErrorStatus(err error) int {
+	if errors.Is(err, store.ErrSettingsType) || errors.Is(err, store.ErrSettingsValue) {
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
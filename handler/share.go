@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ── 截图分享链接 ──────────────────────────────────────────────
+
+const (
+	shareKeyFile    = "share.key"
+	defaultShareTTL = 24 * time.Hour
+	minShareTTL     = time.Minute
+	maxShareTTL     = 30 * 24 * time.Hour
+)
+
+// shareSigner 用 HMAC-SHA256 签发带过期时间的截图链接。
+// 密钥保存在 share.key，轮换密钥即可让所有已发出的链接失效。
+type shareSigner struct {
+	file string
+
+	mu  sync.RWMutex
+	key []byte
+}
+
+func newShareSigner(file string) *shareSigner {
+	s := &shareSigner{file: file}
+	if data, err := os.ReadFile(file); err == nil {
+		if key, err := hex.DecodeString(strings.TrimSpace(string(data))); err == nil && len(key) >= 16 {
+			s.key = key
+			return s
+		}
+	}
+	if err := s.rotate(); err != nil {
+		log.Printf("分享密钥无法保存，重启后已签发的链接将失效: %v", err)
+	}
+	return s
+}
+
+// rotate 生成新密钥并持久化
+func (s *shareSigner) rotate() error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.key = key
+	s.mu.Unlock()
+	return os.WriteFile(s.file, []byte(hex.EncodeToString(key)), 0600)
+}
+
+func (s *shareSigner) sign(id string, exp int64) string {
+	s.mu.RLock()
+	mac := hmac.New(sha256.New, s.key)
+	s.mu.RUnlock()
+	fmt.Fprintf(mac, "%s|%d", id, exp)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// url 返回 id 对应截图的相对分享链接
+func (s *shareSigner) url(id string, ttl time.Duration) (string, time.Time) {
+	expires := time.Now().Add(ttl)
+	exp := expires.Unix()
+	return fmt.Sprintf("/s/%s?exp=%d&sig=%s", id, exp, s.sign(id, exp)), expires
+}
+
+func (s *shareSigner) verify(id, expStr, sig string) bool {
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.sign(id, exp)))
+}
+
+type shareResp struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ShareScreenshot 为截图签发分享链接，可选 ?ttl= 指定有效期（默认 24h，最长 720h）
+func (h *Handler) ShareScreenshot(c *gin.Context) {
+	ttl := defaultShareTTL
+	if v := c.Query("ttl"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < minShareTTL || d > maxShareTTL {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ttl 无效，应为 1m-720h 之间的时长"})
+			return
+		}
+		ttl = d
+	}
+
+	id := c.Param("id")
+	t := h.store.Get(id)
+	if t == nil || !deviceAllowed(c, t.Device) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if _, ok := h.screenshotPath(t); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	url, expires := h.share.url(id, ttl)
+	h.audit(c, "screenshot.share", id, map[string]any{"ttl": ttl.String()})
+	c.JSON(http.StatusOK, shareResp{URL: url, ExpiresAt: expires})
+}
+
+// RotateShareKey 轮换签名密钥，所有已签发的分享链接立即失效
+func (h *Handler) RotateShareKey(c *gin.Context) {
+	if err := h.share.rotate(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "share.rotate", "", nil)
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// SharedScreenshot 是公开端点，校验签名与有效期后返回截图
+func (h *Handler) SharedScreenshot(c *gin.Context) {
+	id := c.Param("id")
+	if !h.share.verify(id, c.Query("exp"), c.Query("sig")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "链接无效或已过期"})
+		return
+	}
+	t := h.store.Get(id)
+	if t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	file, ok := h.screenshotPath(t)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.File(file)
+}
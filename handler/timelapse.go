@@ -0,0 +1,479 @@
+package handler
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 延时回放导出 ──────────────────────────────────────────────
+
+const (
+	timelapseDir       = "timelapse"
+	timelapseMaxWidth  = 800
+	timelapseMaxFrames = 200       // 范围内的截图超过该数量时均匀抽取
+	timelapseMaxBytes  = 100 << 20 // 生成的文件超过该大小时放弃
+	timelapseMaxPixels = 64 << 20  // GIF 所有帧的像素总数上限，编码前每帧都要留在内存中
+	timelapseTTL       = time.Hour // 生成结束后保留的时间，过期后删除记录和文件
+)
+
+var (
+	errTimelapseTooLarge  = fmt.Errorf("生成的文件超过 %d MB，请缩小时间范围", timelapseMaxBytes>>20)
+	errTimelapseTooManyPx = errors.New("GIF 帧数过多或截图过大，请缩小时间范围或改用 zip")
+)
+
+type timelapseReq struct {
+	Device string    `json:"device"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	Format string    `json:"format"` // gif（默认）或 zip
+	Delay  int       `json:"delay"`  // GIF 帧间隔，单位 1/100 秒
+}
+
+type timelapseJob struct {
+	ID        string       `json:"id"`
+	Device    string       `json:"device,omitempty"`
+	Format    string       `json:"format"`
+	Status    store.Status `json:"status"`
+	Total     int          `json:"total"`  // 范围内的截图数
+	Frames    int          `json:"frames"` // 实际写入的帧数
+	Error     string       `json:"error,omitempty"`
+	CreatedAt time.Time    `json:"created_at"`
+	DoneAt    *time.Time   `json:"done_at,omitempty"`
+	file      string
+	cancel    context.CancelFunc
+}
+
+// timelapseJobs 保存后台生成任务的状态，仅存在于内存中。
+// 结束超过 timelapseTTL 的任务连同文件在下次创建时清理，重启前留下的文件也一并删除。
+type timelapseJobs struct {
+	mu      sync.RWMutex
+	jobs    map[string]*timelapseJob
+	running sync.WaitGroup
+}
+
+func (j *timelapseJobs) add(job *timelapseJob) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.jobs == nil {
+		j.jobs = make(map[string]*timelapseJob)
+	}
+	j.jobs[job.ID] = job
+	j.running.Add(1)
+}
+
+func (j *timelapseJobs) get(id string) *timelapseJob {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	job, ok := j.jobs[id]
+	if !ok {
+		return nil
+	}
+	cp := *job
+	return &cp
+}
+
+// finish 记录生成结果。失败、取消或任务已被删除时删除生成了一半的文件。
+func (j *timelapseJobs) finish(job *timelapseJob, frames int, err error) {
+	defer j.running.Done()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	removed := j.jobs[job.ID] != job
+	if removed || err != nil {
+		_ = os.Remove(job.file)
+	}
+	if removed {
+		return
+	}
+	now := time.Now()
+	job.DoneAt = &now
+	job.Frames = frames
+	job.cancel()
+	if err != nil {
+		job.Status = store.StatusFailed
+		job.Error = err.Error()
+		return
+	}
+	job.Status = store.StatusSuccess
+}
+
+// remove 删除任务：正在生成的会被取消，已生成的文件会被删除
+func (j *timelapseJobs) remove(id string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	job, ok := j.jobs[id]
+	if !ok {
+		return false
+	}
+	delete(j.jobs, id)
+	job.cancel()
+	if job.DoneAt != nil {
+		_ = os.Remove(job.file)
+	}
+	return true
+}
+
+// sweep 删除结束超过 timelapseTTL 的任务及其文件，以及 dir 中不属于任何任务的文件
+func (j *timelapseJobs) sweep(dir string, now time.Time) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	files := make(map[string]bool, len(j.jobs))
+	for id, job := range j.jobs {
+		if job.DoneAt != nil && now.Sub(*job.DoneAt) > timelapseTTL {
+			delete(j.jobs, id)
+			continue
+		}
+		files[filepath.Base(job.file)] = true
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if !e.IsDir() && !files[e.Name()] {
+			_ = os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
+
+// close 取消所有正在生成的任务并等待它们退出
+func (j *timelapseJobs) close() {
+	j.mu.RLock()
+	for _, job := range j.jobs {
+		job.cancel()
+	}
+	j.mu.RUnlock()
+	j.running.Wait()
+}
+
+// CreateTimelapse 根据设备与时间范围收集截图，在后台生成 GIF 或 ZIP。
+// 截图超过 timelapseMaxFrames 张时均匀抽取。
+func (h *Handler) CreateTimelapse(c *gin.Context) {
+	var req timelapseReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Format == "" {
+		req.Format = "gif"
+	}
+	if req.Format != "gif" && req.Format != "zip" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 只能是 gif 或 zip"})
+		return
+	}
+	if req.Delay <= 0 {
+		req.Delay = 50
+	}
+	// 限定了设备的 API 密钥必须指定其中一台设备
+	if key := currentKey(c); key != nil && len(key.Devices) > 0 {
+		if req.Device == "" || !key.DeviceAllowed(req.Device) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "该密钥无权访问此设备"})
+			return
+		}
+	}
+
+	shots := h.screenshotsInRange(req.Device, req.From, req.To)
+	if len(shots) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "该范围内没有截图"})
+		return
+	}
+
+	dir := h.dataPath(timelapseDir)
+	h.timelapse.sweep(dir, time.Now())
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &timelapseJob{
+		ID:        uuid.NewString(),
+		Device:    req.Device,
+		Format:    req.Format,
+		Status:    store.StatusPending,
+		Total:     len(shots),
+		CreatedAt: time.Now(),
+		cancel:    cancel,
+	}
+	job.file = filepath.Join(dir, job.ID+"."+req.Format)
+	h.timelapse.add(job)
+	resp := *job
+	h.audit(c, "timelapse.create", job.ID, map[string]any{"device": req.Device, "format": req.Format, "total": len(shots)})
+
+	go func() {
+		n, err := renderTimelapse(ctx, job.file, req.Format, req.Delay, sampleFrames(shots, timelapseMaxFrames))
+		h.timelapse.finish(job, n, err)
+	}()
+
+	c.JSON(http.StatusAccepted, resp)
+}
+
+// visibleTimelapse 返回当前凭证可以访问的导出任务，限定了设备的 API 密钥只能访问这些设备的导出
+func (h *Handler) visibleTimelapse(c *gin.Context) *timelapseJob {
+	job := h.timelapse.get(c.Param("id"))
+	if job == nil || !deviceAllowed(c, job.Device) {
+		return nil
+	}
+	return job
+}
+
+// GetTimelapse 查询导出任务状态
+func (h *Handler) GetTimelapse(c *gin.Context) {
+	job := h.visibleTimelapse(c)
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadTimelapse 下载已生成的 GIF 或 ZIP
+func (h *Handler) DownloadTimelapse(c *gin.Context) {
+	job := h.visibleTimelapse(c)
+	if job == nil || job.Status != store.StatusSuccess {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.FileAttachment(job.file, "timelapse_"+shortID(job.ID)+"."+job.Format)
+}
+
+// DeleteTimelapse 取消正在生成的导出任务，或删除已生成的文件
+func (h *Handler) DeleteTimelapse(c *gin.Context) {
+	if h.visibleTimelapse(c) == nil || !h.timelapse.remove(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	h.audit(c, "timelapse.delete", c.Param("id"), nil)
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// Close 停止卡死检测，取消正在生成的延时回放并等待后台任务退出
+func (h *Handler) Close() {
+	if h.watchdog != nil {
+		h.watchdog.close()
+	}
+	h.timelapse.close()
+}
+
+// sampleFrames 从 shots 中均匀抽取不超过 limit 帧，保留首尾
+func sampleFrames(shots []timelapseFrame, limit int) []timelapseFrame {
+	if len(shots) <= limit {
+		return shots
+	}
+	sampled := make([]timelapseFrame, limit)
+	for i := range sampled {
+		sampled[i] = shots[i*(len(shots)-1)/(limit-1)]
+	}
+	return sampled
+}
+
+// timelapseFrame 是一张截图的文件路径和截图时间
+type timelapseFrame struct {
+	file string
+	at   time.Time
+}
+
+// screenshotsInRange 返回指定设备在时间范围内成功的截图（按时间升序）。
+// device 为空表示不限设备，from/to 为零值表示不限。
+func (h *Handler) screenshotsInRange(device string, from, to time.Time) []timelapseFrame {
+	var shots []timelapseFrame
+	for _, t := range h.store.All() {
+		file, ok := h.screenshotPath(t)
+		if !ok || t.DoneAt == nil {
+			continue
+		}
+		if device != "" && t.Device != device {
+			continue
+		}
+		if !from.IsZero() && t.DoneAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && t.DoneAt.After(to) {
+			continue
+		}
+		shots = append(shots, timelapseFrame{file: file, at: *t.DoneAt})
+	}
+	sort.Slice(shots, func(i, j int) bool { return shots[i].at.Before(shots[j].at) })
+	return shots
+}
+
+// renderTimelapse 逐帧读取截图、缩放并叠加时间戳，写入 file。
+// 无法读取的截图会被跳过，返回实际写入的帧数。ctx 取消、文件超过 timelapseMaxBytes
+// 或 GIF 帧的像素总数超过 timelapseMaxPixels 时返回错误。
+func renderTimelapse(ctx context.Context, file, format string, delay int, shots []timelapseFrame) (int, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	out := &limitedWriter{w: f, n: timelapseMaxBytes}
+
+	var (
+		anim   gif.GIF
+		zw     *zip.Writer
+		n      int
+		pixels = pixelBudget{n: timelapseMaxPixels}
+	)
+	if format == "zip" {
+		zw = zip.NewWriter(out)
+	}
+
+	for _, t := range shots {
+		if ctx.Err() != nil {
+			return n, errors.New("已取消")
+		}
+		img, err := loadImage(t.file)
+		if err != nil {
+			continue
+		}
+		frame := scaleToWidth(img, timelapseMaxWidth)
+		drawLabel(frame, t.at.Format("2006-01-02 15:04:05"))
+		n++
+
+		if zw != nil {
+			w, err := zw.Create(fmt.Sprintf("%04d_%s.png", n, t.at.Format("20060102_150405")))
+			if err != nil {
+				return n, err
+			}
+			if err := png.Encode(w, frame); err != nil {
+				return n, err
+			}
+			continue
+		}
+
+		if err := pixels.take(frame.Bounds()); err != nil {
+			return n, err
+		}
+		p := image.NewPaletted(frame.Bounds(), palette.Plan9)
+		draw.FloydSteinberg.Draw(p, p.Bounds(), frame, image.Point{})
+		anim.Image = append(anim.Image, p)
+		anim.Delay = append(anim.Delay, delay)
+	}
+
+	if n == 0 {
+		return 0, fmt.Errorf("没有可读取的截图")
+	}
+	if zw != nil {
+		return n, zw.Close()
+	}
+	return n, gif.EncodeAll(out, &anim)
+}
+
+// limitedWriter 最多写入 n 字节，超过时返回 errTimelapseTooLarge
+type limitedWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.n {
+		return 0, errTimelapseTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+// pixelBudget 限制累计的像素数，超过时返回 errTimelapseTooManyPx
+type pixelBudget struct {
+	n int
+}
+
+func (b *pixelBudget) take(r image.Rectangle) error {
+	px := r.Dx() * r.Dy()
+	if px > b.n {
+		return errTimelapseTooManyPx
+	}
+	b.n -= px
+	return nil
+}
+
+func loadImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// scaleToWidth 用最近邻采样把图片缩放到不超过 maxW 的宽度
+func scaleToWidth(src image.Image, maxW int) *image.RGBA {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > maxW {
+		h = h * maxW / w
+		w = maxW
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy := b.Min.Y + y*b.Dy()/h
+		for x := 0; x < w; x++ {
+			sx := b.Min.X + x*b.Dx()/w
+			dst.Set(x, y, src.At(sx, sy))
+		}
+	}
+	return dst
+}
+
+// ── 文字叠加（内置 5x7 点阵字体，仅含数字与少量符号）──────────
+
+var glyphs = map[rune][7]uint8{
+	'0': {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1': {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3': {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4': {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5': {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6': {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9': {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'-': {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	':': {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	'%': {0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03},
+	' ': {},
+}
+
+// drawLabel 在图片左上角绘制带半透明底色的白色文字
+func drawLabel(img *image.RGBA, text string) {
+	const scale, pad = 2, 4
+	w := len(text)*6*scale + pad*2
+	h := 7*scale + pad*2
+	bg := image.NewUniform(color.RGBA{0, 0, 0, 160})
+	draw.Draw(img, image.Rect(0, 0, w, h), bg, image.Point{}, draw.Over)
+
+	white := color.RGBA{255, 255, 255, 255}
+	for i, r := range text {
+		g := glyphs[r]
+		ox := pad + i*6*scale
+		for row := 0; row < 7; row++ {
+			for col := 0; col < 5; col++ {
+				if g[row]&(0x10>>col) == 0 {
+					continue
+				}
+				for dy := 0; dy < scale; dy++ {
+					for dx := 0; dx < scale; dx++ {
+						img.Set(ox+col*scale+dx, pad+row*scale+dy, white)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/store"
+)
+
+func timelapseRouter(h *Handler) *gin.Engine {
+	r := gin.New()
+	r.POST("/timelapse", h.CreateTimelapse)
+	r.GET("/timelapse/:id", h.GetTimelapse)
+	r.DELETE("/timelapse/:id", h.DeleteTimelapse)
+	return r
+}
+
+// addScreenshots 让 dev1 汇报 n 张截图
+func addScreenshots(t *testing.T, h *Handler, n int) {
+	t.Helper()
+	r := screenshotRouter(h)
+	for i := 0; i < n; i++ {
+		task := h.store.AddFor("dev1", "CaptureImage", "")
+		serve(r, "POST", "/maa/getTask", `{"user":"u1","device":"dev1"}`)
+		if w := report(r, task.ID, "SUCCESS", testPNG()); w.Code != http.StatusOK {
+			t.Fatalf("汇报截图返回 %d", w.Code)
+		}
+	}
+}
+
+func createTimelapse(t *testing.T, r http.Handler) timelapseJob {
+	t.Helper()
+	w := serve(r, "POST", "/timelapse", `{"device":"dev1","format":"zip"}`)
+	var job timelapseJob
+	if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil || w.Code != http.StatusAccepted {
+		t.Fatalf("创建返回 %d: %s", w.Code, w.Body)
+	}
+	return job
+}
+
+func waitTimelapse(t *testing.T, h *Handler, id string) *timelapseJob {
+	t.Helper()
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if job := h.timelapse.get(id); job == nil || job.DoneAt != nil {
+			return job
+		}
+	}
+	t.Fatal("延时回放没有生成完")
+	return nil
+}
+
+func TestSampleFrames(t *testing.T) {
+	shots := make([]timelapseFrame, 1000)
+	for i := range shots {
+		shots[i].at = time.Unix(int64(i), 0)
+	}
+	sampled := sampleFrames(shots, timelapseMaxFrames)
+	if len(sampled) != timelapseMaxFrames || sampled[0] != shots[0] || sampled[len(sampled)-1] != shots[len(shots)-1] {
+		t.Errorf("抽取了 %d 帧，首 %v 尾 %v", len(sampled), sampled[0].at, sampled[len(sampled)-1].at)
+	}
+	if got := sampleFrames(shots[:10], timelapseMaxFrames); len(got) != 10 {
+		t.Errorf("不足上限时抽取了 %d 帧", len(got))
+	}
+}
+
+func TestTimelapseLifecycle(t *testing.T) {
+	h := newTestHandler(t)
+	t.Cleanup(h.Close)
+	r := timelapseRouter(h)
+	addScreenshots(t, h, 3)
+
+	job := createTimelapse(t, r)
+	done := waitTimelapse(t, h, job.ID)
+	if done.Status != store.StatusSuccess || done.Total != 3 || done.Frames != 3 {
+		t.Fatalf("生成结果 %+v", done)
+	}
+	if _, err := os.Stat(done.file); err != nil {
+		t.Fatal(err)
+	}
+
+	// 删除后记录和文件都不存在
+	if w := serve(r, "DELETE", "/timelapse/"+job.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("删除返回 %d", w.Code)
+	}
+	if _, err := os.Stat(done.file); !os.IsNotExist(err) {
+		t.Errorf("删除后文件仍存在: %v", err)
+	}
+	if w := serve(r, "GET", "/timelapse/"+job.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("删除后查询返回 %d", w.Code)
+	}
+}
+
+func TestTimelapseDeviceKey(t *testing.T) {
+	h := newTestHandler(t)
+	t.Cleanup(h.Close)
+	addScreenshots(t, h, 1)
+	job := waitTimelapse(t, h, createTimelapse(t, timelapseRouter(h)).ID)
+
+	r := gin.New()
+	g := r.Group("/timelapse", h.Authenticate(), h.Allow(store.RoleViewer, store.ScopeScreenshotsRead))
+	g.GET("/:id", h.GetTimelapse)
+	g.GET("/:id/download", h.DownloadTimelapse)
+	g.DELETE("/:id", h.DeleteTimelapse)
+	dev1 := createKey(t, h, store.APIKey{Scopes: []store.Scope{store.ScopeScreenshotsRead}, Devices: []string{"dev1"}})
+	dev2 := createKey(t, h, store.APIKey{Scopes: []store.Scope{store.ScopeScreenshotsRead}, Devices: []string{"dev2"}})
+
+	// 其他设备的密钥看不到、下载不了也删除不了
+	for _, c := range []struct{ method, path string }{
+		{"GET", "/timelapse/" + job.ID},
+		{"GET", "/timelapse/" + job.ID + "/download"},
+		{"DELETE", "/timelapse/" + job.ID},
+	} {
+		if w := serveKey(r, dev2, c.method, c.path, ""); w.Code != http.StatusNotFound {
+			t.Errorf("dev2 的密钥 %s %s 返回 %d", c.method, c.path, w.Code)
+		}
+	}
+	if h.timelapse.get(job.ID) == nil {
+		t.Fatal("其他设备的密钥删除了导出")
+	}
+	if w := serveKey(r, dev1, "GET", "/timelapse/"+job.ID+"/download", ""); w.Code != http.StatusOK {
+		t.Errorf("dev1 的密钥下载返回 %d", w.Code)
+	}
+	if w := serveKey(r, dev1, "DELETE", "/timelapse/"+job.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("dev1 的密钥删除返回 %d", w.Code)
+	}
+}
+
+func TestTimelapseSweep(t *testing.T) {
+	h := newTestHandler(t)
+	t.Cleanup(h.Close)
+	r := timelapseRouter(h)
+	addScreenshots(t, h, 1)
+
+	job := waitTimelapse(t, h, createTimelapse(t, r).ID)
+	// 重启前留下的文件不属于任何任务
+	stale := filepath.Join(h.dataPath(timelapseDir), "stale.gif")
+	if err := os.WriteFile(stale, []byte("gif"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h.timelapse.sweep(h.dataPath(timelapseDir), time.Now())
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("遗留的文件没有被删除: %v", err)
+	}
+	if h.timelapse.get(job.ID) == nil {
+		t.Fatal("未过期的任务被删除")
+	}
+
+	h.timelapse.sweep(h.dataPath(timelapseDir), time.Now().Add(timelapseTTL+time.Minute))
+	if h.timelapse.get(job.ID) != nil {
+		t.Error("过期的任务没有被删除")
+	}
+	if _, err := os.Stat(job.file); !os.IsNotExist(err) {
+		t.Errorf("过期任务的文件没有被删除: %v", err)
+	}
+}
+
+func TestRenderTimelapseCancel(t *testing.T) {
+	h := newTestHandler(t)
+	addScreenshots(t, h, 2)
+	file := filepath.Join(t.TempDir(), "out.zip")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := renderTimelapse(ctx, file, "zip", 50, h.screenshotsInRange("dev1", time.Time{}, time.Time{})); err == nil {
+		t.Error("取消后仍然生成成功")
+	}
+}
+
+func TestLimitedWriter(t *testing.T) {
+	w := &limitedWriter{w: io.Discard, n: 10}
+	if _, err := w.Write(make([]byte, 6)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(make([]byte, 6)); !errors.Is(err, errTimelapseTooLarge) {
+		t.Errorf("超过上限时返回 %v", err)
+	}
+}
+
+func TestPixelBudget(t *testing.T) {
+	b := &pixelBudget{n: 100}
+	if err := b.take(image.Rect(0, 0, 8, 8)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.take(image.Rect(0, 0, 8, 8)); !errors.Is(err, errTimelapseTooManyPx) {
+		t.Errorf("超过上限时返回 %v", err)
+	}
+	if err := b.take(image.Rect(0, 0, 6, 6)); err != nil {
+		t.Errorf("剩余像素足够时返回 %v", err)
+	}
+}
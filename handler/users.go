@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/api"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 用户与角色 ────────────────────────────────────────────────
+
+type userReq struct {
+	Username string     `json:"username"`
+	Password string     `json:"password"`
+	Role     store.Role `json:"role"`
+}
+
+type roleTypesReq struct {
+	Types []string `json:"types"`
+}
+
+// Me 返回当前登录用户及其可下发的任务类型；使用 API 密钥时返回密钥信息
+func (h *Handler) Me(c *gin.Context) {
+	if key := currentKey(c); key != nil {
+		c.JSON(http.StatusOK, key)
+		return
+	}
+	user := currentUser(c)
+	types := []string{"*"}
+	if user.Role != store.RoleAdmin {
+		types = h.users.RoleTypes()[user.Role]
+	}
+	resp := api.Identity{Username: user.Username, Role: string(user.Role), Types: types}
+	if v, ok := c.Get(ctxSession); ok {
+		resp.CSRF = v.(*store.Session).CSRF
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListUsers 返回所有用户
+func (h *Handler) ListUsers(c *gin.Context) {
+	c.JSON(http.StatusOK, h.users.List())
+}
+
+// CreateUser 新建用户
+func (h *Handler) CreateUser(c *gin.Context) {
+	var req userReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := h.users.Create(strings.TrimSpace(req.Username), req.Password, req.Role)
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "user.create", user.Username, map[string]any{"role": user.Role})
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateUser 修改用户密码或角色
+func (h *Handler) UpdateUser(c *gin.Context) {
+	var req userReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	user, err := h.users.Update(c.Param("name"), req.Password, req.Role)
+	if err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if req.Password != "" {
+		h.sessions.DeleteUser(user.Username)
+	}
+	h.audit(c, "user.update", user.Username, map[string]any{"role": req.Role, "password_changed": req.Password != ""})
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser 删除用户
+func (h *Handler) DeleteUser(c *gin.Context) {
+	if err := h.users.Delete(c.Param("name")); err != nil {
+		c.JSON(userErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	h.sessions.DeleteUser(c.Param("name"))
+	h.audit(c, "user.delete", c.Param("name"), nil)
+	c.JSON(http.StatusOK, gin.H{})
+}
+
+// ListRoles 返回各角色可下发的任务类型
+func (h *Handler) ListRoles(c *gin.Context) {
+	c.JSON(http.StatusOK, h.users.RoleTypes())
+}
+
+// SetRoleTypes 设置角色可下发的任务类型，类型末尾的 * 表示前缀匹配
+func (h *Handler) SetRoleTypes(c *gin.Context) {
+	var req roleTypesReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.users.SetRoleTypes(store.Role(c.Param("role")), req.Types); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.audit(c, "role.set_types", c.Param("role"), map[string]any{"types": req.Types})
+	c.JSON(http.StatusOK, h.users.RoleTypes())
+}
+
+func userErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, store.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, store.ErrUserExists), errors.Is(err, store.ErrLastAdmin):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"ArknightsMaaRemoter/store"
+)
+
+func TestRoles(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	addUser(t, h, "viewer", store.RoleViewer)
+	addUser(t, h, "op", store.RoleOperator)
+	addUser(t, h, "boss", store.RoleAdmin)
+
+	for _, c := range []struct {
+		user, method, path, body string
+		want                     int
+	}{
+		{"nobody", "GET", "/admin/me", "", http.StatusUnauthorized},
+		{"viewer", "GET", "/admin/queue", "", http.StatusOK},
+		{"viewer", "POST", "/admin/task", `{"type":"LinkStart"}`, http.StatusForbidden},
+		{"op", "POST", "/admin/task", `{"type":"LinkStart"}`, http.StatusOK},
+		{"op", "POST", "/admin/task", `{"type":"Settings-Stage1","params":"1-7"}`, http.StatusForbidden},
+		{"boss", "POST", "/admin/task", `{"type":"Settings-Stage1","params":"1-7"}`, http.StatusAccepted},
+		{"op", "POST", "/admin/users", `{"username":"eve","password":"password1","role":"admin"}`, http.StatusForbidden},
+		{"boss", "POST", "/admin/users", `{"username":"eve","password":"short","role":"viewer"}`, http.StatusBadRequest},
+		{"boss", "POST", "/admin/users", `{"username":"eve","password":"password1","role":"viewer"}`, http.StatusOK},
+		{"op", "PUT", "/admin/roles/operator", `{"types":["*"]}`, http.StatusForbidden},
+	} {
+		if w := serveAs(r, c.user, c.method, c.path, c.body); w.Code != c.want {
+			t.Errorf("%s %s %s 返回 %d，期望 %d: %s", c.user, c.method, c.path, w.Code, c.want, w.Body)
+		}
+	}
+
+	// 角色的任务类型修改后立即生效
+	if w := serveAs(r, "boss", "PUT", "/admin/roles/operator", `{"types":["CaptureImage*"]}`); w.Code != http.StatusOK {
+		t.Fatalf("修改角色返回 %d", w.Code)
+	}
+	if w := serveAs(r, "op", "POST", "/admin/task", `{"type":"LinkStart"}`); w.Code != http.StatusForbidden {
+		t.Errorf("移除类型后 operator 下发返回 %d，期望 403", w.Code)
+	}
+	// 降级后立即失去权限
+	if w := serveAs(r, "boss", "PUT", "/admin/users/op", `{"role":"viewer"}`); w.Code != http.StatusOK {
+		t.Fatalf("降级返回 %d", w.Code)
+	}
+	if w := serveAs(r, "op", "POST", "/admin/task", `{"type":"CaptureImage"}`); w.Code != http.StatusForbidden {
+		t.Errorf("降级为 viewer 后下发返回 %d，期望 403", w.Code)
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/api"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 等待任务结束 ──────────────────────────────────────────────
+
+const (
+	defaultWaitTimeout = 30 * time.Second
+	maxWaitTimeout     = 5 * time.Minute
+)
+
+// parseWaitTimeout 解析 ?timeout=，可以是时长（如 90s）或秒数，默认 30 秒，最长 5 分钟
+func parseWaitTimeout(v string) (time.Duration, bool) {
+	if v == "" {
+		return defaultWaitTimeout, true
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		n, nerr := strconv.Atoi(v)
+		if nerr != nil {
+			return 0, false
+		}
+		d = time.Duration(n) * time.Second
+	}
+	return d, d >= 0 && d <= maxWaitTimeout
+}
+
+// WaitTask 阻塞到任务结束（成功、失败或被拒绝）或超时，返回任务的最新状态。
+// 超时不算错误，仍返回 200，调用方根据 done_at 判断是否需要继续等待。
+func (h *Handler) WaitTask(c *gin.Context) {
+	timeout, ok := parseWaitTimeout(c.Query("timeout"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "timeout 应为 0-5m 之间的时长，如 30s，或秒数"})
+		return
+	}
+	id := c.Param("id")
+	t, done := h.store.Watch(id)
+	if t == nil || !deviceAllowed(c, t.Device) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+	case <-c.Request.Context().Done():
+		return
+	}
+	if t = h.store.Get(id); t == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+	c.JSON(http.StatusOK, taskResult(c, t))
+}
+
+// taskResult 为成功的截图任务附上下载地址，地址与当前请求使用相同的前缀（/admin 或 /api/v1）
+func taskResult(c *gin.Context, t *store.Task) api.TaskResult {
+	result := api.TaskResult{Task: t}
+	if t.ScreenshotFile() != "" {
+		prefix, _, _ := strings.Cut(c.FullPath(), "/tasks/")
+		result.ScreenshotURL = prefix + "/screenshot/" + t.ID
+	}
+	return result
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ArknightsMaaRemoter/api"
+	"ArknightsMaaRemoter/store"
+)
+
+// waitAsync 在后台请求 /admin/tasks/:id/wait，返回接收响应的 channel
+func waitAsync(r http.Handler, id, timeout string) <-chan *httptest.ResponseRecorder {
+	ch := make(chan *httptest.ResponseRecorder, 1)
+	go func() { ch <- serveAs(r, "viewer", "GET", "/admin/tasks/"+id+"/wait?timeout="+timeout, "") }()
+	return ch
+}
+
+func waitResult(t *testing.T, ch <-chan *httptest.ResponseRecorder) api.TaskResult {
+	t.Helper()
+	select {
+	case w := <-ch:
+		var result api.TaskResult
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("等待返回 %d: %s", w.Code, w.Body)
+		}
+		return result
+	case <-time.After(10 * time.Second):
+		t.Fatal("任务结束后等待没有返回")
+		return api.TaskResult{}
+	}
+}
+
+func TestWaitReleasedByComplete(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	addUser(t, h, "viewer", store.RoleViewer)
+	task := h.store.AddFor("dev1", "LinkStart", "")
+
+	ch := waitAsync(r, task.ID, "5m")
+	time.Sleep(100 * time.Millisecond)
+	h.store.Pending("u1", "dev1")
+	if err := h.store.Complete(task.ID, "u1", "dev1", "SUCCESS", "done"); err != nil {
+		t.Fatal(err)
+	}
+	result := waitResult(t, ch)
+	if result.Status != store.StatusSuccess || result.Payload != "done" || result.DoneAt == nil {
+		t.Errorf("等待返回 %+v", result.Task)
+	}
+}
+
+func TestWaitReleasedByReject(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	addUser(t, h, "viewer", store.RoleViewer)
+	addUser(t, h, "boss", store.RoleAdmin)
+	task := h.store.AddTask(&store.Task{Type: "Toolbox-GachaOnce", Status: store.StatusAwaitingApproval, SubmittedBy: "viewer"})
+
+	ch := waitAsync(r, task.ID, "5m")
+	time.Sleep(100 * time.Millisecond)
+	if w := serveAs(r, "boss", "POST", "/admin/task/"+task.ID+"/reject", ""); w.Code != http.StatusOK {
+		t.Fatalf("拒绝返回 %d: %s", w.Code, w.Body)
+	}
+	if result := waitResult(t, ch); result.Status != store.StatusRejected || result.ApprovedBy != "boss" {
+		t.Errorf("等待返回 %+v", result.Task)
+	}
+}
+
+func TestWaitTimeout(t *testing.T) {
+	h := newTestHandler(t)
+	r := adminRouter(h)
+	addUser(t, h, "viewer", store.RoleViewer)
+	task := h.store.AddFor("dev1", "LinkStart", "")
+
+	start := time.Now()
+	// 超时仍返回 200 和未结束的任务
+	result := waitResult(t, waitAsync(r, task.ID, "200ms"))
+	if result.Status != store.StatusPending || result.DoneAt != nil {
+		t.Errorf("超时返回 %+v，期望未结束的任务", result.Task)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("%v 后就返回，没有等到超时", elapsed)
+	}
+
+	for _, timeout := range []string{"6m", "-1s", "soon"} {
+		if w := serveAs(r, "viewer", "GET", "/admin/tasks/"+task.ID+"/wait?timeout="+timeout, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("timeout=%s 返回 %d，期望 400", timeout, w.Code)
+		}
+	}
+	if w := serveAs(r, "viewer", "GET", "/admin/tasks/missing/wait?timeout=1s", ""); w.Code != http.StatusNotFound {
+		t.Errorf("不存在的任务返回 %d，期望 404", w.Code)
+	}
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"image"
+	"log"
+	"math/bits"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/api"
+	"ArknightsMaaRemoter/store"
+)
+
+// ── 卡死检测 ──────────────────────────────────────────────────
+
+const maxStuckAlerts = 100
+
+// watchState 记录某台设备当前运行任务期间的截图哈希比对进度
+type watchState struct {
+	taskID    string
+	captureID string
+	last      uint64
+	hasLast   bool
+	same      int
+	alerted   bool
+}
+
+type watchdog struct {
+	mu      sync.Mutex
+	devices map[string]*watchState
+	alerts  []api.Alert
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+// close 停止定时截图并等待正在进行的比对结束
+func (w *watchdog) close() {
+	w.once.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+// StartWatchdog 每隔 interval 给正在执行任务的设备截图，interval 为 0 时不启用。
+// 判定卡死的帧数、哈希距离和是否自动停止取自 Options，可以热更新。Close 时停止。
+func (h *Handler) StartWatchdog(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	w := &watchdog{
+		devices: make(map[string]*watchState),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	h.watchdog = w
+
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				h.watchTick()
+			}
+		}
+	}()
+	log.Printf("卡死检测已启用：每 %s 截图一次，连续 %d 帧相同视为卡死", interval, h.options().WatchdogFrames)
+}
+
+// ListAlerts 返回最近的疑似卡死告警（最新在前）
+func (h *Handler) ListAlerts(c *gin.Context) {
+	result := make([]api.Alert, 0)
+	if w := h.watchdog; w != nil {
+		w.mu.Lock()
+		for i := len(w.alerts) - 1; i >= 0; i-- {
+			result = append(result, w.alerts[i])
+		}
+		w.mu.Unlock()
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// watchTick 为每台忙碌设备比对上一张截图并排队下一张。
+// 设备还有未下发的截图任务（例如离线或重启前排队的）时沿用它，不再重复排队。
+func (h *Handler) watchTick() {
+	w := h.watchdog
+	running := h.runningTasks()
+	queued := h.queuedCaptures()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for dev := range w.devices {
+		if running[dev] == nil {
+			delete(w.devices, dev)
+		}
+	}
+
+	for dev, t := range running {
+		st := w.devices[dev]
+		if st == nil || st.taskID != t.ID {
+			st = &watchState{taskID: t.ID}
+			w.devices[dev] = st
+		}
+
+		if st.captureID != "" {
+			c := h.store.Get(st.captureID)
+			if c != nil && c.Status == store.StatusPending {
+				continue // 上一张截图还没回来
+			}
+			st.captureID = ""
+			if c != nil && c.Status == store.StatusSuccess {
+				w.observe(h, dev, t, c, st)
+			}
+		}
+
+		if id, ok := queued[dev]; ok {
+			st.captureID = id
+			continue
+		}
+		st.captureID = h.store.AddFor(dev, "CaptureImageNow", "").ID
+	}
+}
+
+// queuedCaptures 返回每台设备尚未下发的 CaptureImageNow 任务
+func (h *Handler) queuedCaptures() map[string]string {
+	queued := make(map[string]string)
+	for _, t := range h.store.All() {
+		if t.Type == "CaptureImageNow" && t.Device != "" && t.Status == store.StatusPending && t.DispatchedAt == nil {
+			queued[t.Device] = t.ID
+		}
+	}
+	return queued
+}
+
+func (w *watchdog) observe(h *Handler, dev string, t, capture *store.Task, st *watchState) {
+	file, ok := h.screenshotPath(capture)
+	if !ok {
+		return
+	}
+	img, err := loadImage(file)
+	if err != nil {
+		return
+	}
+	opts := h.options()
+	hash := dHash(img)
+	if st.hasLast && bits.OnesCount64(hash^st.last) <= opts.WatchdogThreshold {
+		st.same++
+	} else {
+		st.same = 0
+	}
+	st.last, st.hasLast = hash, true
+
+	if st.same+1 < opts.WatchdogFrames || st.alerted {
+		return
+	}
+	st.alerted = true
+	shot, _ := h.share.url(capture.ID, defaultShareTTL)
+	alert := api.Alert{
+		Device:     dev,
+		TaskID:     t.ID,
+		TaskType:   t.Type,
+		Frames:     st.same + 1,
+		Screenshot: shot,
+		CreatedAt:  time.Now(),
+	}
+	if opts.WatchdogStop {
+		stop := h.store.AddFor(dev, "StopTask", "")
+		h.record(store.AuditEntry{
+			Actor:  "watchdog",
+			Action: "task.submit",
+			Target: stop.ID,
+			Detail: map[string]any{"type": stop.Type, "device": dev, "stuck_task": t.ID},
+		})
+		alert.StopQueued = true
+	}
+	log.Printf("疑似卡死：设备 %s 执行 %s 时连续 %d 帧画面无变化", dev, t.Type, alert.Frames)
+
+	w.alerts = append(w.alerts, alert)
+	if len(w.alerts) > maxStuckAlerts {
+		w.alerts = w.alerts[len(w.alerts)-maxStuckAlerts:]
+	}
+}
+
+// runningTasks 返回每台设备正在执行的顺序任务，即已下发但尚未汇报的最早任务。
+// 未指定设备的任务下发给所有设备，下发后轮询过的设备都视为在执行它。
+func (h *Handler) runningTasks() map[string]*store.Task {
+	all := h.store.All()
+	devices := h.store.Devices()
+	running := make(map[string]*store.Task)
+	for i := len(all) - 1; i >= 0; i-- {
+		t := all[i]
+		if t.Status != store.StatusPending || t.DispatchedAt == nil || isImmediateTask(t.Type) {
+			continue
+		}
+		if t.Device != "" {
+			if running[t.Device] == nil {
+				running[t.Device] = t
+			}
+			continue
+		}
+		for _, d := range devices {
+			if running[d.ID] == nil && !d.LastSeen.Before(*t.DispatchedAt) {
+				running[d.ID] = t
+			}
+		}
+	}
+	return running
+}
+
+// isImmediateTask 判断任务是否为 MAA 立即执行、不进入顺序队列的类型
+func isImmediateTask(taskType string) bool {
+	return taskType == "CaptureImageNow" || taskType == "HeartBeat" || taskType == "StopTask"
+}
+
+// dHash 计算 64 位差值感知哈希：缩成 9x8 灰度图后比较相邻像素亮度
+func dHash(img image.Image) uint64 {
+	const cols, rows, samples = 9, 8, 6
+	b := img.Bounds()
+	var gray [rows][cols]uint32
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			var sum uint32
+			for sy := 0; sy < samples; sy++ {
+				for sx := 0; sx < samples; sx++ {
+					px := b.Min.X + (x*samples+sx)*b.Dx()/(cols*samples)
+					py := b.Min.Y + (y*samples+sy)*b.Dy()/(rows*samples)
+					r, g, bl, _ := img.At(px, py).RGBA()
+					sum += (299*r + 587*g + 114*bl) / 1000 >> 8
+				}
+			}
+			gray[y][x] = sum
+		}
+	}
+	var hash uint64
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols-1; x++ {
+			hash <<= 1
+			if gray[y][x] > gray[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
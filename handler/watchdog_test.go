@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunningTasks(t *testing.T) {
+	h := newTestHandler(t)
+	// MAA 按顺序执行，较早的任务先执行
+	named := h.store.AddFor("dev2", "LinkStart-Combat", "")
+	broadcast := h.store.Add("LinkStart", "")
+	h.store.Add("CaptureImageNow", "") // 立即执行的任务不进入顺序队列
+
+	h.store.Seen("u1", "dev1")
+	h.store.Pending("u1", "dev1")
+	h.store.Seen("u1", "dev1")
+	h.store.Seen("u2", "dev2")
+	h.store.Pending("u2", "dev2")
+
+	running := h.runningTasks()
+	if running["dev1"] != broadcast {
+		t.Errorf("dev1 正在执行 %v，期望未指定设备的任务", running["dev1"])
+	}
+	if running["dev2"] != named {
+		t.Errorf("dev2 正在执行 %v，期望指定给它的任务", running["dev2"])
+	}
+	if len(running) != 2 {
+		t.Errorf("running = %v，期望只有 dev1 和 dev2", running)
+	}
+}
+
+// captures 返回 dev 的 CaptureImageNow 任务数
+func captures(h *Handler, dev string) int {
+	n := 0
+	for _, t := range h.store.All() {
+		if t.Type == "CaptureImageNow" && t.Device == dev {
+			n++
+		}
+	}
+	return n
+}
+
+func TestWatchTickQueuedCapture(t *testing.T) {
+	h := newTestHandler(t)
+	h.watchdog = &watchdog{devices: make(map[string]*watchState)}
+	h.store.AddFor("dev1", "LinkStart", "")
+	h.store.Seen("u1", "dev1")
+	h.store.Pending("u1", "dev1")
+
+	// 重启前排队、还没下发的截图被沿用
+	old := h.store.AddFor("dev1", "CaptureImageNow", "")
+	h.watchTick()
+	if n := captures(h, "dev1"); n != 1 {
+		t.Fatalf("有未下发的截图时又排队了截图，共 %d 个", n)
+	}
+	if got := h.watchdog.devices["dev1"].captureID; got != old.ID {
+		t.Errorf("正在等待的截图是 %s，期望 %s", got, old.ID)
+	}
+
+	// 设备一直不来取任务时每次检测都不重复排队
+	for i := 0; i < 3; i++ {
+		h.watchTick()
+	}
+	if n := captures(h, "dev1"); n != 1 {
+		t.Errorf("设备离线时排队了 %d 个截图", n)
+	}
+}
+
+func TestWatchdogClose(t *testing.T) {
+	h := newTestHandler(t)
+	h.StartWatchdog(time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		h.Close()
+		h.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close 没有停止卡死检测")
+	}
+}
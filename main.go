@@ -1,49 +1,317 @@
 package main
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/config"
 	"ArknightsMaaRemoter/handler"
 	staticfiles "ArknightsMaaRemoter/static"
 	"ArknightsMaaRemoter/store"
 )
 
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	cmd, args := "serve", os.Args[1:]
+	// 不带子命令时（包括只有 -config 等参数）默认启动服务，兼容旧的启动方式
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		cmd, args = args[0], args[1:]
 	}
+	if err := runCommand(cmd, args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// serve 启动 HTTP 服务
+func serve(args []string) error {
+	flags, configFlag := newFlagSet("serve", "")
+	_ = flags.Parse(args)
+	cfg, configPath, err := openDataDir(*configFlag, true)
+	if err != nil {
+		return err
+	}
+	if cfg.GinMode != "" {
+		gin.SetMode(cfg.GinMode)
+	}
+
+	dir := cfg.DataDir
+	s := store.New(dir)
+	users := store.NewUsers(dir)
+	pw := bootstrapPassword(cfg)
+	if generated, created := users.Bootstrap("admin", pw); created && pw == "" {
+		if cfg.Auth.AdminToken != "" {
+			log.Printf("auth.admin_token 不足 %d 位，不用作初始管理员密码", store.MinPasswordLen)
+		}
+		log.Printf("已创建初始管理员 admin，密码: %s（请登录后尽快修改）", generated)
+	}
+	h := handler.New(dir, s, store.NewReferences(dir), users, store.NewAPIKeys(dir), store.NewSessions(dir, time.Duration(cfg.Auth.SessionTTL)), store.NewDeviceTokens(dir), store.NewAudit(dir), store.NewSettings(dir))
+	h.SetOptions(handlerOptions(cfg))
+	h.StartWatchdog(time.Duration(cfg.Watchdog.Interval))
+	defer h.Close()
 
-	s := store.New()
-	h := handler.New(s)
+	r, reload, err := newRouter(cfg, h)
+	if err != nil {
+		return err
+	}
+	// 收到 SIGHUP 时重新读取配置，应用可以热更新的设置
+	go watchReload(configPath, cfg, func(next *config.Config) error {
+		h.SetOptions(reloadOptions(cfg, next))
+		return reload(next)
+	})
+
+	certs, err := setupTLS(cfg)
+	if err != nil {
+		return err
+	}
+	scheme := "http"
+	if certs != nil {
+		scheme = "https"
+	}
+	_, port, _ := net.SplitHostPort(cfg.Listen)
+	log.Printf("MAA Remote 已启动，访问 %s://localhost:%s", scheme, port)
+	log.Printf("MAA 获取任务端点: %s://localhost:%s/maa/getTask", scheme, port)
+	log.Printf("MAA 汇报任务端点: %s://localhost:%s/maa/reportStatus", scheme, port)
+
+	srv := &http.Server{Addr: cfg.Listen, Handler: r}
+	if certs == nil {
+		return srv.ListenAndServe()
+	}
+	defer certs.Close()
+	srv.TLSConfig = &tls.Config{GetCertificate: certs.GetCertificate, MinVersion: tls.VersionTLS12}
+	if redirectPort := cfg.TLS.RedirectPort; redirectPort != 0 {
+		go func() {
+			log.Printf("HTTP 端口 %d 的请求将跳转到 HTTPS", redirectPort)
+			log.Fatal(http.ListenAndServe(":"+strconv.Itoa(redirectPort), redirectToHTTPS(port)))
+		}()
+	}
+	return srv.ListenAndServeTLS("", "")
+}
 
-	r := gin.Default()
+// newRouter 注册所有路由。返回的 reload 用于配置热更新时调整限流速率和 IP 规则。
+func newRouter(cfg *config.Config, h *handler.Handler) (r *gin.Engine, reload func(*config.Config) error, err error) {
+	r = gin.Default()
+	// 只有来自受信任代理的请求才采用 X-Forwarded-For 作为客户端 IP
+	if err = r.SetTrustedProxies(cfg.Auth.TrustedProxies); err != nil {
+		return nil, nil, fmt.Errorf("auth.trusted_proxies 无效: %w", err)
+	}
+	maaIPs, err := handler.NewIPFilter("access.maa", cfg.Access.MAA.Allow, cfg.Access.MAA.Deny)
+	if err != nil {
+		return nil, nil, err
+	}
+	adminIPs, err := handler.NewIPFilter("access.admin", cfg.Access.Admin.Allow, cfg.Access.Admin.Deny)
+	if err != nil {
+		return nil, nil, err
+	}
+	dashboardIPs, err := handler.NewIPFilter("access.dashboard", cfg.Access.Dashboard.Allow, cfg.Access.Dashboard.Deny)
+	if err != nil {
+		return nil, nil, err
+	}
+	maaFilter, adminFilter := maaIPs.Middleware(), adminIPs.Middleware()
+	maaLimiter := handler.NewRateLimiter(cfg.Limits.RateMAA)
+	adminLimiter := handler.NewRateLimiter(cfg.Limits.RateAdmin)
+	maaLimit, adminLimit := maaLimiter.Middleware(), adminLimiter.Middleware()
+	smallBody, reportBody := h.LimitBody(false), h.LimitBody(true)
 
 	// MAA 协议端点（匿名可访问，符合协议要求）
-	r.POST("/maa/getTask", h.GetTask)
-	r.POST("/maa/reportStatus", h.ReportStatus)
+	maa := r.Group("/maa", maaFilter, maaLimit)
+	maa.POST("/getTask", smallBody, h.GetTask)
+	maa.POST("/reportStatus", reportBody, h.ReportStatus)
+	// 带设备令牌的端点，令牌由 /admin/devices/:device/token 生成
+	maa.POST("/:token/getTask", smallBody, h.GetTask)
+	maa.POST("/:token/reportStatus", reportBody, h.ReportStatus)
 
-	// 管理端点（可通过 ADMIN_TOKEN 环境变量保护）
-	admin := r.Group("/admin", h.AdminAuth())
+	// 管理端点（需登录，按角色或 API 密钥的 scope 授权）
+	viewer, operator := store.RoleViewer, store.RoleOperator
+	admin := r.Group("/admin", adminFilter, adminLimit, smallBody, h.Authenticate())
 	{
-		admin.POST("/task", h.SubmitTask)
-		admin.GET("/tasks", h.ListTasks)
-		admin.GET("/screenshot/:id", h.GetScreenshot)
+		admin.GET("/tasks", h.Allow(viewer, store.ScopeTasksRead), h.ListTasks)
+		admin.GET("/tasks/:id/wait", h.Allow(viewer, store.ScopeTasksRead), h.WaitTask)
+		admin.POST("/task", h.Allow(operator, store.ScopeTasksSubmit), h.SubmitTask)
+		admin.POST("/tasks/batch", h.Allow(operator, store.ScopeTasksSubmit), h.SubmitBatch)
+		// 确认须由登录用户操作，API 密钥不能确认
+		admin.POST("/task/:id/approve", h.Allow(operator, ""), h.ApproveTask)
+		admin.POST("/task/:id/reject", h.Allow(operator, ""), h.RejectTask)
 	}
+	adminRoutes(admin, h)
+
+	// 版本化 API：与 /admin 共用处理函数，任务接口支持过滤和分页，错误统一为信封格式
+	v1 := r.Group("/api/v1", handler.ErrorEnvelope(), adminFilter, adminLimit, smallBody, h.Authenticate())
+	{
+		v1.GET("/tasks", h.Allow(viewer, store.ScopeTasksRead), h.QueryTasks)
+		v1.GET("/tasks/:id", h.Allow(viewer, store.ScopeTasksRead), h.GetTaskByID)
+		v1.GET("/tasks/:id/wait", h.Allow(viewer, store.ScopeTasksRead), h.WaitTask)
+		v1.POST("/tasks", h.Allow(operator, store.ScopeTasksSubmit), h.SubmitTask)
+		v1.POST("/tasks/batch", h.Allow(operator, store.ScopeTasksSubmit), h.SubmitBatch)
+		v1.POST("/tasks/:id/approve", h.Allow(operator, ""), h.ApproveTask)
+		v1.POST("/tasks/:id/reject", h.Allow(operator, ""), h.RejectTask)
+	}
+	adminRoutes(v1, h)
+	r.NoRoute(handler.NoRoute)
+
+	// 截图分享链接（凭签名访问，无需 Token）
+	r.GET("/s/:id", adminLimit, h.SharedScreenshot)
+	// OpenAPI 文档（无需登录）
+	r.GET("/api/openapi.json", adminFilter, adminLimit, h.OpenAPI)
+
+	dashboard := r.Group("", dashboardIPs.Middleware())
 
 	// 静态文件（内嵌于二进制，无需外部 static/ 目录）
 	sub, _ := fs.Sub(staticfiles.FS, ".")
-	r.StaticFS("/static", http.FS(sub))
+	dashboard.StaticFS("/static", http.FS(sub))
 
-	// 控制面板
-	r.GET("/", h.Dashboard)
+	// 控制面板与登录
+	dashboard.GET("/", h.Dashboard)
+	dashboard.GET("/login", h.LoginPage)
+	dashboard.POST("/login", adminLimit, smallBody, h.Login)
+	dashboard.POST("/logout", adminLimit, smallBody, h.Logout)
 
-	log.Printf("MAA Remote 已启动，访问 http://localhost:%s", port)
-	log.Printf("MAA 获取任务端点: http://localhost:%s/maa/getTask", port)
-	log.Printf("MAA 汇报任务端点: http://localhost:%s/maa/reportStatus", port)
-	log.Fatal(r.Run(":" + port))
+	reload = func(next *config.Config) error {
+		maaLimiter.SetRate(next.Limits.RateMAA)
+		adminLimiter.SetRate(next.Limits.RateAdmin)
+		return errors.Join(
+			maaIPs.Set(next.Access.MAA.Allow, next.Access.MAA.Deny),
+			adminIPs.Set(next.Access.Admin.Allow, next.Access.Admin.Deny),
+			dashboardIPs.Set(next.Access.Dashboard.Allow, next.Access.Dashboard.Deny),
+		)
+	}
+	return r, reload, nil
+}
+
+// adminRoutes 注册 /admin 和 /api/v1 共有的管理端点
+func adminRoutes(g *gin.RouterGroup, h *handler.Handler) {
+	viewer, operator, adminOnly := store.RoleViewer, store.RoleOperator, store.RoleAdmin
+	g.GET("/me", h.Me)
+	g.GET("/alerts", h.Allow(viewer, store.ScopeTasksRead), h.ListAlerts)
+	g.GET("/queue", h.Allow(viewer, store.ScopeTasksRead), h.Queue)
+	g.POST("/queue/:id/move", h.Allow(operator, store.ScopeTasksSubmit), h.MoveTask)
+	g.GET("/devices", h.Allow(viewer, store.ScopeDevicesManage), h.ListDevices)
+	g.GET("/devices/tokens", h.Allow(adminOnly, store.ScopeDevicesManage), h.ListDeviceTokens)
+	g.POST("/devices/:device/token", h.Allow(adminOnly, store.ScopeDevicesManage), h.IssueDeviceToken)
+	g.DELETE("/devices/:device/token", h.Allow(adminOnly, store.ScopeDevicesManage), h.RevokeDeviceToken)
+	// 同时需要下发任务和查看截图的权限
+	g.POST("/devices/:device/screenshot", h.Allow(operator, store.ScopeTasksSubmit), h.Allow(viewer, store.ScopeScreenshotsRead), h.CaptureScreenshot)
+
+	g.GET("/screenshot/:id", h.Allow(viewer, store.ScopeScreenshotsRead), h.GetScreenshot)
+	g.GET("/screenshot/:id/diff/:other", h.Allow(viewer, store.ScopeScreenshotsRead), h.DiffScreenshots)
+	g.POST("/screenshot/:id/share", h.Allow(operator, store.ScopeScreenshotsRead), h.ShareScreenshot)
+	g.POST("/timelapse", h.Allow(operator, store.ScopeScreenshotsRead), h.CreateTimelapse)
+	g.GET("/timelapse/:id", h.Allow(viewer, store.ScopeScreenshotsRead), h.GetTimelapse)
+	g.GET("/timelapse/:id/download", h.Allow(viewer, store.ScopeScreenshotsRead), h.DownloadTimelapse)
+	g.DELETE("/timelapse/:id", h.Allow(operator, store.ScopeScreenshotsRead), h.DeleteTimelapse)
+
+	g.GET("/references", h.Allow(viewer, ""), h.ListReferences)
+	g.POST("/references", h.Allow(adminOnly, ""), h.AddReference)
+	g.DELETE("/references/:id", h.Allow(adminOnly, ""), h.DeleteReference)
+	g.POST("/share/rotate", h.Allow(adminOnly, ""), h.RotateShareKey)
+
+	// 账号与密钥管理只允许管理员登录操作，API 密钥不能访问
+	manage := g.Group("", h.Allow(adminOnly, ""))
+	{
+		manage.GET("/users", h.ListUsers)
+		manage.POST("/users", h.CreateUser)
+		manage.PUT("/users/:name", h.UpdateUser)
+		manage.DELETE("/users/:name", h.DeleteUser)
+		manage.GET("/roles", h.ListRoles)
+		manage.PUT("/roles/:role", h.SetRoleTypes)
+		manage.GET("/keys", h.ListKeys)
+		manage.POST("/keys", h.CreateKey)
+		manage.DELETE("/keys/:id", h.DeleteKey)
+		manage.GET("/settings-rules", h.GetSettingsRules)
+		manage.PUT("/settings-rules", h.SetSettingsRules)
+		manage.GET("/audit", h.ListAudit)
+		manage.GET("/audit/export", h.ExportAudit)
+	}
+}
+
+// openDataDir 读取配置并检查数据目录，返回配置和配置文件路径。
+// create 为真时创建不存在的数据目录，否则报错，供只读检查的子命令使用。
+func openDataDir(configFlag string, create bool) (*config.Config, string, error) {
+	configPath := config.Path(configFlag)
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.DataDir != "" {
+		if create {
+			if err := os.MkdirAll(cfg.DataDir, 0755); err != nil {
+				return nil, "", fmt.Errorf("创建数据目录失败: %w", err)
+			}
+		} else if _, err := os.Stat(cfg.DataDir); err != nil {
+			return nil, "", fmt.Errorf("数据目录不可用: %w", err)
+		}
+	}
+	return cfg, configPath, nil
+}
+
+// bootstrapPassword 返回初始管理员密码，兼容只设置了 ADMIN_TOKEN 的旧部署。
+// admin_password 的长度由配置校验保证；admin_token 短于 MinPasswordLen 时不用作密码，
+// 返回空字符串，由 Bootstrap 随机生成。
+func bootstrapPassword(cfg *config.Config) string {
+	if cfg.Auth.AdminPassword != "" {
+		return cfg.Auth.AdminPassword
+	}
+	if len(cfg.Auth.AdminToken) < store.MinPasswordLen {
+		return ""
+	}
+	return cfg.Auth.AdminToken
+}
+
+// reloadOptions 返回热更新后的设置。已有截图按启动时的截图目录校验路径，所以 screenshot_dir 保持不变。
+func reloadOptions(cur, next *config.Config) handler.Options {
+	opts := handlerOptions(next)
+	opts.ScreenshotDir = cur.ScreenshotDir
+	return opts
+}
+
+func handlerOptions(cfg *config.Config) handler.Options {
+	return handler.Options{
+		ScreenshotDir: cfg.ScreenshotDir,
+		AdminToken:    cfg.Auth.AdminToken,
+		MaxBody:       int64(cfg.Limits.MaxBody),
+		MaxReportBody: int64(cfg.Limits.MaxReportBody),
+
+		RequireDeviceToken: cfg.Auth.RequireDeviceToken,
+		LoginMaxFailures:   cfg.Auth.LoginMaxFailures,
+		LoginLockout:       time.Duration(cfg.Auth.LoginLockout),
+
+		ConfirmTypes:     cfg.Confirm.Types,
+		ConfirmSelfDelay: time.Duration(cfg.Confirm.SelfDelay),
+
+		WatchdogFrames:    cfg.Watchdog.Frames,
+		WatchdogThreshold: cfg.Watchdog.Threshold,
+		WatchdogStop:      cfg.Watchdog.Stop,
+	}
+}
+
+// watchReload 在收到 SIGHUP 时重新读取配置。新配置无效时保留原配置；
+// 需要重启才能生效的设置只提示，不应用。
+func watchReload(path string, cur *config.Config, apply func(*config.Config) error) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		next, err := config.Load(path)
+		if err != nil {
+			log.Printf("重新加载配置失败，继续使用原配置: %v", err)
+			continue
+		}
+		if changed := cur.Restart(next); len(changed) > 0 {
+			log.Printf("以下设置需要重启才能生效: %s", strings.Join(changed, ", "))
+		}
+		if err := apply(next); err != nil {
+			log.Printf("部分设置未能应用: %v", err)
+			continue
+		}
+		log.Printf("已重新加载配置")
+	}
 }
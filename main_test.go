@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"ArknightsMaaRemoter/config"
+	"ArknightsMaaRemoter/handler"
+	"ArknightsMaaRemoter/store"
+)
+
+const testPassword = "test-password"
+
+// fixture 是请求某个路由时使用的请求体和期望的状态码（0 表示只要是文档中列出的状态码即可）
+type fixture struct {
+	body   string
+	form   bool
+	status int
+}
+
+// TestRoutesMatchOpenAPI 请求 main.go 注册的每个路由，检查路由都在 OpenAPI 文档中，
+// 返回的状态码在文档中列出，JSON 响应体符合文档的 schema；同时检查文档中没有多余的端点。
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	dir := t.TempDir()
+
+	cfg := config.Default()
+	cfg.DataDir = dir
+	cfg.Limits.RateMAA, cfg.Limits.RateAdmin = 0, 0
+	users := store.NewUsers(dir)
+	users.Bootstrap("admin", testPassword)
+	h := handler.New(dir, store.New(dir), store.NewReferences(dir), users, store.NewAPIKeys(dir), store.NewSessions(dir, time.Hour), store.NewDeviceTokens(dir), store.NewAudit(dir), store.NewSettings(dir))
+	h.SetOptions(handlerOptions(cfg))
+	// 延时回放在后台生成，先于 t.TempDir 的清理取消并等待
+	t.Cleanup(h.Close)
+	r, _, err := newRouter(cfg, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := request(r, "GET", "/api/openapi.json", "", false)
+	var doc map[string]any
+	if err := json.Unmarshal(raw.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("解析 OpenAPI 文档失败: %v", err)
+	}
+	v := &validator{doc: doc}
+
+	// 准备数据：一个已完成的截图任务和一个已下发未汇报的任务
+	shot := submit(t, r, `{"type":"CaptureImageNow","device":"dev1"}`)
+	start := submit(t, r, `{"type":"LinkStart","device":"dev1"}`)
+	request(r, "POST", "/maa/getTask", `{"user":"u1","device":"dev1"}`, false)
+	report := fmt.Sprintf(`{"user":"u1","device":"dev1","task":%q,"status":"SUCCESS","payload":%q}`, shot, testPNG())
+	if w := request(r, "POST", "/maa/reportStatus", report, false); w.Code != http.StatusOK {
+		t.Fatalf("汇报截图失败: %d %s", w.Code, w.Body)
+	}
+
+	// 模拟在线的 MAA：之后下发的截图任务会被执行并汇报，供同步截图端点使用
+	stop := make(chan struct{})
+	defer close(stop)
+	go simulateMAA(r, stop, map[string]bool{shot: true})
+
+	params := map[string]string{
+		"id": shot, "other": shot, "device": "dev1", "name": "viewer1",
+		"role": "operator", "token": "invalid", "filepath": "Top.png",
+	}
+	submitBody := `{"type":"LinkStart","device":"dev1"}`
+	batchBody := `{"tasks":[{"type":"LinkStart","device":"dev1"},{"type":"LinkStart-Base","device":"dev1"}]}`
+	fixtures := map[string]fixture{
+		"POST /maa/getTask":                       {body: `{"user":"u1","device":"dev1"}`, status: 200},
+		"POST /maa/reportStatus":                  {body: fmt.Sprintf(`{"user":"u1","device":"dev1","task":%q,"status":"SUCCESS","payload":""}`, start), status: 200},
+		"POST /maa/:token/getTask":                {body: `{"user":"u1","device":"dev1"}`},
+		"POST /maa/:token/reportStatus":           {body: `{"user":"u1","device":"dev1","task":"x","status":"SUCCESS","payload":""}`},
+		"GET /admin/tasks":                        {status: 200},
+		"POST /admin/task":                        {body: submitBody, status: 200},
+		"POST /admin/tasks/batch":                 {body: batchBody, status: 200},
+		"GET /api/v1/tasks":                       {status: 200},
+		"GET /api/v1/tasks/:id":                   {status: 200},
+		"GET /api/v1/tasks/:id/wait":              {status: 200},
+		"GET /admin/tasks/:id/wait":               {status: 200},
+		"POST /api/v1/tasks":                      {body: submitBody, status: 200},
+		"POST /api/v1/tasks/batch":                {body: batchBody, status: 200},
+		"GET /admin/queue":                        {status: 200},
+		"POST /admin/queue/:id/move":              {body: `{"to":"front"}`, status: 409}, // 截图任务已完成，不在队列中
+		"POST /api/v1/queue/:id/move":             {body: `{"to":"up"}`, status: 409},
+		"GET /admin/me":                           {status: 200},
+		"GET /api/v1/me":                          {status: 200},
+		"GET /admin/screenshot/:id":               {status: 200},
+		"GET /admin/screenshot/:id/diff/:other":   {status: 200},
+		"POST /admin/screenshot/:id/share":        {status: 200},
+		"POST /admin/timelapse":                   {body: `{"format":"zip"}`, status: 202},
+		"POST /api/v1/timelapse":                  {body: `{"format":"gif"}`, status: 202},
+		"POST /admin/devices/:device/token":       {status: 200},
+		"POST /admin/devices/:device/screenshot":  {status: 200},
+		"POST /api/v1/devices/:device/screenshot": {status: 200},
+		"POST /admin/users":                       {body: `{"username":"viewer1","password":"password1","role":"viewer"}`, status: 200},
+		"POST /api/v1/users":                      {body: `{"username":"viewer1","password":"password1","role":"viewer"}`, status: 200},
+		"PUT /admin/users/:name":                  {body: `{"role":"operator"}`, status: 200},
+		"PUT /api/v1/users/:name":                 {body: `{"password":"password2"}`, status: 200},
+		"PUT /admin/roles/:role":                  {body: `{"types":["LinkStart*"]}`, status: 200},
+		"PUT /api/v1/roles/:role":                 {body: `{"types":["*"]}`, status: 200},
+		"POST /admin/keys":                        {body: `{"name":"ci","scopes":["tasks:read"]}`, status: 200},
+		"POST /api/v1/keys":                       {body: `{"name":"bot","scopes":["tasks:submit"],"types":["*"],"expires_in":"1h"}`, status: 200},
+		"PUT /admin/settings-rules":               {body: `{"types":["Settings-Stage1"]}`, status: 200},
+		"PUT /api/v1/settings-rules":              {body: `{"types":[]}`, status: 200},
+		"GET /admin/audit":                        {status: 200},
+		"GET /admin/audit/export":                 {status: 200},
+		"POST /admin/references":                  {body: "label=x", form: true, status: 400},
+		"GET /static/*filepath":                   {status: 200},
+		"POST /login":                             {body: "username=admin&password=" + testPassword, form: true, status: 303},
+	}
+
+	// 先按路径排序，保证新建（POST /users）在修改和删除（/users/:name）之前
+	routes := r.Routes()
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return methodOrder(routes[i].Method) < methodOrder(routes[j].Method)
+	})
+
+	paths, _ := doc["paths"].(map[string]any)
+	documented := make(map[string]bool)
+	for path, item := range paths {
+		for method := range item.(map[string]any) {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		documentedPath := specPath(route.Path)
+		op, ok := paths[documentedPath].(map[string]any)[strings.ToLower(route.Method)].(map[string]any)
+		if !ok {
+			t.Errorf("%s 没有写入 OpenAPI 文档", key)
+			continue
+		}
+		delete(documented, route.Method+" "+documentedPath)
+
+		f := fixtures[key]
+		if f.body != "" && !f.form {
+			schema := op["requestBody"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"]
+			var body any
+			_ = json.Unmarshal([]byte(f.body), &body)
+			if err := v.validate(schema, body, "请求体"); err != nil {
+				t.Errorf("%s 测试用的请求体不符合文档: %v", key, err)
+			}
+		}
+		path := route.Path
+		for name, value := range params {
+			path = strings.Replace(path, ":"+name, value, 1)
+			path = strings.Replace(path, "*"+name, value, 1)
+		}
+		w := request(r, route.Method, path, f.body, f.form)
+		if f.status != 0 && w.Code != f.status {
+			t.Errorf("%s 返回 %d，期望 %d: %s", key, w.Code, f.status, w.Body)
+		}
+
+		resp, ok := op["responses"].(map[string]any)[strconv.Itoa(w.Code)].(map[string]any)
+		if !ok {
+			t.Errorf("%s 返回了文档中没有的状态码 %d: %s", key, w.Code, w.Body)
+			continue
+		}
+		if err := v.checkResponse(resp, w); err != nil {
+			t.Errorf("%s 的 %d 响应不符合文档: %v", key, w.Code, err)
+		}
+	}
+	for key := range documented {
+		t.Errorf("文档中的 %s 没有对应的路由", key)
+	}
+}
+
+func TestBootstrapPassword(t *testing.T) {
+	for _, c := range []struct{ password, token, want string }{
+		{"password1", "legacy-token", "password1"},
+		{"", "legacy-token", "legacy-token"},
+		{"", "short", ""}, // 不足 8 位的旧 Token 不用作密码，改为随机生成
+		{"", "", ""},
+	} {
+		cfg := config.Default()
+		cfg.Auth.AdminPassword, cfg.Auth.AdminToken = c.password, c.token
+		if got := bootstrapPassword(cfg); got != c.want {
+			t.Errorf("admin_password=%q admin_token=%q: 初始密码为 %q，期望 %q", c.password, c.token, got, c.want)
+		}
+	}
+}
+
+func TestReloadOptions(t *testing.T) {
+	cur, next := config.Default(), config.Default()
+	next.ScreenshotDir = "shots"
+	next.Limits.MaxBody = 1 << 10
+	opts := reloadOptions(cur, next)
+	if opts.ScreenshotDir != cur.ScreenshotDir || opts.MaxBody != 1<<10 {
+		t.Errorf("热更新后 screenshot_dir = %s，max_body = %d", opts.ScreenshotDir, opts.MaxBody)
+	}
+}
+
+// specPath 把 Gin 的 :id、*filepath 转换为 OpenAPI 的 {id}、{filepath}
+func specPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
+			parts[i] = "{" + p[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func methodOrder(method string) int {
+	return strings.Index("GET HEAD POST PUT DELETE", method)
+}
+
+func request(r http.Handler, method, path, body string, form bool) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	switch {
+	case form:
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	case body != "":
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, "/api/v1/") {
+		req.SetBasicAuth("admin", testPassword)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func submit(t *testing.T, r http.Handler, body string) string {
+	w := request(r, "POST", "/api/v1/tasks", body, false)
+	var task store.Task
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &task) != nil {
+		t.Fatalf("下发任务失败: %d %s", w.Code, w.Body)
+	}
+	return task.ID
+}
+
+// simulateMAA 以 dev1 的身份轮询，把截图任务汇报为成功，skip 中的任务不再汇报
+func simulateMAA(r http.Handler, stop <-chan struct{}, skip map[string]bool) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+		var resp struct {
+			Tasks []struct{ ID, Type string }
+		}
+		w := request(r, "POST", "/maa/getTask", `{"user":"u1","device":"dev1"}`, false)
+		_ = json.Unmarshal(w.Body.Bytes(), &resp)
+		for _, t := range resp.Tasks {
+			if t.Type != "CaptureImageNow" || skip[t.ID] {
+				continue
+			}
+			skip[t.ID] = true
+			report := fmt.Sprintf(`{"user":"u1","device":"dev1","task":%q,"status":"SUCCESS","payload":%q}`, t.ID, testPNG())
+			request(r, "POST", "/maa/reportStatus", report, false)
+		}
+	}
+}
+
+func testPNG() string {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 4)))
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// ── 按 OpenAPI 3.0 schema 校验 ────────────────────────────────
+
+type validator struct {
+	doc map[string]any
+}
+
+// checkResponse 检查响应的 Content-Type 和 JSON 响应体
+func (v *validator) checkResponse(resp map[string]any, w *httptest.ResponseRecorder) error {
+	contents, _ := resp["content"].(map[string]any)
+	if len(contents) == 0 {
+		if w.Body.Len() > 0 && w.Code != http.StatusSeeOther {
+			return fmt.Errorf("文档中没有响应体，实际返回了 %d 字节", w.Body.Len())
+		}
+		return nil
+	}
+	media, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if _, ok := contents["*/*"]; ok {
+		return nil
+	}
+	c, ok := contents[media].(map[string]any)
+	if !ok {
+		return fmt.Errorf("Content-Type %q 不在文档中", media)
+	}
+	schema, ok := c["schema"]
+	if !ok {
+		return nil
+	}
+	var body any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		return fmt.Errorf("响应体不是 JSON: %v", err)
+	}
+	return v.validate(schema, body, "响应体")
+}
+
+func (v *validator) validate(schema, value any, at string) error {
+	s, _ := schema.(map[string]any)
+	if ref, ok := s["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		return v.validate(v.doc["components"].(map[string]any)["schemas"].(map[string]any)[name], value, at)
+	}
+	if value == nil {
+		if s["nullable"] == true || len(s) == 0 {
+			return nil
+		}
+		return fmt.Errorf("%s 为 null", at)
+	}
+	if all, ok := s["allOf"].([]any); ok {
+		for _, sub := range all {
+			if err := v.validate(sub, value, at); err != nil {
+				return err
+			}
+		}
+	}
+	if one, ok := s["oneOf"].([]any); ok {
+		var errs []string
+		for _, sub := range one {
+			err := v.validate(sub, value, at)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return fmt.Errorf("%s 不符合任何一种格式: %s", at, strings.Join(errs, "；"))
+	}
+
+	switch s["type"] {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s 应为对象", at)
+		}
+		required, _ := s["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				return fmt.Errorf("%s 缺少字段 %s", at, name)
+			}
+		}
+		props, _ := s["properties"].(map[string]any)
+		for name, field := range obj {
+			sub, ok := props[name]
+			if !ok {
+				sub, ok = s["additionalProperties"].(map[string]any)
+			}
+			if !ok {
+				return fmt.Errorf("%s 有文档中没有的字段 %s", at, name)
+			}
+			if err := v.validate(sub, field, at+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s 应为数组", at)
+		}
+		for i, item := range items {
+			if err := v.validate(s["items"], item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s 应为字符串", at)
+		}
+		if enum, ok := s["enum"].([]any); ok && !containsValue(enum, str) {
+			return fmt.Errorf("%s 的值 %q 不在枚举中", at, str)
+		}
+		if s["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, str); err != nil {
+				return fmt.Errorf("%s 不是 RFC3339 时间: %q", at, str)
+			}
+		}
+	case "integer":
+		if n, ok := value.(float64); !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s 应为整数", at)
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			return fmt.Errorf("%s 应为数字", at)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s 应为布尔值", at)
+		}
+	}
+	return nil
+}
+
+func containsValue(list []any, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Scope string
+
+const (
+	ScopeTasksSubmit     Scope = "tasks:submit"
+	ScopeTasksRead       Scope = "tasks:read"
+	ScopeScreenshotsRead Scope = "screenshots:read"
+	ScopeDevicesManage   Scope = "devices:manage"
+)
+
+var validScopes = map[Scope]bool{
+	ScopeTasksSubmit:     true,
+	ScopeTasksRead:       true,
+	ScopeScreenshotsRead: true,
+	ScopeDevicesManage:   true,
+}
+
+const apiKeyPrefix = "maa_"
+
+var (
+	ErrInvalidScope = errors.New("scope 必须是 tasks:submit、tasks:read、screenshots:read 或 devices:manage")
+	ErrKeyNoTypes   = errors.New("包含 tasks:submit 时必须通过 types 指定可下发的任务类型（* 表示全部）")
+	ErrKeyNoName    = errors.New("name 不能为空")
+)
+
+// APIKey 是供脚本和机器人使用的受限凭证，只保存密钥的 SHA-256 摘要
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // 密钥开头几位，便于辨认
+	Hash       string     `json:"hash,omitempty"`
+	Scopes     []Scope    `json:"scopes"`
+	Types      []string   `json:"types,omitempty"`   // 可下发的任务类型，末尾 * 表示前缀匹配
+	Devices    []string   `json:"devices,omitempty"` // 为空表示不限设备
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// HasScope 判断密钥是否拥有某个 scope
+func (k *APIKey) HasScope(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// TypeAllowed 判断密钥能否下发该类型的任务
+func (k *APIKey) TypeAllowed(taskType string) bool {
+	if !k.HasScope(ScopeTasksSubmit) {
+		return false
+	}
+	for _, pattern := range k.Types {
+		if MatchType(pattern, taskType) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeviceAllowed 判断密钥能否访问该设备
+func (k *APIKey) DeviceAllowed(device string) bool {
+	if len(k.Devices) == 0 {
+		return true
+	}
+	for _, d := range k.Devices {
+		if d == device {
+			return true
+		}
+	}
+	return false
+}
+
+type APIKeys struct {
+	mu   sync.RWMutex
+	keys []*APIKey
+	file string
+}
+
+func NewAPIKeys(dir string) *APIKeys {
+	k := &APIKeys{
+		keys: make([]*APIKey, 0),
+		file: filepath.Join(dir, "apikeys.json"),
+	}
+	k.load()
+	return k
+}
+
+// Create 生成新密钥，返回记录（不含摘要）和只会出现这一次的明文密钥
+func (k *APIKeys) Create(key APIKey) (*APIKey, string, error) {
+	key.Name = strings.TrimSpace(key.Name)
+	if key.Name == "" {
+		return nil, "", ErrKeyNoName
+	}
+	for _, s := range key.Scopes {
+		if !validScopes[s] {
+			return nil, "", ErrInvalidScope
+		}
+	}
+	if key.HasScope(ScopeTasksSubmit) && len(key.Types) == 0 {
+		return nil, "", ErrKeyNoTypes
+	}
+
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	secret := apiKeyPrefix + hex.EncodeToString(b)
+	key.ID = uuid.NewString()
+	key.Prefix = secret[:len(apiKeyPrefix)+6]
+	key.Hash = hashKey(secret)
+	key.CreatedAt = time.Now()
+	key.LastUsedAt = nil
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.keys = append(k.keys, &key)
+	k.save()
+	return redact(&key), secret, nil
+}
+
+// Lookup 按明文密钥查找未过期的记录，并更新最后使用时间
+func (k *APIKeys) Lookup(secret string) *APIKey {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return nil
+	}
+	hash := hashKey(secret)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, key := range k.keys {
+		if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		now := time.Now()
+		if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+			return nil
+		}
+		// 脚本可能频繁调用，最后使用时间每分钟最多落盘一次
+		stale := key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > time.Minute
+		key.LastUsedAt = &now
+		if stale {
+			k.save()
+		}
+		return redact(key)
+	}
+	return nil
+}
+
+// List 返回所有密钥（不含摘要）
+func (k *APIKeys) List() []*APIKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	result := make([]*APIKey, 0, len(k.keys))
+	for _, key := range k.keys {
+		result = append(result, redact(key))
+	}
+	return result
+}
+
+// Delete 吊销密钥
+func (k *APIKeys) Delete(id string) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for i, key := range k.keys {
+		if key.ID == id {
+			k.keys = append(k.keys[:i], k.keys[i+1:]...)
+			k.save()
+			return true
+		}
+	}
+	return false
+}
+
+func hashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func redact(key *APIKey) *APIKey {
+	cp := *key
+	cp.Hash = ""
+	return &cp
+}
+
+func (k *APIKeys) save() {
+	data, _ := json.MarshalIndent(k.keys, "", "  ")
+	_ = os.WriteFile(k.file, data, 0600)
+}
+
+func (k *APIKeys) load() {
+	data, err := os.ReadFile(k.file)
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(data, &k.keys)
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyCreate(t *testing.T) {
+	k := NewAPIKeys(t.TempDir())
+	for _, c := range []struct {
+		key  APIKey
+		want error
+	}{
+		{APIKey{Name: " ", Scopes: []Scope{ScopeTasksRead}}, ErrKeyNoName},
+		{APIKey{Name: "ci", Scopes: []Scope{"tasks:*"}}, ErrInvalidScope},
+		{APIKey{Name: "ci", Scopes: []Scope{ScopeTasksSubmit}}, ErrKeyNoTypes},
+		{APIKey{Name: "ci", Scopes: []Scope{ScopeTasksSubmit}, Types: []string{"LinkStart*"}}, nil},
+	} {
+		if _, _, err := k.Create(c.key); !errors.Is(err, c.want) {
+			t.Errorf("Create(%+v) = %v，期望 %v", c.key, err, c.want)
+		}
+	}
+}
+
+func TestAPIKeyLookup(t *testing.T) {
+	dir := t.TempDir()
+	k := NewAPIKeys(dir)
+	info, secret, err := k.Create(APIKey{Name: "bot", Scopes: []Scope{ScopeTasksRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, apiKeyPrefix) || info.Hash != "" || !strings.HasPrefix(secret, info.Prefix) {
+		t.Errorf("密钥 %q，记录 %+v", secret, info)
+	}
+	// 文件中只保存摘要
+	if key := NewAPIKeys(dir).Lookup(secret); key == nil || key.ID != info.ID || key.Hash != "" || key.LastUsedAt == nil {
+		t.Errorf("重新加载后查找返回 %+v", key)
+	}
+	for _, wrong := range []string{"", secret[:len(secret)-1], "maa_" + strings.Repeat("0", 48), strings.TrimPrefix(secret, apiKeyPrefix)} {
+		if k.Lookup(wrong) != nil {
+			t.Errorf("错误的密钥 %q 查找成功", wrong)
+		}
+	}
+
+	past := time.Now().Add(-time.Second)
+	_, expired, _ := k.Create(APIKey{Name: "old", Scopes: []Scope{ScopeTasksRead}, ExpiresAt: &past})
+	if k.Lookup(expired) != nil {
+		t.Error("过期的密钥查找成功")
+	}
+	if !k.Delete(info.ID) || k.Lookup(secret) != nil {
+		t.Error("吊销后密钥仍然有效")
+	}
+}
+
+func TestAPIKeyPermissions(t *testing.T) {
+	key := &APIKey{Scopes: []Scope{ScopeTasksSubmit}, Types: []string{"LinkStart*", "CaptureImageNow"}, Devices: []string{"dev1"}}
+	for taskType, want := range map[string]bool{"LinkStart": true, "LinkStart-Base": true, "CaptureImageNow": true, "CaptureImage": false, "Settings-Stage1": false} {
+		if got := key.TypeAllowed(taskType); got != want {
+			t.Errorf("TypeAllowed(%s) = %v", taskType, got)
+		}
+	}
+	if !key.DeviceAllowed("dev1") || key.DeviceAllowed("dev2") || key.DeviceAllowed("") {
+		t.Error("限定设备的密钥 DeviceAllowed 结果错误")
+	}
+	// 没有 tasks:submit 时 types 不生效
+	readOnly := &APIKey{Scopes: []Scope{ScopeTasksRead}, Types: []string{"*"}}
+	if readOnly.TypeAllowed("LinkStart") || !readOnly.DeviceAllowed("dev2") {
+		t.Error("只读密钥的权限错误")
+	}
+}
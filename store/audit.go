@@ -0,0 +1,110 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// AuditEntry 是一条审计记录
+type AuditEntry struct {
+	Time      time.Time      `json:"time"`
+	Actor     string         `json:"actor"`            // 用户名；API 密钥为 key:<名称>
+	Action    string         `json:"action"`           // 如 task.submit、user.create、login.failed
+	Target    string         `json:"target,omitempty"` // 被操作对象的 ID 或名称
+	Detail    map[string]any `json:"detail,omitempty"`
+	IP        string         `json:"ip,omitempty"`
+	UserAgent string         `json:"user_agent,omitempty"`
+}
+
+// AuditFilter 是查询审计记录的条件，零值表示不限
+type AuditFilter struct {
+	Actor  string
+	Action string // 末尾 * 表示前缀匹配
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+func (f AuditFilter) match(e *AuditEntry) bool {
+	return (f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Action == "" || MatchType(f.Action, e.Action)) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until))
+}
+
+// Audit 是只追加的审计日志，每行一条 JSON，与 tasks.json 分开存放。
+// 程序只会追加，不提供修改和删除。
+type Audit struct {
+	mu   sync.Mutex
+	file string
+}
+
+func NewAudit(dir string) *Audit {
+	return &Audit{file: filepath.Join(dir, "audit.jsonl")}
+}
+
+// Record 追加一条记录
+func (a *Audit) Record(e AuditEntry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.OpenFile(a.file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Query 返回符合条件的记录，最新的在前
+func (a *Audit) Query(filter AuditFilter) ([]AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	f, err := os.Open(a.file)
+	if os.IsNotExist(err) {
+		return []AuditEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var all []AuditEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e AuditEntry
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if filter.match(&e) {
+			all = append(all, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]AuditEntry, 0, len(all))
+	for i := len(all) - 1; i >= 0; i-- {
+		if filter.Limit > 0 && len(result) >= filter.Limit {
+			break
+		}
+		result = append(result, all[i])
+	}
+	return result, nil
+}
@@ -0,0 +1,89 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditAppendOnly(t *testing.T) {
+	dir := t.TempDir()
+	a := NewAudit(dir)
+	if entries, err := a.Query(AuditFilter{}); err != nil || len(entries) != 0 {
+		t.Fatalf("空日志返回 %v, %v", entries, err)
+	}
+	for _, action := range []string{"user.create", "task.submit"} {
+		if err := a.Record(AuditEntry{Actor: "admin", Action: action}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 每条一行，后写入的追加在末尾；重新打开后仍能读到
+	data, err := os.ReadFile(filepath.Join(dir, "audit.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "user.create") || !strings.Contains(lines[1], "task.submit") {
+		t.Errorf("audit.jsonl = %s", data)
+	}
+	entries, err := NewAudit(dir).Query(AuditFilter{})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("重新打开后查询到 %v, %v", entries, err)
+	}
+	if entries[0].Action != "task.submit" || entries[0].Time.IsZero() {
+		t.Errorf("最新的记录为 %+v", entries[0])
+	}
+
+	// 损坏的行被跳过，不影响其他记录
+	f, _ := os.OpenFile(filepath.Join(dir, "audit.jsonl"), os.O_WRONLY|os.O_APPEND, 0600)
+	_, _ = f.WriteString("{broken\n")
+	f.Close()
+	_ = a.Record(AuditEntry{Actor: "admin", Action: "logout"})
+	if entries, _ := a.Query(AuditFilter{}); len(entries) != 3 {
+		t.Errorf("有损坏的行时查询到 %d 条", len(entries))
+	}
+}
+
+func TestAuditQuery(t *testing.T) {
+	a := NewAudit(t.TempDir())
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	for i, e := range []AuditEntry{
+		{Actor: "admin", Action: "user.create"},
+		{Actor: "alice", Action: "task.submit"},
+		{Actor: "key:ci", Action: "task.submit"},
+		{Actor: "alice", Action: "task.approve"},
+	} {
+		e.Time = base.Add(time.Duration(i) * time.Hour)
+		if err := a.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, c := range []struct {
+		name   string
+		filter AuditFilter
+		want   string
+	}{
+		{"全部", AuditFilter{}, "task.approve,task.submit,task.submit,user.create"},
+		{"按操作者", AuditFilter{Actor: "alice"}, "task.approve,task.submit"},
+		{"按操作", AuditFilter{Action: "task.submit"}, "task.submit,task.submit"},
+		{"操作前缀", AuditFilter{Action: "task.*"}, "task.approve,task.submit,task.submit"},
+		{"时间范围", AuditFilter{Since: base.Add(time.Hour), Until: base.Add(3 * time.Hour)}, "task.submit,task.submit"},
+		{"条数", AuditFilter{Limit: 1}, "task.approve"},
+	} {
+		entries, err := a.Query(c.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actions := make([]string, len(entries))
+		for i, e := range entries {
+			actions[i] = e.Action
+		}
+		if got := strings.Join(actions, ","); got != c.want {
+			t.Errorf("%s: %s，期望 %s", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// dataFiles 是 store 包读写的 JSON 文件及其内容对应的类型
+var dataFiles = []struct {
+	name   string
+	secret bool // 含密码哈希、令牌等，只应对运行用户可读
+	value  func() any
+}{
+	{"tasks.json", false, func() any { return &[]*Task{} }},
+	{"users.json", true, func() any { return &usersData{} }},
+	{"apikeys.json", true, func() any { return &[]*APIKey{} }},
+	{"sessions.json", true, func() any { return &map[string]*Session{} }},
+	{"device_tokens.json", true, func() any { return &map[string]*DeviceToken{} }},
+	{"references.json", false, func() any { return &[]*Reference{} }},
+	{"settings_rules.json", false, func() any { return &SettingsRules{} }},
+}
+
+// FileInfo 描述数据目录中的一个文件
+type FileInfo struct {
+	Name   string
+	Secret bool
+}
+
+// Files 返回 store 包使用的所有数据文件，包括 audit.jsonl
+func Files() []FileInfo {
+	files := make([]FileInfo, 0, len(dataFiles)+1)
+	for _, f := range dataFiles {
+		files = append(files, FileInfo{Name: f.name, Secret: f.secret})
+	}
+	return append(files, FileInfo{Name: "audit.jsonl", Secret: true})
+}
+
+// CheckFiles 校验 dir 目录下的数据文件能否被正确解析。
+// 各 store 加载时会忽略解析错误并从空数据开始，因此损坏的文件只能靠这里发现。
+// 不存在的文件视为正常。
+func CheckFiles(dir string) []string {
+	var problems []string
+	for _, f := range dataFiles {
+		data, err := os.ReadFile(filepath.Join(dir, f.name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", f.name, err))
+			continue
+		}
+		if err := json.Unmarshal(data, f.value()); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: 解析失败: %v", f.name, err))
+		}
+	}
+	return append(problems, checkAudit(filepath.Join(dir, "audit.jsonl"))...)
+}
+
+func checkAudit(file string) []string {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("audit.jsonl: %v", err)}
+	}
+	defer f.Close()
+
+	var problems []string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var e AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			problems = append(problems, fmt.Sprintf("audit.jsonl 第 %d 行: 解析失败: %v", line, err))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		problems = append(problems, fmt.Sprintf("audit.jsonl: %v", err))
+	}
+	return problems
+}
+
+// Check 检查任务数据本身的一致性：重复或缺失的 ID、未知的状态
+func (s *Store) Check() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var problems []string
+	seen := make(map[string]bool, len(s.tasks))
+	for i, t := range s.tasks {
+		switch {
+		case t.ID == "":
+			problems = append(problems, fmt.Sprintf("tasks.json: 第 %d 个任务没有 ID", i+1))
+		case seen[t.ID]:
+			problems = append(problems, fmt.Sprintf("tasks.json: 任务 ID %s 重复", t.ID))
+		}
+		seen[t.ID] = true
+		switch t.Status {
+		case StatusPending, StatusSuccess, StatusFailed, StatusAwaitingApproval, StatusRejected:
+		default:
+			problems = append(problems, fmt.Sprintf("tasks.json: 任务 %s 的状态 %q 未知", t.ID, t.Status))
+		}
+	}
+	return problems
+}
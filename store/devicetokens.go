@@ -0,0 +1,122 @@
+package store
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DeviceToken 是某台 MAA 设备专用的接入令牌，拼在协议端点的路径或查询参数中。
+// 和 API 密钥一样只保存 SHA-256 摘要。
+type DeviceToken struct {
+	Device    string    `json:"device"`
+	Prefix    string    `json:"prefix"`
+	Hash      string    `json:"hash,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DeviceTokens struct {
+	mu     sync.RWMutex
+	tokens map[string]*DeviceToken // 按设备标识索引
+	file   string
+}
+
+func NewDeviceTokens(dir string) *DeviceTokens {
+	t := &DeviceTokens{
+		tokens: make(map[string]*DeviceToken),
+		file:   filepath.Join(dir, "device_tokens.json"),
+	}
+	t.load()
+	return t
+}
+
+// Issue 为设备生成新令牌，旧令牌立即失效。返回只会出现这一次的明文令牌。
+func (t *DeviceTokens) Issue(device string) (*DeviceToken, string) {
+	secret := randomHex(24)
+	tok := &DeviceToken{
+		Device:    device,
+		Prefix:    secret[:6],
+		Hash:      hashKey(secret),
+		CreatedAt: time.Now(),
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.tokens[device] = tok
+	t.save()
+	cp := *tok
+	cp.Hash = ""
+	return &cp, secret
+}
+
+// Revoke 删除设备的令牌，之后该设备重新使用匿名端点
+func (t *DeviceTokens) Revoke(device string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.tokens[device]; !ok {
+		return false
+	}
+	delete(t.tokens, device)
+	t.save()
+	return true
+}
+
+// Device 返回令牌所属的设备，令牌无效时返回空字符串
+func (t *DeviceTokens) Device(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	hash := hashKey(secret)
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, tok := range t.tokens {
+		if subtle.ConstantTimeCompare([]byte(tok.Hash), []byte(hash)) == 1 {
+			return tok.Device
+		}
+	}
+	return ""
+}
+
+// Empty 判断是否还没有为任何设备配置令牌
+func (t *DeviceTokens) Empty() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return len(t.tokens) == 0
+}
+
+// List 返回所有令牌（不含摘要），按设备标识排序
+func (t *DeviceTokens) List() []DeviceToken {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	result := make([]DeviceToken, 0, len(t.tokens))
+	for _, tok := range t.tokens {
+		cp := *tok
+		cp.Hash = ""
+		result = append(result, cp)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Device < result[j].Device })
+	return result
+}
+
+func (t *DeviceTokens) save() {
+	data, _ := json.MarshalIndent(t.tokens, "", "  ")
+	_ = os.WriteFile(t.file, data, 0600)
+}
+
+func (t *DeviceTokens) load() {
+	data, err := os.ReadFile(t.file)
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(data, &t.tokens)
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeviceTokens(t *testing.T) {
+	dir := t.TempDir()
+	tokens := NewDeviceTokens(dir)
+	if !tokens.Empty() {
+		t.Fatal("新建的令牌列表不为空")
+	}
+	tok, secret := tokens.Issue("dev1")
+	if tok.Device != "dev1" || tok.Hash != "" || !strings.HasPrefix(secret, tok.Prefix) {
+		t.Errorf("Issue 返回 %+v", tok)
+	}
+	_, other := tokens.Issue("dev2")
+	if tokens.Empty() || tokens.Device(secret) != "dev1" || tokens.Device(other) != "dev2" {
+		t.Error("令牌未对应到设备")
+	}
+	if tokens.Device("") != "" || tokens.Device(secret+"0") != "" {
+		t.Error("错误的令牌对应到了设备")
+	}
+	if list := tokens.List(); len(list) != 2 || list[0].Device != "dev1" || list[0].Hash != "" {
+		t.Errorf("List 返回 %+v", list)
+	}
+
+	// 文件中只有摘要，重新加载后仍然有效
+	data, err := os.ReadFile(filepath.Join(dir, "device_tokens.json"))
+	if err != nil || strings.Contains(string(data), secret) {
+		t.Errorf("device_tokens.json 中出现了明文令牌（%v）", err)
+	}
+	if NewDeviceTokens(dir).Device(secret) != "dev1" {
+		t.Error("重新加载后令牌无效")
+	}
+
+	// 重新签发后旧令牌失效
+	_, renewed := tokens.Issue("dev1")
+	if tokens.Device(secret) != "" || tokens.Device(renewed) != "dev1" {
+		t.Error("重新签发后旧令牌仍然有效")
+	}
+
+	if !tokens.Revoke("dev1") || tokens.Revoke("dev1") {
+		t.Error("Revoke 的返回值不对")
+	}
+	if tokens.Device(renewed) != "" || NewDeviceTokens(dir).Device(renewed) != "" {
+		t.Error("吊销后令牌仍然有效")
+	}
+	tokens.Revoke("dev2")
+	if !tokens.Empty() {
+		t.Error("吊销所有令牌后列表不为空")
+	}
+}
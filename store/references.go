@@ -0,0 +1,90 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reference 是管理员上传的参考截图片段，用于识别当前游戏画面
+type Reference struct {
+	ID        string    `json:"id"`
+	Label     string    `json:"label"`
+	File      string    `json:"file"`
+	Threshold float64   `json:"threshold"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type References struct {
+	mu   sync.RWMutex
+	refs []*Reference
+	file string
+}
+
+func NewReferences(dir string) *References {
+	r := &References{
+		refs: make([]*Reference, 0),
+		file: filepath.Join(dir, "references.json"),
+	}
+	r.load()
+	return r
+}
+
+// Add 登记一张参考图
+func (r *References) Add(label, file string, threshold float64) *Reference {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ref := &Reference{
+		ID:        uuid.NewString(),
+		Label:     label,
+		File:      file,
+		Threshold: threshold,
+		CreatedAt: time.Now(),
+	}
+	r.refs = append(r.refs, ref)
+	r.save()
+	return ref
+}
+
+// Remove 删除参考图记录，返回被删除的记录（不存在时为 nil）
+func (r *References) Remove(id string) *Reference {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, ref := range r.refs {
+		if ref.ID == id {
+			r.refs = append(r.refs[:i], r.refs[i+1:]...)
+			r.save()
+			return ref
+		}
+	}
+	return nil
+}
+
+// All 返回所有参考图（按上传顺序）
+func (r *References) All() []*Reference {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]*Reference, len(r.refs))
+	copy(result, r.refs)
+	return result
+}
+
+func (r *References) save() {
+	data, _ := json.MarshalIndent(r.refs, "", "  ")
+	_ = os.WriteFile(r.file, data, 0644)
+}
+
+func (r *References) load() {
+	data, err := os.ReadFile(r.file)
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(data, &r.refs)
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Session 是控制面板的登录会话。Cookie 中是随机令牌，这里只保存其 SHA-256 摘要。
+type Session struct {
+	Hash      string    `json:"hash"`
+	Username  string    `json:"username"`
+	CSRF      string    `json:"csrf"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type Sessions struct {
+	mu       sync.Mutex
+	sessions map[string]*Session
+	ttl      time.Duration
+	file     string
+}
+
+// NewSessions 创建会话存储，ttl 是会话闲置多久后过期
+func NewSessions(dir string, ttl time.Duration) *Sessions {
+	s := &Sessions{
+		sessions: make(map[string]*Session),
+		ttl:      ttl,
+		file:     filepath.Join(dir, "sessions.json"),
+	}
+	s.load()
+	return s
+}
+
+// TTL 返回会话闲置过期时间
+func (s *Sessions) TTL() time.Duration {
+	return s.ttl
+}
+
+// Create 为用户新建会话，返回写入 Cookie 的令牌
+func (s *Sessions) Create(username string) (string, *Session) {
+	token := randomHex(32)
+	now := time.Now()
+	sess := &Session{
+		Hash:      hashKey(token),
+		Username:  username,
+		CSRF:      randomHex(16),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[sess.Hash] = sess
+	s.prune(now)
+	s.save()
+	cp := *sess
+	return token, &cp
+}
+
+// Get 按令牌查找未过期的会话，并顺延过期时间
+func (s *Sessions) Get(token string) *Session {
+	if token == "" {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess := s.sessions[hashKey(token)]
+	if sess == nil {
+		return nil
+	}
+	now := time.Now()
+	if now.After(sess.ExpiresAt) {
+		delete(s.sessions, sess.Hash)
+		s.save()
+		return nil
+	}
+	// 控制面板每 2 秒轮询一次，过期时间每分钟最多落盘一次
+	if next := now.Add(s.ttl); next.Sub(sess.ExpiresAt) > time.Minute {
+		sess.ExpiresAt = next
+		s.save()
+	}
+	cp := *sess
+	return &cp
+}
+
+// Delete 注销会话
+func (s *Sessions) Delete(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, hashKey(token))
+	s.save()
+}
+
+// DeleteUser 注销某个用户的全部会话，用于改密码或删除账号后
+func (s *Sessions) DeleteUser(username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, sess := range s.sessions {
+		if sess.Username == username {
+			delete(s.sessions, hash)
+		}
+	}
+	s.save()
+}
+
+func (s *Sessions) prune(now time.Time) {
+	for hash, sess := range s.sessions {
+		if now.After(sess.ExpiresAt) {
+			delete(s.sessions, hash)
+		}
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Sessions) save() {
+	data, _ := json.MarshalIndent(s.sessions, "", "  ")
+	_ = os.WriteFile(s.file, data, 0600)
+}
+
+func (s *Sessions) load() {
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(data, &s.sessions)
+	s.prune(time.Now())
+}
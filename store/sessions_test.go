@@ -0,0 +1,51 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessions(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSessions(dir, time.Hour)
+	token, sess := s.Create("alice")
+	if sess.Username != "alice" || sess.CSRF == "" || sess.CSRF == token {
+		t.Errorf("新会话 %+v", sess)
+	}
+	// 文件中只有令牌的摘要
+	data, err := os.ReadFile(filepath.Join(dir, "sessions.json"))
+	if err != nil || strings.Contains(string(data), token) {
+		t.Errorf("sessions.json 中出现了明文令牌（%v）", err)
+	}
+	if got := NewSessions(dir, time.Hour).Get(token); got == nil || got.CSRF != sess.CSRF {
+		t.Errorf("重新加载后会话为 %+v", got)
+	}
+	if s.Get("") != nil || s.Get(token+"0") != nil {
+		t.Error("错误的令牌找到了会话")
+	}
+
+	other, _ := s.Create("bob")
+	s.DeleteUser("alice")
+	if s.Get(token) != nil || s.Get(other) == nil {
+		t.Error("DeleteUser 只应注销该用户的会话")
+	}
+	s.Delete(other)
+	if s.Get(other) != nil {
+		t.Error("注销后会话仍然有效")
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	s := NewSessions(t.TempDir(), 50*time.Millisecond)
+	token, _ := s.Create("alice")
+	if s.Get(token) == nil {
+		t.Fatal("新会话无效")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if s.Get(token) != nil {
+		t.Error("闲置超过 TTL 的会话仍然有效")
+	}
+}
@@ -0,0 +1,197 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// SettingsRules 是 Settings-* 任务的下发规则
+type SettingsRules struct {
+	// Types 是允许下发的 Settings 类型，末尾 * 表示前缀匹配
+	Types []string `json:"types"`
+	// Values 限定某个类型可用的参数值，为空表示只做格式校验
+	Values map[string][]string `json:"values,omitempty"`
+	// Devices 按设备限定参数值，优先于 Values
+	Devices map[string]map[string][]string `json:"devices,omitempty"`
+}
+
+var defaultSettingsRules = SettingsRules{
+	Types: []string{"Settings-ConnectionAddress", "Settings-Stage1"},
+}
+
+var (
+	ErrSettingsType    = errors.New("不允许下发该 Settings 类型")
+	ErrSettingsValue   = errors.New("参数不在允许的取值范围内")
+	ErrSettingsDevice  = errors.New("该设置按设备限定了取值，必须指定设备")
+	ErrParamsTooLong   = errors.New("参数过长")
+	ErrParamsCharacter = errors.New("参数包含控制字符")
+)
+
+const maxParamsLen = 256
+
+type Settings struct {
+	mu    sync.RWMutex
+	rules SettingsRules
+	file  string
+}
+
+func NewSettings(dir string) *Settings {
+	s := &Settings{rules: defaultSettingsRules, file: filepath.Join(dir, "settings_rules.json")}
+	s.load()
+	return s
+}
+
+// Rules 返回当前规则
+func (s *Settings) Rules() SettingsRules {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rules
+}
+
+// SetRules 替换规则，同时校验其中列出的取值本身是否合法
+func (s *Settings) SetRules(rules SettingsRules) error {
+	check := func(taskType string, values []string) error {
+		for _, v := range values {
+			if err := validateSetting(taskType, v, true); err != nil {
+				return fmt.Errorf("%s 的取值 %q 无效: %w", taskType, v, err)
+			}
+		}
+		return nil
+	}
+	for taskType, values := range rules.Values {
+		if err := check(taskType, values); err != nil {
+			return err
+		}
+	}
+	for _, byType := range rules.Devices {
+		for taskType, values := range byType {
+			if err := check(taskType, values); err != nil {
+				return err
+			}
+		}
+	}
+	if rules.Types == nil {
+		rules.Types = []string{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.rules = rules
+	s.save()
+	return nil
+}
+
+// Check 校验即将下发的任务参数。所有任务的参数都不能过长或含控制字符；
+// Settings-* 任务还须在允许的类型内，并通过格式校验和取值限定。
+// device 为空时任务可能被任何设备领取，因此按设备限定了取值的类型必须指定设备。
+func (s *Settings) Check(device, taskType, params string) error {
+	if len(params) > maxParamsLen {
+		return ErrParamsTooLong
+	}
+	for _, r := range params {
+		if unicode.IsControl(r) {
+			return ErrParamsCharacter
+		}
+	}
+	if !strings.HasPrefix(taskType, "Settings-") {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	allowed := false
+	for _, pattern := range s.rules.Types {
+		if MatchType(pattern, taskType) {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return ErrSettingsType
+	}
+
+	values, listed := s.rules.Values[taskType]
+	if device == "" {
+		for _, byType := range s.rules.Devices {
+			if _, ok := byType[taskType]; ok {
+				return ErrSettingsDevice
+			}
+		}
+	} else if v, ok := s.rules.Devices[device][taskType]; ok {
+		values, listed = v, true
+	}
+	if listed {
+		for _, v := range values {
+			if v == params {
+				return nil
+			}
+		}
+		return ErrSettingsValue
+	}
+	return validateSetting(taskType, params, false)
+}
+
+var (
+	// 主线 1-7、H7-4，资源 CE-6、PR-A-1，活动 S3-7、IW-8，以及 Annihilation（剿灭）
+	stagePattern  = regexp.MustCompile(`^(?:H?\d{1,2}-\d{1,2}|PR-[A-D]-[12]|[A-Z]{1,4}\d{0,2}-\d{1,2}|Annihilation)$`)
+	serialPattern = regexp.MustCompile(`^emulator-\d{1,5}$`)
+)
+
+// validateSetting 按类型校验参数格式。explicit 为真表示取值由管理员明确列出，
+// 此时连接地址可以是任意主机；否则只允许本机和局域网地址。
+func validateSetting(taskType, params string, explicit bool) error {
+	switch {
+	case taskType == "Settings-ConnectionAddress":
+		return validateADBAddress(params, explicit)
+	case strings.HasPrefix(taskType, "Settings-Stage"):
+		if params != "" && !stagePattern.MatchString(params) {
+			return errors.New("关卡名格式无效，应如 1-7、CE-6、S3-7 或 Annihilation")
+		}
+	}
+	return nil
+}
+
+func validateADBAddress(addr string, anyHost bool) error {
+	if serialPattern.MatchString(addr) {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return errors.New("ADB 地址格式无效，应如 127.0.0.1:5555 或 emulator-5554")
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return errors.New("ADB 端口无效")
+	}
+	if anyHost || host == "localhost" {
+		return nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast()) {
+		return errors.New("只允许本机或局域网 ADB 地址，其他地址须由管理员加入允许列表")
+	}
+	return nil
+}
+
+func (s *Settings) save() {
+	data, _ := json.MarshalIndent(s.rules, "", "  ")
+	_ = os.WriteFile(s.file, data, 0644)
+}
+
+func (s *Settings) load() {
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(data, &s.rules)
+}
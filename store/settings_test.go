@@ -0,0 +1,114 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSettingsCheck(t *testing.T) {
+	s := NewSettings(t.TempDir())
+	for _, c := range []struct {
+		device, taskType, params string
+		ok                       bool
+	}{
+		{"", "LinkStart", "", true},
+		{"", "LinkStart", strings.Repeat("a", maxParamsLen), true},
+		{"", "LinkStart", strings.Repeat("a", maxParamsLen+1), false},
+		{"", "LinkStart", "a\nb", false},
+		{"", "Settings-Penguin", "x", false},
+		{"", "Settings-Stage1", "1-7", true},
+		{"", "Settings-Stage1", "H7-4", true},
+		{"", "Settings-Stage1", "CE-6", true},
+		{"", "Settings-Stage1", "PR-A-1", true},
+		{"", "Settings-Stage1", "Annihilation", true},
+		{"", "Settings-Stage1", "", true},
+		{"", "Settings-Stage1", "1-7; rm -rf", false},
+		{"", "Settings-ConnectionAddress", "127.0.0.1:5555", true},
+		{"", "Settings-ConnectionAddress", "192.168.1.10:5555", true},
+		{"", "Settings-ConnectionAddress", "localhost:16384", true},
+		{"", "Settings-ConnectionAddress", "[::1]:5555", true},
+		{"", "Settings-ConnectionAddress", "emulator-5554", true},
+		{"", "Settings-ConnectionAddress", "8.8.8.8:5555", false},
+		{"", "Settings-ConnectionAddress", "example.com:5555", false},
+		{"", "Settings-ConnectionAddress", "127.0.0.1:0", false},
+		{"", "Settings-ConnectionAddress", "127.0.0.1", false},
+	} {
+		if err := s.Check(c.device, c.taskType, c.params); (err == nil) != c.ok {
+			t.Errorf("Check(%q, %q, %q) = %v", c.device, c.taskType, c.params, err)
+		}
+	}
+}
+
+func TestSettingsValues(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSettings(dir)
+	rules := SettingsRules{
+		Types:  []string{"Settings-*"},
+		Values: map[string][]string{"Settings-ConnectionAddress": {"10.0.0.5:5555", "203.0.113.7:5555"}},
+		Devices: map[string]map[string][]string{
+			"dev1": {"Settings-Stage1": {"1-7", "CE-6"}},
+		},
+	}
+	if err := s.SetRules(rules); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		device, taskType, params string
+		want                     error
+	}{
+		// 管理员列出的公网地址可以下发，未列出的局域网地址不行
+		{"", "Settings-ConnectionAddress", "203.0.113.7:5555", nil},
+		{"", "Settings-ConnectionAddress", "192.168.1.10:5555", ErrSettingsValue},
+		// 按设备限定了取值的类型必须指定设备
+		{"", "Settings-Stage1", "1-7", ErrSettingsDevice},
+		{"dev1", "Settings-Stage1", "CE-6", nil},
+		{"dev1", "Settings-Stage1", "S3-7", ErrSettingsValue},
+		{"dev2", "Settings-Stage1", "S3-7", nil},
+		{"", "Settings-Penguin", "anything", nil},
+	} {
+		if err := s.Check(c.device, c.taskType, c.params); !errors.Is(err, c.want) {
+			t.Errorf("Check(%q, %q, %q) = %v，期望 %v", c.device, c.taskType, c.params, err, c.want)
+		}
+	}
+
+	// 没有限定取值的设备仍做格式校验
+	if err := s.Check("dev2", "Settings-Stage1", "bad stage"); err == nil {
+		t.Error("未限定取值的设备下发了格式无效的关卡")
+	}
+
+	// 规则保存在数据目录中
+	if got := NewSettings(dir).Rules(); len(got.Types) != 1 || len(got.Devices["dev1"]["Settings-Stage1"]) != 2 {
+		t.Errorf("重新加载后规则为 %+v", got)
+	}
+}
+
+func TestSetRulesValidates(t *testing.T) {
+	dir := t.TempDir()
+	s := NewSettings(dir)
+	for _, rules := range []SettingsRules{
+		{Values: map[string][]string{"Settings-Stage1": {"not a stage"}}},
+		{Values: map[string][]string{"Settings-ConnectionAddress": {"host-without-port"}}},
+		{Devices: map[string]map[string][]string{"dev1": {"Settings-Stage1": {"1-7", "x y"}}}},
+	} {
+		if err := s.SetRules(rules); err == nil {
+			t.Errorf("SetRules(%+v) 未报错", rules)
+		}
+	}
+	// 失败时保留原规则
+	if got := NewSettings(dir).Rules(); len(got.Types) != len(defaultSettingsRules.Types) || s.Check("", "Settings-Stage1", "1-7") != nil {
+		t.Errorf("SetRules 失败后规则为 %+v", got)
+	}
+
+	// Types 为空表示不允许任何 Settings 任务
+	if err := s.SetRules(SettingsRules{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Check("", "Settings-Stage1", "1-7"); !errors.Is(err, ErrSettingsType) {
+		t.Errorf("清空类型后 Check 返回 %v", err)
+	}
+	if err := s.Check("", "LinkStart", ""); err != nil {
+		t.Errorf("非 Settings 任务 Check 返回 %v", err)
+	}
+}
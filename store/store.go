@@ -2,41 +2,59 @@ package store
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"ArknightsMaaRemoter/api"
 )
 
-type Status string
+// 任务相关的类型定义在 api 包中，与客户端共用
+type (
+	Status = api.Status
+	Task   = api.Task
+	Device = api.Device
+)
 
 const (
-	StatusPending Status = "PENDING"
-	StatusSuccess Status = "SUCCESS"
-	StatusFailed  Status = "FAILED"
+	StatusPending          = api.StatusPending
+	StatusSuccess          = api.StatusSuccess
+	StatusFailed           = api.StatusFailed
+	StatusAwaitingApproval = api.StatusAwaitingApproval
+	StatusRejected         = api.StatusRejected
 )
 
-type Task struct {
-	ID        string     `json:"id"`
-	Type      string     `json:"type"`
-	Params    string     `json:"params,omitempty"`
-	Status    Status     `json:"status"`
-	Payload   string     `json:"payload,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
-	DoneAt    *time.Time `json:"done_at,omitempty"`
-}
+var (
+	ErrTaskNotFound    = errors.New("任务不存在")
+	ErrTaskNotAssigned = errors.New("任务未下发给该设备")
+	ErrTaskDone        = errors.New("任务已经结束")
+	ErrNotAwaiting     = errors.New("任务不在待确认状态")
+	ErrApproveTooSoon  = errors.New("不能立即确认自己提交的任务，请稍后再试或请他人确认")
+	ErrNotQueued       = errors.New("只能调整尚未下发的待执行任务")
+)
 
 type Store struct {
-	mu    sync.RWMutex
-	tasks []*Task
-	file  string
+	mu      sync.RWMutex
+	tasks   []*Task
+	devices map[string]*Device
+	file    string
+	// waiters 保存等待任务结束的 channel，任务结束时关闭
+	waiters map[string]chan struct{}
 }
 
-func New() *Store {
+// New 从 dir 目录加载任务，dir 为空时使用当前目录
+func New(dir string) *Store {
 	s := &Store{
-		tasks: make([]*Task, 0),
-		file:  "tasks.json",
+		tasks:   make([]*Task, 0),
+		devices: make(map[string]*Device),
+		file:    filepath.Join(dir, "tasks.json"),
+		waiters: make(map[string]chan struct{}),
 	}
 	s.load()
 	return s
@@ -44,47 +62,321 @@ func New() *Store {
 
 // Add 将新任务加入队列
 func (s *Store) Add(taskType, params string) *Task {
+	return s.AddFor("", taskType, params)
+}
+
+// AddFor 将新任务加入队列，device 非空时只下发给该设备
+func (s *Store) AddFor(device, taskType, params string) *Task {
+	return s.AddTask(&Task{Type: taskType, Params: params, Status: StatusPending, Device: device})
+}
+
+// AddTask 加入一个由调用方填好类型、状态等字段的任务，ID 和创建时间由这里填写。
+// 状态为 StatusAwaitingApproval 的任务经 Approve 确认后才会下发。
+func (s *Store) AddTask(t *Task) *Task {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	t := &Task{
-		ID:        uuid.NewString(),
-		Type:      taskType,
-		Params:    params,
-		Status:    StatusPending,
-		CreatedAt: time.Now(),
-	}
+	t.ID = uuid.NewString()
+	t.CreatedAt = time.Now()
+	t.Order = s.nextOrder()
 	s.tasks = append(s.tasks, t)
 	s.save()
 	return t
 }
 
-// Pending 返回所有待执行任务。
-// MAA 自身会按 ID 去重，所以重复返回安全。
-func (s *Store) Pending() []*Task {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+// AddBatch 把一批任务按顺序加入队列，全部加入后只写一次文件。
+// 同一批任务的创建时间依次递增 1ns，按创建时间排序时也保持提交顺序。
+func (s *Store) AddBatch(tasks []*Task) []*Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now, next := time.Now(), s.nextOrder()
+	for i, t := range tasks {
+		t.ID = uuid.NewString()
+		t.CreatedAt = now.Add(time.Duration(i))
+		t.Order = next + int64(i)
+		s.tasks = append(s.tasks, t)
+	}
+	s.save()
+	return tasks
+}
+
+// Approve 确认待确认的任务，使其进入待执行队列。提交者本人确认时，
+// 须距提交至少 selfDelay；selfDelay 为 0 表示不允许本人确认。
+func (s *Store) Approve(id, approver string, selfDelay time.Duration) (*Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.find(id)
+	if t == nil {
+		return nil, ErrTaskNotFound
+	}
+	if t.Status != StatusAwaitingApproval {
+		return nil, ErrNotAwaiting
+	}
+	if approver == t.SubmittedBy && (selfDelay <= 0 || time.Since(t.CreatedAt) < selfDelay) {
+		return nil, ErrApproveTooSoon
+	}
+	t.Status = StatusPending
+	t.ApprovedBy = approver
+	s.save()
+	return t, nil
+}
+
+// Reject 拒绝待确认的任务
+func (s *Store) Reject(id, by string) (*Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.find(id)
+	if t == nil {
+		return nil, ErrTaskNotFound
+	}
+	if t.Status != StatusAwaitingApproval {
+		return nil, ErrNotAwaiting
+	}
+	now := time.Now()
+	t.Status = StatusRejected
+	t.ApprovedBy = by
+	t.DoneAt = &now
+	s.save()
+	s.notify(id)
+	return t, nil
+}
+
+func (s *Store) find(id string) *Task {
+	for _, t := range s.tasks {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+// Pending 返回 device 可执行的待执行任务：指定给该设备的任务和未指定设备的任务。
+// 未指定设备的任务与 MAA 协议的原有行为一致，下发给每台轮询的设备，由最先汇报的设备完成；
+// 指定了设备的任务在首次下发时记录领取它的用户，之后只接受该用户的汇报。
+// device 为空时不记录下发。MAA 自身会按 ID 去重，所以重复返回安全。
+func (s *Store) Pending(user, device string) []*Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
 	var result []*Task
+	// 已下发的任务已在 MAA 的执行队列中，按当初下发的顺序放在最前面
 	for _, t := range s.tasks {
-		if t.Status == StatusPending {
+		if t.Status == StatusPending && t.DispatchedAt != nil && (t.Device == "" || t.Device == device) {
 			result = append(result, t)
 		}
 	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].DispatchedAt.Before(*result[j].DispatchedAt) })
+	changed := false
+	for _, t := range s.queue() {
+		if t.Device != "" && t.Device != device {
+			continue
+		}
+		if device != "" {
+			now := time.Now()
+			t.DispatchedAt = &now
+			if t.Device != "" {
+				t.User = user
+			}
+			changed = true
+		}
+		result = append(result, t)
+	}
+	if changed {
+		s.save()
+	}
 	return result
 }
 
-// Complete 标记任务完成
-func (s *Store) Complete(id, status, payload string) bool {
+// queue 返回尚未下发的待执行任务，按下发顺序排列：优先级高的在前，同优先级按 Order。
+// 调用方须持有锁。
+func (s *Store) queue() []*Task {
+	q := make([]*Task, 0)
+	for _, t := range s.tasks {
+		if t.Status == StatusPending && t.DispatchedAt == nil {
+			q = append(q, t)
+		}
+	}
+	sort.SliceStable(q, func(i, j int) bool {
+		if q[i].Priority != q[j].Priority {
+			return q[i].Priority > q[j].Priority
+		}
+		return q[i].Order < q[j].Order
+	})
+	return q
+}
+
+// nextOrder 返回排在所有任务之后的 Order。调用方须持有锁。
+func (s *Store) nextOrder() int64 {
+	var max int64
+	for _, t := range s.tasks {
+		if t.Order > max {
+			max = t.Order
+		}
+	}
+	return max + 1
+}
+
+// Queue 返回尚未下发给 MAA 的任务，顺序即 Pending 下发它们的顺序
+func (s *Store) Queue() []*Task {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.queue()
+}
+
+// Move 调整尚未下发任务在队列中的位置，返回调整后的队列。
+// 只相对会下发给同一设备的任务移动（两者之一未指定设备，或指定了同一设备），
+// 越过只下发给其他设备的任务没有意义。up、down 与这样的相邻任务交换，front 移到它们之前；
+// 越过优先级不同的任务时改用对方的优先级。已在最前（最后）时不做改动。
+func (s *Store) Move(id string, to api.Move) ([]*Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := s.queue()
+	i := -1
+	for j, t := range q {
+		if t.ID == id {
+			i = j
+		}
+	}
+	if i < 0 {
+		if s.find(id) == nil {
+			return nil, ErrTaskNotFound
+		}
+		return nil, ErrNotQueued
+	}
+
+	t, anchor, before := q[i], -1, true
+	switch to {
+	case api.MoveUp:
+		for j := i - 1; j >= 0 && anchor < 0; j-- {
+			if sharesDevice(t, q[j]) {
+				anchor = j
+			}
+		}
+	case api.MoveDown:
+		for j := i + 1; j < len(q) && anchor < 0; j++ {
+			if sharesDevice(t, q[j]) {
+				anchor, before = j, false
+			}
+		}
+	case api.MoveFront:
+		for j := 0; j < i && anchor < 0; j++ {
+			if sharesDevice(t, q[j]) {
+				anchor = j
+			}
+		}
+	default:
+		return nil, fmt.Errorf("未知的方向 %q", to)
+	}
+	if anchor < 0 {
+		return q, nil
+	}
+
+	// 把 t 移到 anchor 紧前（紧后），并采用 anchor 的优先级，使二者在队列中相邻
+	t.Priority = q[anchor].Priority
+	moved := make([]*Task, 0, len(q))
+	for j, x := range q {
+		switch {
+		case x == t:
+		case j == anchor && before:
+			moved = append(moved, t, x)
+		case j == anchor:
+			moved = append(moved, x, t)
+		default:
+			moved = append(moved, x)
+		}
+	}
+	// 按新的顺序重新分配这些任务原有的 Order，其他任务的 Order 不变
+	orders := make([]int64, len(q))
+	for j, x := range q {
+		orders[j] = x.Order
+	}
+	sort.Slice(orders, func(a, b int) bool { return orders[a] < orders[b] })
+	for j, x := range moved {
+		x.Order = orders[j]
+	}
+	s.save()
+	return moved, nil
+}
+
+// sharesDevice 判断两个任务是否会下发给同一台设备
+func sharesDevice(a, b *Task) bool {
+	return a.Device == "" || b.Device == "" || a.Device == b.Device
+}
+
+// Complete 标记任务完成。只接受领取了该任务的设备和用户的汇报，
+// 未下发过的任务或来自其他设备的汇报返回 ErrTaskNotAssigned，已结束的任务返回 ErrTaskDone。
+// 未指定设备的任务记录为由汇报的设备完成，之后其他设备的汇报不再生效。
+func (s *Store) Complete(id, user, device, status, payload string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, t := range s.tasks {
+		if t.ID != id {
+			continue
+		}
+		if t.Done() {
+			return ErrTaskDone
+		}
+		if !t.AssignedTo(user, device) {
+			return ErrTaskNotAssigned
+		}
+		if t.Device == "" {
+			t.Device, t.User = device, user
+		}
+		t.Status = Status(status)
+		t.Payload = payload
+		now := time.Now()
+		t.DoneAt = &now
+		s.save()
+		s.notify(id)
+		return nil
+	}
+	return ErrTaskNotFound
+}
+
+// Watch 返回任务和一个在任务结束（成功、失败或被拒绝）时关闭的 channel，
+// 任务已经结束时 channel 已关闭，任务不存在时返回 nil。
+func (s *Store) Watch(id string) (*Task, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.find(id)
+	if t == nil {
+		return nil, nil
+	}
+	if t.Done() {
+		ch := make(chan struct{})
+		close(ch)
+		return t, ch
+	}
+	ch, ok := s.waiters[id]
+	if !ok {
+		ch = make(chan struct{})
+		s.waiters[id] = ch
+	}
+	return t, ch
+}
+
+// notify 唤醒等待该任务的请求，调用方须持有写锁
+func (s *Store) notify(id string) {
+	if ch, ok := s.waiters[id]; ok {
+		close(ch)
+		delete(s.waiters, id)
+	}
+}
+
+// SetScreen 记录截图任务识别出的画面标签
+func (s *Store) SetScreen(id, screen string) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	for _, t := range s.tasks {
 		if t.ID == id {
-			t.Status = Status(status)
-			t.Payload = payload
-			now := time.Now()
-			t.DoneAt = &now
+			t.Screen = screen
 			s.save()
 			return true
 		}
@@ -92,17 +384,36 @@ func (s *Store) Complete(id, status, payload string) bool {
 	return false
 }
 
+// Seen 记录设备的最近一次轮询（仅保存在内存中）
+func (s *Store) Seen(user, device string) {
+	if device == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.devices[device] = &Device{ID: device, User: user, LastSeen: time.Now()}
+}
+
+// Devices 返回本次启动以来轮询过的设备（最近活跃的在前）
+func (s *Store) Devices() []Device {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]Device, 0, len(s.devices))
+	for _, d := range s.devices {
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].LastSeen.After(result[j].LastSeen) })
+	return result
+}
+
 // Get 按 ID 查找任务
 func (s *Store) Get(id string) *Task {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	for _, t := range s.tasks {
-		if t.ID == id {
-			return t
-		}
-	}
-	return nil
+	return s.find(id)
 }
 
 // All 返回所有任务（最新的在前）
@@ -117,6 +428,169 @@ func (s *Store) All() []*Task {
 	return result
 }
 
+// TaskFilter 是查询任务的条件，零值表示不限
+type TaskFilter struct {
+	IDPrefix string
+	Statuses []Status
+	Types    []string // 末尾 * 表示前缀匹配
+	Device   string
+	Screen   string
+	Since    time.Time // 按创建时间
+	Until    time.Time
+	Allow    func(*Task) bool // 额外条件，如 API 密钥的设备限制
+}
+
+func (f *TaskFilter) match(t *Task) bool {
+	if len(f.Statuses) > 0 && !containsStatus(f.Statuses, t.Status) {
+		return false
+	}
+	if len(f.Types) > 0 {
+		ok := false
+		for _, pattern := range f.Types {
+			if MatchType(pattern, t.Type) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	return strings.HasPrefix(t.ID, f.IDPrefix) &&
+		(f.Device == "" || t.Device == f.Device) &&
+		(f.Screen == "" || t.Screen == f.Screen) &&
+		(f.Since.IsZero() || !t.CreatedAt.Before(f.Since)) &&
+		(f.Until.IsZero() || t.CreatedAt.Before(f.Until)) &&
+		(f.Allow == nil || f.Allow(t))
+}
+
+func containsStatus(list []Status, s Status) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// TaskCursor 是分页位置，即上一页最后一个任务的创建时间和 ID
+type TaskCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// taskBefore 按创建时间、再按 ID 比较，保证排序稳定，分页不会遗漏或重复
+func taskBefore(a *Task, createdAt time.Time, id string) bool {
+	return a.CreatedAt.Before(createdAt) || (a.CreatedAt.Equal(createdAt) && a.ID < id)
+}
+
+// Query 返回符合条件的任务，按创建时间排序，ascending 为假时最新的在前。
+// after 非空时从该位置之后开始；limit 为 0 表示不限。more 表示之后还有符合条件的任务。
+func (s *Store) Query(f TaskFilter, ascending bool, after *TaskCursor, limit int) (tasks []*Task, more bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	matched := make([]*Task, 0)
+	for _, t := range s.tasks {
+		if f.match(t) {
+			matched = append(matched, t)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if ascending {
+			return taskBefore(matched[i], matched[j].CreatedAt, matched[j].ID)
+		}
+		return taskBefore(matched[j], matched[i].CreatedAt, matched[i].ID)
+	})
+
+	result := make([]*Task, 0)
+	for _, t := range matched {
+		if after != nil {
+			// 升序时跳过不晚于游标的任务，降序时跳过不早于游标的任务
+			past := taskBefore(t, after.CreatedAt, after.ID)
+			if ascending == past || (t.CreatedAt.Equal(after.CreatedAt) && t.ID == after.ID) {
+				continue
+			}
+		}
+		if limit > 0 && len(result) == limit {
+			return result, true
+		}
+		result = append(result, t)
+	}
+	return result, false
+}
+
+// Import 合并导入的任务，已存在或不符合 ValidID 的 ID 会跳过，返回实际导入的数量。
+// 导入包可能来自别的机器或被改动过，待执行和待确认的任务一律改为待确认，
+// 须由有权限的用户确认后才会下发，不会绕过权限和 Settings 规则直接进入队列。
+// 导入的任务排在已有任务之后。合并后按创建时间排序，只写一次文件；队列顺序由 Order 决定，不受排序影响。
+func (s *Store) Import(tasks []*Task) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 导入的任务之间保持原来的先后；旧版本导出的任务没有 Order，按创建时间
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Order != tasks[j].Order {
+			return tasks[i].Order < tasks[j].Order
+		}
+		return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+	})
+	added, next := 0, s.nextOrder()
+	for _, t := range tasks {
+		if !ValidID(t.ID) || s.find(t.ID) != nil {
+			continue
+		}
+		t.Order = next + int64(added)
+		if t.Status == StatusPending || t.Status == StatusAwaitingApproval {
+			t.Status = StatusAwaitingApproval
+			t.User, t.ApprovedBy, t.DispatchedAt, t.DoneAt = "", "", nil, nil
+		}
+		s.tasks = append(s.tasks, t)
+		added++
+	}
+	if added > 0 {
+		sort.SliceStable(s.tasks, func(i, j int) bool { return s.tasks[i].CreatedAt.Before(s.tasks[j].CreatedAt) })
+		s.save()
+	}
+	return added
+}
+
+// ValidID 判断导入的任务 ID 是否可用：1-64 个字母、数字、- 或 _。
+// ID 会出现在截图文件名和 URL 中，不能含路径分隔符。
+func ValidID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Prune 删除 before 之前已结束的任务，返回被删除的任务。
+// 待执行和待确认的任务不论多旧都会保留。
+func (s *Store) Prune(before time.Time) []*Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var removed []*Task
+	kept := s.tasks[:0]
+	for _, t := range s.tasks {
+		if t.FinishedBefore(before) {
+			removed = append(removed, t)
+		} else {
+			kept = append(kept, t)
+		}
+	}
+	s.tasks = kept
+	if len(removed) > 0 {
+		s.save()
+	}
+	return removed
+}
+
 func (s *Store) save() {
 	data, _ := json.MarshalIndent(s.tasks, "", "  ")
 	_ = os.WriteFile(s.file, data, 0644)
@@ -128,4 +602,10 @@ func (s *Store) load() {
 		return
 	}
 	_ = json.Unmarshal(data, &s.tasks)
+	// 旧版本的数据没有 Order，按在文件中的位置补上
+	for i, t := range s.tasks {
+		if t.Order == 0 {
+			t.Order = int64(i + 1)
+		}
+	}
 }
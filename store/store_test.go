@@ -0,0 +1,359 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"ArknightsMaaRemoter/api"
+)
+
+func types(tasks []*Task) []string {
+	result := make([]string, len(tasks))
+	for i, t := range tasks {
+		result[i] = t.Type
+	}
+	return result
+}
+
+func TestPendingBroadcastsUnnamedTasks(t *testing.T) {
+	s := New(t.TempDir())
+	task := s.Add("LinkStart", "")
+
+	for _, dev := range []string{"dev1", "dev2", "dev1"} {
+		if got := s.Pending("u-"+dev, dev); len(got) != 1 || got[0].ID != task.ID {
+			t.Fatalf("%s 轮询得到 %v，期望未指定设备的任务", dev, types(got))
+		}
+	}
+	if task.Device != "" || task.DispatchedAt == nil {
+		t.Fatalf("下发后 device=%q dispatched_at=%v，期望不绑定设备但记录下发时间", task.Device, task.DispatchedAt)
+	}
+	if err := s.Complete(task.ID, "u-dev2", "dev2", "SUCCESS", ""); err != nil {
+		t.Fatalf("任一设备都可以汇报: %v", err)
+	}
+	if task.Device != "dev2" || task.User != "u-dev2" {
+		t.Errorf("完成后 device=%q user=%q，期望记录汇报的设备", task.Device, task.User)
+	}
+	if got := s.Pending("u-dev1", "dev1"); len(got) != 0 {
+		t.Errorf("完成的任务仍被下发: %v", types(got))
+	}
+}
+
+func TestPendingNamedTaskOnlyForDevice(t *testing.T) {
+	s := New(t.TempDir())
+	task := s.AddFor("dev1", "LinkStart", "")
+
+	if got := s.Pending("u2", "dev2"); len(got) != 0 {
+		t.Fatalf("指定给 dev1 的任务下发给了 dev2: %v", types(got))
+	}
+	if got := s.Pending("", ""); len(got) != 0 {
+		t.Fatalf("指定设备的任务下发给了未提供设备的请求: %v", types(got))
+	}
+	if err := s.Complete(task.ID, "u1", "dev1", "SUCCESS", ""); !errors.Is(err, ErrTaskNotAssigned) {
+		t.Fatalf("未下发的任务接受了汇报: %v", err)
+	}
+	if got := s.Pending("u1", "dev1"); len(got) != 1 {
+		t.Fatalf("dev1 轮询得到 %v", types(got))
+	}
+	for _, from := range [][2]string{{"u2", "dev2"}, {"u2", "dev1"}, {"u1", ""}} {
+		if err := s.Complete(task.ID, from[0], from[1], "SUCCESS", ""); !errors.Is(err, ErrTaskNotAssigned) {
+			t.Errorf("接受了来自 %s/%s 的汇报: %v", from[0], from[1], err)
+		}
+	}
+	if err := s.Complete(task.ID, "u1", "dev1", "SUCCESS", ""); err != nil {
+		t.Errorf("领取任务的设备汇报失败: %v", err)
+	}
+}
+
+func TestCompleteRejectsFinishedTask(t *testing.T) {
+	s := New(t.TempDir())
+	task := s.AddFor("dev1", "LinkStart", "")
+	s.Pending("u1", "dev1")
+	if err := s.Complete(task.ID, "u1", "dev1", "FAILED", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Complete(task.ID, "u1", "dev1", "SUCCESS", "second"); !errors.Is(err, ErrTaskDone) {
+		t.Errorf("重复汇报返回 %v，期望 ErrTaskDone", err)
+	}
+	if task.Status != StatusFailed || task.Payload != "first" {
+		t.Errorf("重复汇报覆盖了结果: %s %q", task.Status, task.Payload)
+	}
+}
+
+func TestImportRejectsBadIDs(t *testing.T) {
+	s := New(t.TempDir())
+	now := time.Now()
+	var tasks []*Task
+	for _, id := range []string{"", "abc", "../../x", "/../../x", `a\b`, "a.png", strings.Repeat("a", 65)} {
+		tasks = append(tasks, &Task{ID: id, Type: "LinkStart", Status: StatusSuccess, CreatedAt: now})
+	}
+	if n := s.Import(tasks); n != 1 || s.Get("abc") == nil {
+		t.Errorf("导入了 %d 个任务，期望只导入 abc", n)
+	}
+}
+
+func TestImportOpenTasksAwaitApproval(t *testing.T) {
+	s := New(t.TempDir())
+	now := time.Now()
+	tasks := []*Task{
+		{ID: "pending", Type: "LinkStart", Status: StatusPending, CreatedAt: now},
+		{ID: "dispatched", Type: "LinkStart", Status: StatusPending, User: "u1", DispatchedAt: &now, CreatedAt: now},
+		{ID: "approved", Type: "Settings-Stage1", Status: StatusPending, ApprovedBy: "bob", CreatedAt: now},
+		{ID: "done", Type: "LinkStart", Status: StatusSuccess, User: "u1", DispatchedAt: &now, DoneAt: &now, CreatedAt: now},
+		{ID: "stale", Type: "LinkStart", Status: StatusPending, DoneAt: &now, CreatedAt: now},
+	}
+	if n := s.Import(tasks); n != len(tasks) {
+		t.Fatalf("导入了 %d 个任务", n)
+	}
+	for _, id := range []string{"pending", "dispatched", "approved", "stale"} {
+		got := s.Get(id)
+		if got.Status != StatusAwaitingApproval || got.User != "" || got.ApprovedBy != "" || got.DispatchedAt != nil || got.DoneAt != nil {
+			t.Errorf("%s: 导入后为 %+v，期望待确认且未下发", id, got)
+		}
+	}
+	if got := s.Get("done"); got.Status != StatusSuccess || got.User != "u1" {
+		t.Errorf("已结束的任务被修改: %+v", got)
+	}
+	if pending := s.Pending("u1", "dev1"); len(pending) != 0 {
+		t.Errorf("导入的任务未经确认就被下发: %v", pending)
+	}
+}
+
+func queueTypes(s *Store) string {
+	return strings.Join(types(s.Queue()), ",")
+}
+
+func TestMove(t *testing.T) {
+	s := New(t.TempDir())
+	a := s.Add("A", "")
+	b := s.Add("B", "")
+	c := s.Add("C", "")
+
+	for _, step := range []struct {
+		task *Task
+		to   api.Move
+		want string
+	}{
+		{c, api.MoveUp, "A,C,B"},
+		{a, api.MoveDown, "C,A,B"},
+		{b, api.MoveFront, "B,C,A"},
+		{b, api.MoveUp, "B,C,A"}, // 已在最前
+		{a, api.MoveDown, "B,C,A"},
+	} {
+		q, err := s.Move(step.task.ID, step.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(types(q), ","); got != step.want {
+			t.Errorf("%s %s 后队列为 %s，期望 %s", step.task.Type, step.to, got, step.want)
+		}
+	}
+	// 顺序保存在 Order 中，重新加载后不变
+	if got := queueTypes(New(filepath.Dir(s.file))); got != "B,C,A" {
+		t.Errorf("重新加载后队列为 %s", got)
+	}
+	if got := types(s.Pending("u1", "dev1")); strings.Join(got, ",") != "B,C,A" {
+		t.Errorf("下发顺序为 %v", got)
+	}
+	if _, err := s.Move(a.ID, api.MoveUp); !errors.Is(err, ErrNotQueued) {
+		t.Errorf("移动已下发的任务返回 %v，期望 ErrNotQueued", err)
+	}
+}
+
+func TestMovePriority(t *testing.T) {
+	s := New(t.TempDir())
+	low := s.AddTask(&Task{Type: "Low", Status: StatusPending})
+	s.AddTask(&Task{Type: "High", Status: StatusPending, Priority: 5})
+	s.AddTask(&Task{Type: "High2", Status: StatusPending, Priority: 5})
+	if got := queueTypes(s); got != "High,High2,Low" {
+		t.Fatalf("队列为 %s，期望优先级高的在前", got)
+	}
+
+	// 越过优先级更高的任务时采用对方的优先级
+	if _, err := s.Move(low.ID, api.MoveUp); err != nil {
+		t.Fatal(err)
+	}
+	if got := queueTypes(s); got != "High,Low,High2" || low.Priority != 5 {
+		t.Errorf("上移后队列为 %s，优先级 %d", got, low.Priority)
+	}
+	// 新提交的同优先级任务排在后面
+	s.AddTask(&Task{Type: "New", Status: StatusPending, Priority: 5})
+	if got := queueTypes(s); got != "High,Low,High2,New" {
+		t.Errorf("队列为 %s", got)
+	}
+	if _, err := s.Move(low.ID, api.MoveDown); err != nil {
+		t.Fatal(err)
+	}
+	if got := queueTypes(s); got != "High,High2,Low,New" || low.Priority != 5 {
+		t.Errorf("下移后队列为 %s，优先级 %d", got, low.Priority)
+	}
+}
+
+func TestMoveSameDevice(t *testing.T) {
+	s := New(t.TempDir())
+	a1 := s.AddFor("dev1", "A1", "")
+	s.AddFor("dev2", "B1", "")
+	b2 := s.AddFor("dev2", "B2", "")
+	a2 := s.AddFor("dev1", "A2", "")
+	s.Add("All", "")
+
+	// dev2 的任务不会下发给 dev1，上移时越过它们
+	if _, err := s.Move(a2.ID, api.MoveUp); err != nil {
+		t.Fatal(err)
+	}
+	if got := queueTypes(s); got != "A2,A1,B1,B2,All" {
+		t.Errorf("上移后队列为 %s", got)
+	}
+	// 未指定设备的任务也会下发给 dev1，下移时越过 dev2 的任务与它交换
+	if _, err := s.Move(a1.ID, api.MoveDown); err != nil {
+		t.Fatal(err)
+	}
+	if got := queueTypes(s); got != "A2,B1,B2,All,A1" {
+		t.Errorf("下移后队列为 %s", got)
+	}
+	// 移到最前也只是排到同一设备的任务之前
+	if _, err := s.Move(b2.ID, api.MoveFront); err != nil {
+		t.Fatal(err)
+	}
+	if got := queueTypes(s); got != "A2,B2,B1,All,A1" {
+		t.Errorf("移到最前后队列为 %s", got)
+	}
+}
+
+func TestImportKeepsOrder(t *testing.T) {
+	src := New(t.TempDir())
+	a := src.Add("A", "")
+	src.Add("B", "")
+	if _, err := src.Move(a.ID, api.MoveDown); err != nil {
+		t.Fatal(err)
+	}
+	var tasks []*Task
+	for _, task := range src.All() {
+		copied := *task
+		tasks = append(tasks, &copied)
+	}
+
+	s := New(t.TempDir())
+	s.Add("Local", "")
+	s.Import(tasks)
+	for _, task := range tasks {
+		if _, err := s.Approve(task.ID, "bob", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := queueTypes(s); got != "Local,B,A" {
+		t.Errorf("导入后队列为 %s，期望保持移动后的顺序并排在已有任务之后", got)
+	}
+}
+
+func ids(tasks []*Task) string {
+	result := make([]string, len(tasks))
+	for i, t := range tasks {
+		result[i] = t.ID
+	}
+	return strings.Join(result, ",")
+}
+
+// queryStore 导入 a-f 六个已结束的任务，c 和 d 的创建时间相同
+func queryStore(t *testing.T) (*Store, time.Time) {
+	s := New(t.TempDir())
+	base := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	at := func(m int) time.Time { return base.Add(time.Duration(m) * time.Minute) }
+	s.Import([]*Task{
+		{ID: "a", Type: "LinkStart", Device: "dev1", Status: StatusSuccess, CreatedAt: at(0)},
+		{ID: "b", Type: "LinkStart-Combat", Device: "dev2", Status: StatusFailed, CreatedAt: at(1)},
+		{ID: "c", Type: "CaptureImageNow", Device: "dev1", Status: StatusSuccess, Screen: "main", CreatedAt: at(2)},
+		{ID: "d", Type: "CaptureImageNow", Device: "dev2", Status: StatusSuccess, Screen: "main", CreatedAt: at(2)},
+		{ID: "e", Type: "LinkStart-Mall", Device: "dev1", Status: StatusRejected, CreatedAt: at(3)},
+		{ID: "f", Type: "HeartBeat", Device: "dev1", Status: StatusSuccess, CreatedAt: at(4)},
+	})
+	return s, base
+}
+
+func TestQuery(t *testing.T) {
+	s, base := queryStore(t)
+	for _, c := range []struct {
+		name   string
+		filter TaskFilter
+		asc    bool
+		want   string
+	}{
+		{"全部，最新的在前", TaskFilter{}, false, "f,e,d,c,b,a"},
+		{"全部，最早的在前", TaskFilter{}, true, "a,b,c,d,e,f"},
+		{"状态", TaskFilter{Statuses: []Status{StatusFailed, StatusRejected}}, false, "e,b"},
+		{"类型前缀", TaskFilter{Types: []string{"LinkStart*"}}, true, "a,b,e"},
+		{"多个类型", TaskFilter{Types: []string{"LinkStart", "HeartBeat"}}, true, "a,f"},
+		{"设备", TaskFilter{Device: "dev2"}, true, "b,d"},
+		{"画面", TaskFilter{Screen: "main"}, true, "c,d"},
+		{"ID 前缀", TaskFilter{IDPrefix: "c"}, true, "c"},
+		{"时间范围", TaskFilter{Since: base.Add(time.Minute), Until: base.Add(3 * time.Minute)}, true, "b,c,d"},
+		{"额外条件", TaskFilter{Allow: func(t *Task) bool { return t.Device == "dev1" }}, true, "a,c,e,f"},
+		{"组合", TaskFilter{Device: "dev1", Statuses: []Status{StatusSuccess}, Types: []string{"LinkStart*", "CaptureImage*"}}, true, "a,c"},
+	} {
+		tasks, more := s.Query(c.filter, c.asc, nil, 0)
+		if got := ids(tasks); got != c.want || more {
+			t.Errorf("%s: %s（more=%v），期望 %s", c.name, got, more, c.want)
+		}
+	}
+}
+
+func TestQueryPaging(t *testing.T) {
+	s, _ := queryStore(t)
+	for _, asc := range []bool{false, true} {
+		all, _ := s.Query(TaskFilter{}, asc, nil, 0)
+		var paged []*Task
+		var after *TaskCursor
+		for pages := 0; ; pages++ {
+			page, more := s.Query(TaskFilter{}, asc, after, 4)
+			paged = append(paged, page...)
+			if !more {
+				break
+			}
+			if len(page) != 4 || pages > 3 {
+				t.Fatalf("asc=%v: 第 %d 页有 %d 条", asc, pages+1, len(page))
+			}
+			last := page[len(page)-1]
+			after = &TaskCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+		}
+		if ids(paged) != ids(all) {
+			t.Errorf("asc=%v: 逐页得到 %s，期望 %s", asc, ids(paged), ids(all))
+		}
+	}
+
+	// 恰好取完时 more 为假
+	if tasks, more := s.Query(TaskFilter{}, true, nil, 6); len(tasks) != 6 || more {
+		t.Errorf("limit 等于总数时返回 %d 条，more=%v", len(tasks), more)
+	}
+}
+
+func TestQueryCursorStable(t *testing.T) {
+	s, base := queryStore(t)
+	page, _ := s.Query(TaskFilter{}, false, nil, 3)
+	if ids(page) != "f,e,d" {
+		t.Fatalf("第一页为 %s", ids(page))
+	}
+	cursor := &TaskCursor{CreatedAt: page[2].CreatedAt, ID: page[2].ID}
+
+	// 翻页之间新增的任务不会让第二页重复或遗漏：更新的任务和同一时间、ID 更大的任务在游标之前，
+	// 同一时间、ID 更小的任务在游标之后
+	s.Add("LinkStart", "")
+	s.Import([]*Task{
+		{ID: "cc", Type: "LinkStart", Status: StatusSuccess, CreatedAt: base.Add(2 * time.Minute)},
+		{ID: "dd", Type: "LinkStart", Status: StatusSuccess, CreatedAt: base.Add(2 * time.Minute)},
+	})
+	if page, more := s.Query(TaskFilter{}, false, cursor, 0); ids(page) != "cc,c,b,a" || more {
+		t.Errorf("第二页为 %s（more=%v），期望 cc,c,b,a", ids(page), more)
+	}
+
+	// 游标所在的任务已不存在时，按它的位置继续
+	gone := &TaskCursor{CreatedAt: base.Add(2 * time.Minute), ID: "ccc"}
+	if page, _ := s.Query(TaskFilter{}, false, gone, 0); ids(page) != "cc,c,b,a" {
+		t.Errorf("游标任务不存在时为 %s", ids(page))
+	}
+	// 升序时最后是翻页之间新增的任务
+	if page, _ := s.Query(TaskFilter{}, true, gone, 0); len(page) != 5 || !strings.HasPrefix(ids(page), "d,dd,e,f,") {
+		t.Errorf("升序时为 %s", ids(page))
+	}
+}
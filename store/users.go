@@ -0,0 +1,364 @@
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+var roleRank = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// Valid 判断是否为已知角色
+func (r Role) Valid() bool {
+	return roleRank[r] > 0
+}
+
+// AtLeast 判断 r 的权限是否不低于 min
+func (r Role) AtLeast(min Role) bool {
+	return roleRank[r] >= roleRank[min]
+}
+
+// MinPasswordLen 是用户密码的最短长度
+const MinPasswordLen = 8
+
+var (
+	ErrUserExists    = errors.New("用户已存在")
+	ErrUserNotFound  = errors.New("用户不存在")
+	ErrInvalidRole   = errors.New("角色必须是 viewer、operator 或 admin")
+	ErrLastAdmin     = errors.New("不能移除最后一个管理员")
+	ErrWeakPassword  = errors.New("密码至少 8 位")
+	ErrEmptyUsername = errors.New("用户名不能为空")
+)
+
+type User struct {
+	Username     string    `json:"username"`
+	PasswordHash string    `json:"password_hash,omitempty"`
+	Role         Role      `json:"role"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// defaultRoleTypes 是各角色默认可下发的任务类型，末尾的 * 表示前缀匹配。
+// 管理员不受限制；观察者只能查看，不能下发任务。
+var defaultRoleTypes = map[Role][]string{
+	RoleViewer:   {},
+	RoleOperator: {"LinkStart*", "CaptureImage*", "HeartBeat", "StopTask", "Toolbox-*"},
+}
+
+var (
+	dummyOnce sync.Once
+	dummy     []byte
+)
+
+func dummyHash() []byte {
+	dummyOnce.Do(func() {
+		dummy, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	})
+	return dummy
+}
+
+type usersData struct {
+	Users     []*User           `json:"users"`
+	RoleTypes map[Role][]string `json:"role_types"`
+}
+
+type Users struct {
+	mu   sync.RWMutex
+	data usersData
+	file string
+	// verified 缓存最近一次校验通过的密码摘要，避免控制面板每次轮询都跑 bcrypt
+	verified map[string][32]byte
+}
+
+func NewUsers(dir string) *Users {
+	u := &Users{
+		data:     usersData{Users: make([]*User, 0)},
+		file:     filepath.Join(dir, "users.json"),
+		verified: make(map[string][32]byte),
+	}
+	u.load()
+	if u.data.RoleTypes == nil {
+		u.data.RoleTypes = make(map[Role][]string)
+	}
+	for role, types := range defaultRoleTypes {
+		if _, ok := u.data.RoleTypes[role]; !ok {
+			u.data.RoleTypes[role] = types
+		}
+	}
+	return u
+}
+
+// Bootstrap 在没有任何用户时创建初始管理员。password 为空时随机生成。
+// 返回实际使用的密码，以及是否真的创建了用户。
+func (u *Users) Bootstrap(username, password string) (string, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if len(u.data.Users) > 0 {
+		return "", false
+	}
+	if password == "" {
+		b := make([]byte, 9)
+		_, _ = rand.Read(b)
+		password = hex.EncodeToString(b)
+	}
+	hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	u.data.Users = append(u.data.Users, &User{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         RoleAdmin,
+		CreatedAt:    time.Now(),
+	})
+	u.save()
+	return password, true
+}
+
+// Authenticate 校验用户名和密码，成功时返回用户副本（不含密码哈希）
+func (u *Users) Authenticate(username, password string) *User {
+	u.mu.RLock()
+	user := u.find(username)
+	var hash string
+	var cached [32]byte
+	var ok bool
+	if user != nil {
+		hash = user.PasswordHash
+		cached, ok = u.verified[username]
+	}
+	u.mu.RUnlock()
+	if user == nil {
+		// 用户不存在时也跑一次 bcrypt，避免通过响应时间枚举用户名
+		_ = bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return nil
+	}
+
+	sum := sha256.Sum256([]byte(password))
+	if !ok || subtle.ConstantTimeCompare(sum[:], cached[:]) != 1 {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			return nil
+		}
+		u.mu.Lock()
+		u.verified[username] = sum
+		u.mu.Unlock()
+	}
+	return u.Get(username)
+}
+
+// Get 按用户名查找用户，返回不含密码哈希的副本
+func (u *Users) Get(username string) *User {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	user := u.find(username)
+	if user == nil {
+		return nil
+	}
+	cp := *user
+	cp.PasswordHash = ""
+	return &cp
+}
+
+// List 返回所有用户（不含密码哈希）
+func (u *Users) List() []User {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	result := make([]User, 0, len(u.data.Users))
+	for _, user := range u.data.Users {
+		cp := *user
+		cp.PasswordHash = ""
+		result = append(result, cp)
+	}
+	return result
+}
+
+// Create 新建用户
+func (u *Users) Create(username, password string, role Role) (*User, error) {
+	if username == "" {
+		return nil, ErrEmptyUsername
+	}
+	if !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	if len(password) < MinPasswordLen {
+		return nil, ErrWeakPassword
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.find(username) != nil {
+		return nil, ErrUserExists
+	}
+	user := &User{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         role,
+		CreatedAt:    time.Now(),
+	}
+	u.data.Users = append(u.data.Users, user)
+	u.save()
+	cp := *user
+	cp.PasswordHash = ""
+	return &cp, nil
+}
+
+// Update 修改用户的密码和/或角色，空值表示不修改
+func (u *Users) Update(username, password string, role Role) (*User, error) {
+	if role != "" && !role.Valid() {
+		return nil, ErrInvalidRole
+	}
+	if password != "" && len(password) < MinPasswordLen {
+		return nil, ErrWeakPassword
+	}
+	var hash []byte
+	if password != "" {
+		var err error
+		if hash, err = bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost); err != nil {
+			return nil, err
+		}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	user := u.find(username)
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if role != "" && role != RoleAdmin && user.Role == RoleAdmin && u.adminCount() == 1 {
+		return nil, ErrLastAdmin
+	}
+	if role != "" {
+		user.Role = role
+	}
+	if hash != nil {
+		user.PasswordHash = string(hash)
+		delete(u.verified, username)
+	}
+	u.save()
+	cp := *user
+	cp.PasswordHash = ""
+	return &cp, nil
+}
+
+// Delete 删除用户
+func (u *Users) Delete(username string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	for i, user := range u.data.Users {
+		if user.Username != username {
+			continue
+		}
+		if user.Role == RoleAdmin && u.adminCount() == 1 {
+			return ErrLastAdmin
+		}
+		u.data.Users = append(u.data.Users[:i], u.data.Users[i+1:]...)
+		delete(u.verified, username)
+		u.save()
+		return nil
+	}
+	return ErrUserNotFound
+}
+
+// RoleTypes 返回各角色可下发的任务类型
+func (u *Users) RoleTypes() map[Role][]string {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	result := make(map[Role][]string, len(u.data.RoleTypes))
+	for role, types := range u.data.RoleTypes {
+		result[role] = append([]string{}, types...)
+	}
+	return result
+}
+
+// SetRoleTypes 设置某个非管理员角色可下发的任务类型
+func (u *Users) SetRoleTypes(role Role, types []string) error {
+	if !role.Valid() || role == RoleAdmin {
+		return ErrInvalidRole
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.data.RoleTypes[role] = append([]string{}, types...)
+	u.save()
+	return nil
+}
+
+// TypeAllowed 判断角色能否下发该类型的任务
+func (u *Users) TypeAllowed(role Role, taskType string) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+
+	for _, pattern := range u.data.RoleTypes[role] {
+		if MatchType(pattern, taskType) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchType 判断任务类型是否匹配 pattern，pattern 末尾的 * 表示前缀匹配
+func MatchType(pattern, taskType string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(taskType, prefix)
+	}
+	return pattern == taskType
+}
+
+func (u *Users) find(username string) *User {
+	for _, user := range u.data.Users {
+		if user.Username == username {
+			return user
+		}
+	}
+	return nil
+}
+
+func (u *Users) adminCount() int {
+	n := 0
+	for _, user := range u.data.Users {
+		if user.Role == RoleAdmin {
+			n++
+		}
+	}
+	return n
+}
+
+func (u *Users) save() {
+	data, _ := json.MarshalIndent(u.data, "", "  ")
+	_ = os.WriteFile(u.file, data, 0600)
+}
+
+func (u *Users) load() {
+	data, err := os.ReadFile(u.file)
+	if err != nil {
+		return
+	}
+	_ = json.Unmarshal(data, &u.data)
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"errors"
+	"testing"
+)
+
+const testPassword = "test-password"
+
+func TestUsersCreate(t *testing.T) {
+	u := NewUsers(t.TempDir())
+	for _, c := range []struct {
+		username, password string
+		role               Role
+		want               error
+	}{
+		{"", testPassword, RoleViewer, ErrEmptyUsername},
+		{"alice", testPassword, "root", ErrInvalidRole},
+		{"alice", "1234567", RoleViewer, ErrWeakPassword},
+		{"alice", "12345678", RoleViewer, nil},
+		{"alice", testPassword, RoleOperator, ErrUserExists},
+	} {
+		if _, err := u.Create(c.username, c.password, c.role); !errors.Is(err, c.want) {
+			t.Errorf("Create(%q, %q, %q) = %v，期望 %v", c.username, c.password, c.role, err, c.want)
+		}
+	}
+	if user := u.Get("alice"); user == nil || user.PasswordHash != "" || user.Role != RoleViewer {
+		t.Errorf("Get 返回 %+v，期望不含密码哈希的 viewer", user)
+	}
+}
+
+func TestUsersAuthenticate(t *testing.T) {
+	dir := t.TempDir()
+	u := NewUsers(dir)
+	if _, err := u.Create("alice", testPassword, RoleOperator); err != nil {
+		t.Fatal(err)
+	}
+	if user := u.Authenticate("alice", testPassword); user == nil || user.Role != RoleOperator {
+		t.Fatalf("正确的密码登录失败: %+v", user)
+	}
+	if u.Authenticate("alice", "wrong-password") != nil || u.Authenticate("bob", testPassword) != nil {
+		t.Error("错误的密码或不存在的用户登录成功")
+	}
+
+	// 改密码后旧密码立即失效，即使刚刚验证过
+	if _, err := u.Update("alice", "new-password", ""); err != nil {
+		t.Fatal(err)
+	}
+	if u.Authenticate("alice", testPassword) != nil {
+		t.Error("改密码后旧密码仍能登录")
+	}
+	if NewUsers(dir).Authenticate("alice", "new-password") == nil {
+		t.Error("重新加载后新密码无法登录")
+	}
+}
+
+func TestUsersLastAdmin(t *testing.T) {
+	u := NewUsers(t.TempDir())
+	u.Bootstrap("admin", testPassword)
+	if _, err := u.Update("admin", "", RoleOperator); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("降级最后一个管理员返回 %v", err)
+	}
+	if err := u.Delete("admin"); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("删除最后一个管理员返回 %v", err)
+	}
+
+	if _, err := u.Create("root", testPassword, RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Delete("admin"); err != nil {
+		t.Errorf("还有其他管理员时删除失败: %v", err)
+	}
+	if _, created := u.Bootstrap("admin", testPassword); created {
+		t.Error("已有用户时 Bootstrap 仍创建了管理员")
+	}
+}
+
+func TestRoleTypes(t *testing.T) {
+	dir := t.TempDir()
+	u := NewUsers(dir)
+	for _, c := range []struct {
+		role     Role
+		taskType string
+		want     bool
+	}{
+		{RoleAdmin, "Settings-Stage1", true},
+		{RoleOperator, "LinkStart-Combat", true},
+		{RoleOperator, "Toolbox-GachaOnce", true},
+		{RoleOperator, "Settings-Stage1", false},
+		{RoleViewer, "LinkStart", false},
+	} {
+		if got := u.TypeAllowed(c.role, c.taskType); got != c.want {
+			t.Errorf("%s 下发 %s: %v，期望 %v", c.role, c.taskType, got, c.want)
+		}
+	}
+
+	if err := u.SetRoleTypes(RoleAdmin, nil); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("修改管理员的任务类型返回 %v", err)
+	}
+	if err := u.SetRoleTypes(RoleOperator, []string{"CaptureImage*"}); err != nil {
+		t.Fatal(err)
+	}
+	u = NewUsers(dir)
+	if u.TypeAllowed(RoleOperator, "LinkStart") || !u.TypeAllowed(RoleOperator, "CaptureImageNow") {
+		t.Errorf("修改后 operator 的任务类型为 %v", u.RoleTypes()[RoleOperator])
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"ArknightsMaaRemoter/config"
+)
+
+// ── HTTPS ─────────────────────────────────────────────────────
+
+// tlsDir 是数据目录中保存自签 CA 和服务器证书的子目录
+const tlsDir = "tls"
+
+// setupTLS 按配置准备证书，未启用 HTTPS 时返回 nil。cert 和 key 是否成对已由配置校验。
+func setupTLS(cfg *config.Config) (*certReloader, error) {
+	certFile, keyFile := cfg.Resolve(cfg.TLS.Cert), cfg.Resolve(cfg.TLS.Key)
+	switch {
+	case certFile != "":
+	case cfg.TLS.SelfSigned:
+		var err error
+		if certFile, keyFile, err = ensureSelfSigned(cfg.Resolve(tlsDir), cfg.TLS.Hosts); err != nil {
+			return nil, fmt.Errorf("生成自签证书失败: %w", err)
+		}
+	default:
+		return nil, nil
+	}
+
+	cr := newCertReloader(certFile, keyFile)
+	if err := cr.reload(); err != nil {
+		return nil, err
+	}
+	go cr.watch(10 * time.Second)
+	return cr, nil
+}
+
+// certReloader 在证书文件变化后自动重新加载，续期证书无需重启
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func newCertReloader(certFile, keyFile string) *certReloader {
+	return &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (cr *certReloader) reload() error {
+	cert, err := tls.LoadX509KeyPair(cr.certFile, cr.keyFile)
+	if err != nil {
+		return fmt.Errorf("加载证书失败: %w", err)
+	}
+	mod := cr.latestModTime()
+	cr.mu.Lock()
+	cr.cert = &cert
+	cr.modTime = mod
+	cr.mu.Unlock()
+	return nil
+}
+
+// latestModTime 返回证书和私钥中较新的修改时间
+func (cr *certReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, f := range []string{cr.certFile, cr.keyFile} {
+		if info, err := os.Stat(f); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// watch 定期检查文件修改时间，直到 Close
+func (cr *certReloader) watch(interval time.Duration) {
+	defer close(cr.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-cr.stop:
+			return
+		case <-ticker.C:
+			cr.check()
+		}
+	}
+}
+
+// check 在文件变化后重新加载。证书和私钥可能先后写入，加载失败时保留旧证书，下次再试。
+func (cr *certReloader) check() {
+	cr.mu.RLock()
+	changed := cr.latestModTime().After(cr.modTime)
+	cr.mu.RUnlock()
+	if !changed {
+		return
+	}
+	if err := cr.reload(); err != nil {
+		log.Printf("证书已变化但%v，继续使用旧证书", err)
+		return
+	}
+	log.Printf("已重新加载证书 %s", cr.certFile)
+}
+
+// Close 停止检查证书文件。只能在 watch 启动后调用。
+func (cr *certReloader) Close() {
+	cr.once.Do(func() { close(cr.stop) })
+	<-cr.done
+}
+
+func (cr *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	return cr.cert, nil
+}
+
+// redirectToHTTPS 把 HTTP 请求跳转到同一主机的 HTTPS 端口。
+// POST 使用 308 保留请求方法和请求体，MAA 的端点也能正常跳转。
+func redirectToHTTPS(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		status := http.StatusMovedPermanently
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			status = http.StatusPermanentRedirect
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+	})
+}
+
+// ensureSelfSigned 首次运行时生成 CA 和由它签发的服务器证书，之后复用。
+// 服务器证书缺失、即将过期或未覆盖 hosts 时重新签发；CA 不变，客户端无需重新信任。
+func ensureSelfSigned(dir string, hosts []string) (certFile, keyFile string, err error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", "", err
+	}
+	caCertFile, caKeyFile := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca.key")
+	certFile, keyFile = filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+
+	ca, caKey, err := loadCA(caCertFile, caKeyFile)
+	if err != nil {
+		if ca, caKey, err = createCA(caCertFile, caKeyFile); err != nil {
+			return "", "", err
+		}
+		log.Printf("已生成自签 CA: %s", caCertFile)
+	}
+	log.Printf("CA 证书 SHA-256 指纹: %s", fingerprint(ca.Raw))
+	log.Printf("请在运行 MAA 的电脑上把 %s 导入「受信任的根证书颁发机构」", caCertFile)
+
+	dnsNames, ips := defaultHosts(hosts)
+	if serverCertValid(certFile, dnsNames, ips) {
+		return certFile, keyFile, nil
+	}
+	if err := createServerCert(certFile, keyFile, ca, caKey, dnsNames, ips); err != nil {
+		return "", "", err
+	}
+	log.Printf("已签发服务器证书: %s（%s）", certFile, strings.Join(append(dnsNames, ipStrings(ips)...), ", "))
+	return certFile, keyFile, nil
+}
+
+// defaultHosts 返回证书应包含的域名和 IP：localhost、本机名、本机所有地址，以及额外指定的 hosts
+func defaultHosts(extra []string) ([]string, []net.IP) {
+	dnsNames := []string{"localhost"}
+	if name, err := os.Hostname(); err == nil && name != "" && name != "localhost" {
+		dnsNames = append(dnsNames, name)
+	}
+	var ips []net.IP
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok && !ipnet.IP.IsLinkLocalUnicast() {
+				ips = append(ips, ipnet.IP)
+			}
+		}
+	}
+	for _, h := range extra {
+		if ip := net.ParseIP(h); ip != nil {
+			ips = append(ips, ip)
+		} else {
+			dnsNames = append(dnsNames, h)
+		}
+	}
+	return dnsNames, ips
+}
+
+func serverCertValid(certFile string, dnsNames []string, ips []net.IP) bool {
+	cert, err := readCert(certFile)
+	if err != nil || time.Until(cert.NotAfter) < 30*24*time.Hour {
+		return false
+	}
+	for _, name := range dnsNames {
+		if cert.VerifyHostname(name) != nil {
+			return false
+		}
+	}
+	for _, ip := range ips {
+		if cert.VerifyHostname(ip.String()) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func loadCA(certFile, keyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	cert, err := readCert(certFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, nil, errors.New("CA 私钥格式无效")
+	}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+func createCA(certFile, keyFile string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          randomSerial(),
+		Subject:               pkix.Name{CommonName: "MAA Remote CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writeCert(certFile, der); err != nil {
+		return nil, nil, err
+	}
+	if err := writeKey(keyFile, key); err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	return cert, key, err
+}
+
+func createServerCert(certFile, keyFile string, ca *x509.Certificate, caKey *ecdsa.PrivateKey, dnsNames []string, ips []net.IP) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		// 部分客户端拒绝有效期超过 825 天的证书
+		NotAfter:    time.Now().AddDate(0, 0, 825),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:    dnsNames,
+		IPAddresses: ips,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return err
+	}
+	if err := writeKey(keyFile, key); err != nil {
+		return err
+	}
+	return writeCert(certFile, der)
+}
+
+func readCert(file string) (*x509.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s 不是 PEM 格式的证书", file)
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func writeCert(file string, der []byte) error {
+	return os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+func writeKey(file string, key *ecdsa.PrivateKey) error {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)
+}
+
+func randomSerial() *big.Int {
+	n, _ := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	return n
+}
+
+// fingerprint 返回证书的 SHA-256 指纹，格式与浏览器和 certutil 显示的一致
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+func ipStrings(ips []net.IP) []string {
+	result := make([]string, len(ips))
+	for i, ip := range ips {
+		result[i] = ip.String()
+	}
+	return result
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEnsureSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	certFile, _, err := ensureSelfSigned(dir, []string{"maa.example", "10.0.0.5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := readCert(filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ca.IsCA {
+		t.Error("CA 证书没有标记为 CA")
+	}
+	leaf, err := readCert(certFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf.IsCA {
+		t.Error("服务器证书被标记为 CA")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	for _, host := range []string{"localhost", "maa.example", "10.0.0.5"} {
+		if _, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots}); err != nil {
+			t.Errorf("服务器证书不能用于 %s: %v", host, err)
+		}
+	}
+
+	// 再次运行时复用；增加主机后重新签发服务器证书，CA 不变
+	before, _ := os.ReadFile(certFile)
+	if _, _, err := ensureSelfSigned(dir, []string{"maa.example", "10.0.0.5"}); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := os.ReadFile(certFile); !bytes.Equal(before, after) {
+		t.Error("有效的服务器证书被重新签发")
+	}
+	if _, _, err := ensureSelfSigned(dir, []string{"maa.example", "10.0.0.5", "nas.lan"}); err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ = readCert(certFile)
+	if err := leaf.VerifyHostname("nas.lan"); err != nil {
+		t.Errorf("新增的主机没有加入证书: %v", err)
+	}
+	if again, _ := readCert(filepath.Join(dir, "ca.pem")); !bytes.Equal(again.Raw, ca.Raw) {
+		t.Error("重新签发服务器证书时 CA 变了")
+	}
+}
+
+// writeTestCert 写入一张自签、在 notAfter 过期的证书
+func writeTestCert(t *testing.T, certFile, keyFile string, notAfter time.Time, hosts ...string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: randomSerial(),
+		Subject:      pkix.Name{CommonName: hosts[0]},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		DNSNames:     hosts,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeCert(certFile, der); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeKey(keyFile, key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerCertValid(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	if serverCertValid(certFile, []string{"localhost"}, nil) {
+		t.Error("证书不存在时视为有效")
+	}
+
+	writeTestCert(t, certFile, keyFile, time.Now().AddDate(1, 0, 0), "localhost")
+	if !serverCertValid(certFile, []string{"localhost"}, nil) {
+		t.Error("有效的证书视为无效")
+	}
+	if serverCertValid(certFile, []string{"localhost", "maa.example"}, nil) {
+		t.Error("未覆盖的主机名视为有效")
+	}
+	if serverCertValid(certFile, []string{"localhost"}, []net.IP{net.ParseIP("10.0.0.5")}) {
+		t.Error("未覆盖的 IP 视为有效")
+	}
+
+	writeTestCert(t, certFile, keyFile, time.Now().AddDate(0, 0, 10), "localhost")
+	if serverCertValid(certFile, []string{"localhost"}, nil) {
+		t.Error("即将过期的证书视为有效")
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	for _, c := range []struct {
+		method, url, port, want string
+		code                    int
+	}{
+		{"GET", "http://maa.example:8080/admin?x=1", "8443", "https://maa.example:8443/admin?x=1", http.StatusMovedPermanently},
+		{"GET", "http://maa.example/", "443", "https://maa.example/", http.StatusMovedPermanently},
+		{"POST", "http://maa.example:8080/maa/getTask", "8443", "https://maa.example:8443/maa/getTask", http.StatusPermanentRedirect},
+		{"GET", "http://[::1]:8080/", "8443", "https://[::1]:8443/", http.StatusMovedPermanently},
+	} {
+		w := httptest.NewRecorder()
+		redirectToHTTPS(c.port).ServeHTTP(w, httptest.NewRequest(c.method, c.url, nil))
+		if w.Code != c.code || w.Header().Get("Location") != c.want {
+			t.Errorf("%s %s 跳转到 %d %s，期望 %d %s", c.method, c.url, w.Code, w.Header().Get("Location"), c.code, c.want)
+		}
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	writeTestCert(t, certFile, keyFile, time.Now().AddDate(1, 0, 0), "old.example")
+	cr := newCertReloader(certFile, keyFile)
+	if err := cr.reload(); err != nil {
+		t.Fatal(err)
+	}
+	leaf := func() string {
+		cert, _ := cr.GetCertificate(nil)
+		parsed, _ := x509.ParseCertificate(cert.Certificate[0])
+		return parsed.Subject.CommonName
+	}
+
+	// 只写入了证书、私钥还是旧的：保留旧证书
+	later := time.Now().Add(time.Minute)
+	writeTestCert(t, certFile, filepath.Join(dir, "other.key"), time.Now().AddDate(1, 0, 0), "new.example")
+	_ = os.Chtimes(certFile, later, later)
+	cr.check()
+	if got := leaf(); got != "old.example" {
+		t.Errorf("证书和私钥不匹配时加载了 %s", got)
+	}
+
+	writeTestCert(t, certFile, keyFile, time.Now().AddDate(1, 0, 0), "new.example")
+	later = later.Add(time.Minute)
+	_ = os.Chtimes(certFile, later, later)
+	_ = os.Chtimes(keyFile, later, later)
+	cr.check()
+	if got := leaf(); got != "new.example" {
+		t.Errorf("证书更新后仍在使用 %s", got)
+	}
+
+	// 启动的检查在 Close 后退出
+	go cr.watch(time.Millisecond)
+	done := make(chan struct{})
+	go func() {
+		cr.Close()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close 没有停止检查")
+	}
+}